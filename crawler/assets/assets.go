@@ -0,0 +1,276 @@
+// Package assets downloads the logos and the screenshots of the software,
+// so that the site serves local copies instead of hotlinking them from the
+// code hosting services.
+//
+// The images are validated, the SVGs sanitized and the raster images get a
+// thumbnail. The processed files are cached with the ETag and Last-Modified
+// of their source, so they are downloaded again only if it changed.
+package assets
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Asset is an image exported for the site.
+type Asset struct {
+	// Source is the URL the image was downloaded from.
+	Source string `json:"source"`
+	// Path and Thumbnail are relative to the output directory. Thumbnail
+	// is empty if the image is small enough or not a raster image.
+	Path        string `json:"path"`
+	Thumbnail   string `json:"thumbnail,omitempty"`
+	ContentType string `json:"contentType"`
+}
+
+// cached is the metadata of a cached asset.
+type cached struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	ContentType  string `json:"contentType"`
+	Ext          string `json:"ext"`
+	Thumbnail    bool   `json:"thumbnail"`
+
+	// err is the error of the download, if any.
+	err error
+}
+
+// Store downloads and caches the assets. It's not safe for concurrent use.
+type Store struct {
+	// Dir is the directory of the cache.
+	Dir    string
+	Client *http.Client
+	// MaxSize is the maximum size of an asset, in bytes.
+	MaxSize int64
+	// ThumbnailWidth is the width of the thumbnails of the raster images.
+	ThumbnailWidth int
+
+	// checked are the assets already checked by this Store, by URL, so an
+	// asset used by many software is downloaded once.
+	checked map[string]*cached
+}
+
+// NewStore returns a Store caching the assets in dir.
+func NewStore(dir string, client *http.Client, maxSize int64, thumbnailWidth int) *Store {
+	return &Store{
+		Dir:            dir,
+		Client:         client,
+		MaxSize:        maxSize,
+		ThumbnailWidth: thumbnailWidth,
+		checked:        map[string]*cached{},
+	}
+}
+
+// Export downloads the image at rawURL, or reuses the cached copy if it
+// didn't change, and writes it to outputDir/dir/name, with the extension of
+// its type. The error is an InvalidError if the image can't be served.
+func (s *Store) Export(rawURL, outputDir, dir, name string) (Asset, error) {
+	c, ok := s.checked[rawURL]
+	if !ok {
+		c = s.fetch(rawURL)
+		s.checked[rawURL] = c
+	}
+	if c.err != nil {
+		return Asset{}, c.err
+	}
+
+	asset := Asset{
+		Source:      rawURL,
+		Path:        path.Join(dir, name+"."+c.Ext),
+		ContentType: c.ContentType,
+	}
+	if err := os.MkdirAll(filepath.Join(outputDir, filepath.FromSlash(dir)), 0755); err != nil {
+		return Asset{}, err
+	}
+	if err := linkFile(s.file(rawURL, "asset."+c.Ext), filepath.Join(outputDir, filepath.FromSlash(asset.Path))); err != nil {
+		return Asset{}, err
+	}
+	if c.Thumbnail {
+		asset.Thumbnail = path.Join(dir, name+".thumb."+thumbnailExt(c.Ext))
+		if err := linkFile(s.file(rawURL, "thumb."+thumbnailExt(c.Ext)), filepath.Join(outputDir, filepath.FromSlash(asset.Thumbnail))); err != nil {
+			return Asset{}, err
+		}
+	}
+
+	return asset, nil
+}
+
+// fetch downloads the image at rawURL into the cache, unless the cached
+// copy is still valid.
+func (s *Store) fetch(rawURL string) *cached {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &cached{err: invalid("invalid URL: %v", err)}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &cached{err: invalid("unsupported URL scheme %q", u.Scheme)}
+	}
+
+	old := s.load(rawURL)
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return &cached{err: err}
+	}
+	if old != nil {
+		if old.ETag != "" {
+			req.Header.Set("If-None-Match", old.ETag)
+		}
+		if old.LastModified != "" {
+			req.Header.Set("If-Modified-Since", old.LastModified)
+		}
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		if old != nil {
+			log.Debugf("using the cached copy of %s: %v", rawURL, err)
+			return old
+		}
+		return &cached{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && old != nil {
+		return old
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &cached{err: fmt.Errorf("GET %s: %s", rawURL, resp.Status)}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, s.MaxSize+1))
+	if err != nil {
+		return &cached{err: err}
+	}
+	if int64(len(data)) > s.MaxSize {
+		return &cached{err: invalid("larger than %d bytes", s.MaxSize)}
+	}
+
+	file, ext, contentType, thumbnail, err := process(data, s.ThumbnailWidth)
+	if err != nil {
+		return &cached{err: err}
+	}
+
+	c := &cached{
+		URL:          rawURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  contentType,
+		Ext:          ext,
+		Thumbnail:    thumbnail != nil,
+	}
+	if err := s.save(c, file, thumbnail); err != nil {
+		return &cached{err: err}
+	}
+
+	return c
+}
+
+// file returns the path of a file in the cache of the asset at rawURL.
+func (s *Store) file(rawURL, name string) string {
+	sum := sha1.Sum([]byte(rawURL))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]), name)
+}
+
+// load returns the metadata of the cached asset at rawURL, nil if it's
+// not cached.
+func (s *Store) load(rawURL string) *cached {
+	data, err := ioutil.ReadFile(s.file(rawURL, "meta.json"))
+	if err != nil {
+		return nil
+	}
+
+	var c cached
+	if err := json.Unmarshal(data, &c); err != nil || c.URL != rawURL {
+		return nil
+	}
+	if _, err := os.Stat(s.file(rawURL, "asset."+c.Ext)); err != nil {
+		return nil
+	}
+
+	return &c
+}
+
+// save writes the files of the asset c to the cache. The metadata is
+// written last, so the cache is valid only if all the files are there.
+func (s *Store) save(c *cached, file, thumbnail []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.file(c.URL, "meta.json")), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(s.file(c.URL, "asset."+c.Ext), file); err != nil {
+		return err
+	}
+	if thumbnail != nil {
+		if err := writeFile(s.file(c.URL, "thumb."+thumbnailExt(c.Ext)), thumbnail); err != nil {
+			return err
+		}
+	}
+
+	meta, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(s.file(c.URL, "meta.json"), meta)
+}
+
+// writeFile replaces the file at name with a new one, instead of writing
+// to it, since the old one may be linked from a published export.
+func writeFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// thumbnailExt returns the extension of the thumbnails of the images with
+// extension ext.
+func thumbnailExt(ext string) string {
+	if ext == "jpg" {
+		return "jpg"
+	}
+
+	return "png"
+}
+
+// linkFile makes the file at src available at dst, with a hard link if
+// possible, since the cache and the output are often on the same disk.
+func linkFile(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,187 @@
+package assets
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const logoSVG = `<?xml version="1.0"?>
+<!DOCTYPE svg [<!ENTITY x "y">]>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10" onload="alert(1)">
+  <!-- comment -->
+  <style>.a { fill: url(#g) }</style>
+  <style>@import url(https://example.org/track.css);</style>
+  <script>alert(document.cookie)</script>
+  <foreignObject><div>html</div></foreignObject>
+  <a xlink:href="javascript:alert(1)"><rect class="a" width="10" height="10"/></a>
+  <use href="#r" style="fill: url( https://example.org/x )"/>
+  <text x="1" y="5">A &amp; B</text>
+</svg>`
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	assert.True(t, isSVG([]byte(logoSVG)))
+	assert.False(t, isSVG([]byte("<html><body></body></html>")))
+	assert.False(t, isSVG([]byte("logo")))
+
+	out, err := sanitizeSVG([]byte(logoSVG))
+	assert.NoError(t, err)
+	svg := string(out)
+
+	for _, unsafe := range []string{"DOCTYPE", "onload", "comment", "@import", "script", "cookie", "foreignObject", "html", "javascript", "https://example.org"} {
+		assert.NotContains(t, svg, unsafe)
+	}
+	assert.Contains(t, svg, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">`)
+	assert.Contains(t, svg, `<style>.a { fill: url(#g) }</style>`)
+	assert.Contains(t, svg, `<a><rect class="a" width="10" height="10"></rect></a>`)
+	assert.Contains(t, svg, `<use href="#r"></use>`)
+	assert.Contains(t, svg, `<text x="1" y="5">A &amp; B</text>`)
+	assert.True(t, isSVG(out))
+
+	_, err = sanitizeSVG([]byte("<svg><g></svg>"))
+	assert.Error(t, err)
+}
+
+func TestProcess(t *testing.T) {
+	file, ext, contentType, thumbnail, err := process(testPNG(t, 800, 200), 400)
+	assert.NoError(t, err)
+	assert.Equal(t, "png", ext)
+	assert.Equal(t, "image/png", contentType)
+	assert.NotEmpty(t, file)
+	thumb, err := png.Decode(bytes.NewReader(thumbnail))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 400, 100), thumb.Bounds())
+	r, g, b, a := thumb.At(10, 10).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0, 0, 0xffff}, []uint32{r, g, b, a})
+
+	// Small images have no thumbnail.
+	_, _, _, thumbnail, err = process(testPNG(t, 100, 100), 400)
+	assert.NoError(t, err)
+	assert.Nil(t, thumbnail)
+
+	_, ext, contentType, _, err = process([]byte(logoSVG), 400)
+	assert.NoError(t, err)
+	assert.Equal(t, "svg", ext)
+	assert.Equal(t, "image/svg+xml", contentType)
+
+	_, _, _, _, err = process([]byte("<html><script></script></html>"), 400)
+	assert.True(t, IsInvalid(err))
+	_, _, _, _, err = process(append([]byte("\x89PNG\r\n\x1a\n"), "garbage"...), 400)
+	assert.True(t, IsInvalid(err))
+}
+
+func TestExport(t *testing.T) {
+	logo := testPNG(t, 800, 800)
+	var requests, downloads int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			atomic.AddInt32(&downloads, 1)
+			w.Write(logo) // nolint: errcheck
+		case "/logo.svg":
+			w.Write([]byte(logoSVG)) // nolint: errcheck
+		case "/big.png":
+			w.Write(make([]byte, 2048)) // nolint: errcheck
+		case "/page.html":
+			w.Write([]byte("<!DOCTYPE html><html></html>")) // nolint: errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cacheDir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+	outputDir := filepath.Join(cacheDir, "output")
+
+	newStore := func() *Store {
+		return NewStore(filepath.Join(cacheDir, "cache"), &http.Client{Timeout: 5 * time.Second}, 1024*1024, 200)
+	}
+	s := newStore()
+
+	asset, err := s.Export(ts.URL+"/logo.png", outputDir, "assets/slug", "logo")
+	assert.NoError(t, err)
+	assert.Equal(t, Asset{
+		Source:      ts.URL + "/logo.png",
+		Path:        "assets/slug/logo.png",
+		Thumbnail:   "assets/slug/logo.thumb.png",
+		ContentType: "image/png",
+	}, asset)
+	data, err := ioutil.ReadFile(filepath.Join(outputDir, "assets/slug/logo.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, logo, data)
+	_, err = os.Stat(filepath.Join(outputDir, "assets/slug/logo.thumb.png"))
+	assert.NoError(t, err)
+
+	// The same image is downloaded once.
+	_, err = s.Export(ts.URL+"/logo.png", outputDir, "assets/other", "logo")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// The next exports reuse the cache, if the image didn't change.
+	_, err = newStore().Export(ts.URL+"/logo.png", filepath.Join(cacheDir, "next"), "assets/slug", "logo")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+	data, err = ioutil.ReadFile(filepath.Join(cacheDir, "next", "assets/slug/logo.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, logo, data)
+
+	asset, err = s.Export(ts.URL+"/logo.svg", outputDir, "assets/slug", "monochrome-logo")
+	assert.NoError(t, err)
+	assert.Equal(t, "assets/slug/monochrome-logo.svg", asset.Path)
+	assert.Empty(t, asset.Thumbnail)
+	data, err = ioutil.ReadFile(filepath.Join(outputDir, asset.Path))
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "script"))
+
+	s.MaxSize = 1024
+	_, err = s.Export(ts.URL+"/big.png", outputDir, "assets/slug", "screenshot")
+	assert.True(t, IsInvalid(err))
+	_, err = s.Export(ts.URL+"/page.html", outputDir, "assets/slug", "screenshot")
+	assert.True(t, IsInvalid(err))
+	_, err = s.Export("file:///etc/passwd", outputDir, "assets/slug", "screenshot")
+	assert.True(t, IsInvalid(err))
+
+	// Not found is not invalid, the image may come back.
+	_, err = s.Export(ts.URL+"/missing.png", outputDir, "assets/slug", "screenshot")
+	assert.Error(t, err)
+	assert.False(t, IsInvalid(err))
+}
@@ -0,0 +1,158 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+)
+
+// maxDimension is the maximum width and height of a raster image, so that
+// decoding it doesn't use too much memory.
+const maxDimension = 10000
+
+// process validates the image in data and returns the file to serve, its
+// extension and content type, and the thumbnail (nil if the image is not
+// wider than thumbnailWidth or can't be resized).
+func process(data []byte, thumbnailWidth int) (file []byte, ext, contentType string, thumbnail []byte, err error) {
+	contentType = http.DetectContentType(data)
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif":
+		thumbnail, err = rasterThumbnail(data, contentType, thumbnailWidth)
+		if err != nil {
+			return nil, "", "", nil, err
+		}
+		return data, extensions[contentType], contentType, thumbnail, nil
+	case "image/webp":
+		// There is no WebP decoder in the standard library: the image is
+		// served as it is.
+		return data, extensions[contentType], contentType, nil, nil
+	}
+
+	if strings.HasPrefix(contentType, "text/") && isSVG(data) {
+		file, err = sanitizeSVG(data)
+		if err != nil {
+			return nil, "", "", nil, invalid("invalid SVG: %v", err)
+		}
+		return file, "svg", "image/svg+xml", nil, nil
+	}
+
+	return nil, "", "", nil, invalid("unsupported content type %s", contentType)
+}
+
+var extensions = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// rasterThumbnail returns the image in data resized to width, encoded as
+// JPEG if it's a JPEG and as PNG otherwise.
+func rasterThumbnail(data []byte, contentType string, width int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalid("invalid image: %v", err)
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, invalid("image too large (%dx%d)", config.Width, config.Height)
+	}
+	if width <= 0 || config.Width <= width {
+		return nil, nil
+	}
+
+	var src image.Image
+	switch contentType {
+	case "image/gif":
+		// Only the first frame of the animations.
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		src, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, invalid("invalid image: %v", err)
+	}
+
+	height := config.Height * width / config.Width
+	if height < 1 {
+		height = 1
+	}
+	dst := resize(src, width, height)
+
+	var out bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&out, dst)
+	}
+
+	return out.Bytes(), err
+}
+
+// resize scales src down to width and height, averaging the pixels of src
+// that fall into every pixel of the result.
+func resize(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	// Work on premultiplied colors, so the transparent pixels don't darken
+	// the result.
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*b.Dy()/height, (y+1)*b.Dy()/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*b.Dx()/width, (x+1)*b.Dx()/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(rgba.Pix[i+c])
+					}
+					i += 4
+				}
+			}
+
+			n := (x1 - x0) * (y1 - y0)
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	return dst
+}
+
+// InvalidError is returned for the assets that can't be served, as
+// opposed to the ones that can't be downloaded.
+type InvalidError struct {
+	Reason string
+}
+
+func (e *InvalidError) Error() string {
+	return e.Reason
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &InvalidError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// IsInvalid returns true if err is an InvalidError.
+func IsInvalid(err error) bool {
+	_, ok := err.(*InvalidError)
+	return ok
+}
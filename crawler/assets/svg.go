@@ -0,0 +1,152 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// unsafeElements are the SVG elements that can run scripts, embed other
+// documents or change the links, which are removed with their content.
+var unsafeElements = map[string]bool{
+	"script":           true,
+	"foreignobject":    true,
+	"iframe":           true,
+	"embed":            true,
+	"object":           true,
+	"handler":          true,
+	"listener":         true,
+	"set":              true,
+	"animate":          true,
+	"animatemotion":    true,
+	"animatetransform": true,
+}
+
+// isSVG returns true if data is an XML document with an svg root element.
+func isSVG(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return strings.EqualFold(start.Name.Local, "svg")
+		}
+	}
+}
+
+// sanitizeSVG returns the SVG document in data without the unsafe elements,
+// the event handlers, the links to other documents, the comments, the
+// processing instructions and the DTD.
+func sanitizeSVG(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+
+	// open are the elements not closed yet, skip is the depth of the unsafe
+	// element being skipped, 0 if none.
+	var open []string
+	skip := 0
+	inStyle := false
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			if len(open) > 0 {
+				return nil, fmt.Errorf("element <%s> not closed", open[len(open)-1])
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			open = append(open, qualifiedName(t.Name))
+			if skip > 0 {
+				continue
+			}
+			if unsafeElements[strings.ToLower(t.Name.Local)] {
+				skip = len(open)
+				continue
+			}
+			inStyle = strings.EqualFold(t.Name.Local, "style")
+			out.WriteString("<" + qualifiedName(t.Name))
+			for _, attr := range t.Attr {
+				if !safeAttr(attr) {
+					continue
+				}
+				out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+				xml.EscapeText(&out, []byte(attr.Value)) // nolint: errcheck
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			name := qualifiedName(t.Name)
+			if len(open) == 0 || open[len(open)-1] != name {
+				return nil, fmt.Errorf("unexpected end element </%s>", name)
+			}
+			inStyle = false
+			if skip == 0 {
+				out.WriteString("</" + name + ">")
+			}
+			if len(open) == skip {
+				skip = 0
+			}
+			open = open[:len(open)-1]
+		case xml.CharData:
+			if skip > 0 || (inStyle && !safeStyle(string(t))) {
+				continue
+			}
+			xml.EscapeText(&out, t) // nolint: errcheck
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// safeAttr returns true if attr is not an event handler and doesn't link
+// to other documents or scripts.
+func safeAttr(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	value := strings.ToLower(strings.TrimSpace(attr.Value))
+
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+	if name == "href" {
+		// Only the references to the document itself and the embedded images.
+		return strings.HasPrefix(value, "#") || strings.HasPrefix(value, "data:image/")
+	}
+
+	return safeStyle(value)
+}
+
+// safeStyle returns true if the CSS or the attribute value in style doesn't
+// run scripts or load external resources.
+func safeStyle(style string) bool {
+	style = strings.ToLower(strings.Join(strings.Fields(style), ""))
+	if strings.Contains(style, "javascript:") || strings.Contains(style, "@import") {
+		return false
+	}
+	for _, part := range strings.Split(style, "url(")[1:] {
+		part = strings.TrimLeft(part, `"'`)
+		if !strings.HasPrefix(part, "#") && !strings.HasPrefix(part, "data:image/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/crawler"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	blacklistCmd.AddCommand(blacklistListCmd)
+	blacklistCmd.AddCommand(blacklistCheckCmd)
+	rootCmd.AddCommand(blacklistCmd)
+}
+
+var blacklistCmd = &cobra.Command{
+	Use:   "blacklist",
+	Short: "Inspect the blacklist.",
+	Long:  `Inspect the blacklist in BLACKLIST_FOLDER.`,
+}
+
+var blacklistListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all the entries in the blacklist.",
+	Long:  `List all the entries in the blacklist, including the expired ones.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repos := crawler.GetBlacklist().Repos()
+
+		// Prepare data table.
+		var data [][]string
+		now := time.Now()
+		for _, repo := range repos {
+			url, kind := repo.URL, "repository"
+			if repo.Org != "" {
+				url, kind = repo.Org, "organization"
+			}
+			expired := ""
+			if repo.Expired(now) {
+				expired = "expired"
+			}
+			data = append(data, []string{url, kind, repo.Reason, repo.Description, repo.Until, expired})
+		}
+
+		// Write data and render as table in os.Stdout.
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"URL", "Type", "Reason", "Description", "Until", ""})
+		table.SetFooter([]string{"Total entries: " + strconv.Itoa(len(repos)), "", "", "", "", ""})
+		table.SetRowLine(true)
+		table.AppendBulk(data)
+		table.Render()
+	}}
+
+var blacklistCheckCmd = &cobra.Command{
+	Use:   "check [repo url]",
+	Short: "Check whether [repo url] is blacklisted.",
+	Long: `Check whether the repository defined with [repo url] is blacklisted.
+Exits with status 1 if it is.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := crawler.GetBlacklist().Match(args[0])
+		if repo == nil {
+			fmt.Printf("%s is not blacklisted\n", args[0])
+			return
+		}
+
+		entry := repo.URL
+		if repo.Org != "" {
+			entry = "organization " + repo.Org
+		}
+		fmt.Printf("%s is blacklisted by %s\n", args[0], entry)
+		fmt.Printf("Reason: %s\nDescription: %s\n", repo.Reason, repo.Description)
+		if repo.Until != "" {
+			fmt.Printf("Until: %s\n", repo.Until)
+		}
+		os.Exit(1)
+	}}
@@ -17,7 +17,7 @@ var crawlCmd = &cobra.Command{
 	Short: "Crawl publiccode.yml files from given domains.",
 	Long:  `Crawl publiccode.yml files according to the supplied whitelist file(s).`,
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
 		orgs := make(map[string]bool)
 		c := crawler.NewCrawler(dryRun)
 
@@ -26,17 +26,17 @@ var crawlCmd = &cobra.Command{
 		for id := range args {
 			readWhitelist, err := crawler.ReadAndParseWhitelist(args[id])
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 
 		Publisher:
 			for _, publisher := range readWhitelist {
 				for _, org := range publisher.Organizations {
-					if orgs[org] {
-						log.Warnf("Skipping publisher '%s': organization '%s' already present", publisher.Name, org)
+					if orgs[org.URL] {
+						log.Warnf("Skipping publisher '%s': organization '%s' already present", publisher.Name, org.URL)
 						continue Publisher
 					} else {
-						orgs[org] = true
+						orgs[org.URL] = true
 					}
 				}
 				publishers = append(publishers, publisher)
@@ -45,7 +45,7 @@ var crawlCmd = &cobra.Command{
 
 		toBeRemoved, err := c.CrawlPublishers(publishers)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		// I should call delete for items in blacklist
@@ -59,9 +59,16 @@ var crawlCmd = &cobra.Command{
 			}
 		}
 
+		// Send the changes to the catalogue to the webhook subscribers.
+		if !dryRun {
+			crawler.DeliverEvents()
+		}
+
 		// Generate the data files for Jekyll.
 		err = c.ExportForJekyll()
 		if err != nil {
 			log.Errorf("Error while exporting data for Jekyll: %v", err)
 		}
-	}}
+
+		return nil
+	})}
@@ -24,6 +24,9 @@ var deleteCmd = &cobra.Command{
 			log.Error(err)
 		}
 
+		// Send the changes to the catalogue to the webhook subscribers.
+		crawler.DeliverEvents()
+
 		// Generate the data files for Jekyll.
 		err = c.ExportForJekyll()
 		if err != nil {
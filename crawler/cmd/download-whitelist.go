@@ -8,7 +8,6 @@ import (
 
 	"github.com/italia/developers-italia-backend/crawler/crawler"
 	"github.com/spf13/cobra"
-	"github.com/thoas/go-funk"
 	"gopkg.in/yaml.v2"
 )
 
@@ -33,11 +32,10 @@ var downloadWhitelistCmd = &cobra.Command{
 		// Read the current destinatin whitelist, if any
 		var publishers crawler.Whitelist
 		if _, err := os.Stat(args[1]); err == nil {
-			data, err := ioutil.ReadFile(args[1])
+			publishers, err = crawler.ReadAndParseWhitelist(args[1])
 			if err != nil {
-				log.Fatalf("error in reading %s: %v", args[1], err)
+				log.Fatal(err)
 			}
-			yaml.Unmarshal(data, &publishers)
 		}
 
 		// Download the repo-list file
@@ -64,7 +62,12 @@ var downloadWhitelistCmd = &cobra.Command{
 			for idx, publisher := range publishers {
 				if publisher.CodiceIPA == i.IPA {
 					// If this IPA code is already known, append this URL to the existing item
-					publishers[idx].Organizations = funk.UniqString(append(publisher.Organizations, i.URL))
+					for _, org := range publisher.Organizations {
+						if org.URL == i.URL {
+							continue REPOLIST
+						}
+					}
+					publishers[idx].Organizations = append(publisher.Organizations, crawler.NewOrg(i.URL))
 					continue REPOLIST
 				}
 			}
@@ -73,7 +76,7 @@ var downloadWhitelistCmd = &cobra.Command{
 			publishers = append(publishers, crawler.PA{
 				Name:          i.IPA,
 				CodiceIPA:     i.IPA,
-				Organizations: []string{i.URL},
+				Organizations: []crawler.Org{crawler.NewOrg(i.URL)},
 			})
 		}
 
@@ -83,7 +86,7 @@ var downloadWhitelistCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		defer f.Close()
-		data, err := yaml.Marshal(publishers)
+		data, err := crawler.MarshalWhitelist(publishers)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1,25 +1,52 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/italia/developers-italia-backend/crawler/crawler"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var (
+	exportFormat   string
+	exportRollback bool
+)
+
 func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "yaml",
+		fmt.Sprintf("export format (%s)", strings.Join(crawler.ExportFormats(), ", ")))
+	exportCmd.Flags().BoolVar(&exportRollback, "rollback", false, "publish again the previous export instead of exporting")
 	rootCmd.AddCommand(exportCmd)
 }
 
 var exportCmd = &cobra.Command{
 	Use:   "export",
-	Short: "Export YAML files.",
-	Long:  `Export YAML files for the front end.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Export the catalog for the front ends.",
+	Long: `Export the catalog for the front ends.
+
+The yaml format generates the data files for Jekyll, the json format
+generates a static JSON API and the dcat format generates a DCAT-AP_IT
+and schema.org catalog for open data portals.
+
+Files are published only when the whole export is valid, and the previous
+export is kept so that it can be restored with --rollback.`,
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
+		if exportRollback {
+			if err := crawler.RollbackExport(exportFormat); err != nil {
+				return err
+			}
+			log.Infof("Rolled back to the previous %s export", exportFormat)
+			return nil
+		}
+
 		c := crawler.NewCrawler(false)
 
-		// Generate the data files for Jekyll.
-		err := c.ExportForJekyll()
+		err := c.Export(exportFormat)
 		if err != nil {
-			log.Errorf("Error while exporting data for Jekyll: %v", err)
+			log.Errorf("Error while exporting data in %s format: %v", exportFormat, err)
 		}
-	}}
+
+		return nil
+	})}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	"github.com/italia/developers-italia-backend/crawler/history"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history [repo url]",
+	Short: "Show the history of the publiccode.yml of [repo url].",
+	Long: `Show the versions of the publiccode.yml of the repository defined
+with [repo url] stored in Elasticsearch, and the fields changed by every version.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		es, err := elastic.ClientFactory(
+			viper.GetString("ELASTIC_URL"),
+			viper.GetString("ELASTIC_USER"),
+			viper.GetString("ELASTIC_PWD"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		versions, err := history.List(args[0], viper.GetString("ELASTIC_HISTORY_INDEX"), es)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(versions) == 0 {
+			log.Fatalf("No versions found for %s", args[0])
+		}
+
+		// Prepare data table.
+		var data [][]string
+		for _, entry := range history.Entries(versions, time.Now()) {
+			var changes []string
+			for _, change := range entry.Changes {
+				changes = append(changes, formatChange(change))
+			}
+			data = append(data, []string{
+				entry.CrawlTime,
+				entry.Commit,
+				strings.Join(changes, "\n"),
+				strings.Join(entry.Notes, "\n"),
+			})
+		}
+
+		// Write data and render as table in os.Stdout.
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Crawl time", "Commit", "Changes", "Notes"})
+		table.SetFooter([]string{"Total versions: " + fmt.Sprint(len(versions)), "", "", ""})
+		table.SetAutoWrapText(false)
+		table.SetRowLine(true)
+		table.AppendBulk(data)
+		table.Render()
+	}}
+
+func formatChange(change history.Change) string {
+	switch {
+	case change.Old == nil:
+		return fmt.Sprintf("+ %s: %v", change.Field, change.New)
+	case change.New == nil:
+		return fmt.Sprintf("- %s: %v", change.Field, change.Old)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", change.Field, change.Old, change.New)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/italia/developers-italia-backend/crawler/crawler"
 	"github.com/olekukonko/tablewriter"
@@ -32,16 +33,20 @@ var listCmd = &cobra.Command{
 		// Process every item in whitelist.
 		for _, pa := range whitelist {
 			// And add to data table.
-			data = append(data, []string{pa.Name, pa.CodiceIPA, ""})
+			var contacts []string
+			for _, contact := range pa.Contacts {
+				contacts = append(contacts, strings.TrimSpace(contact.Name+" "+contact.Email+" "+contact.Phone))
+			}
+			data = append(data, []string{pa.Name, pa.CodiceIPA, strings.Join(contacts, "\n"), ""})
 			for _, org := range pa.Organizations {
-				data = append(data, []string{pa.Name, pa.CodiceIPA, org})
+				data = append(data, []string{pa.Name, pa.CodiceIPA, strings.Join(contacts, "\n"), org.URL})
 			}
 		}
 
 		// Write data and render as table in os.Stdout.
 		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Name", "Codice iPA", "Repository"})
-		table.SetFooter([]string{"Total Public Administrations: " + strconv.Itoa(len(whitelist)), "", ""})
+		table.SetHeader([]string{"Name", "Codice iPA", "Contacts", "Repository"})
+		table.SetFooter([]string{"Total Public Administrations: " + strconv.Itoa(len(whitelist)), "", "", ""})
 		table.SetAutoMergeCells(true)
 		table.SetRowLine(true)
 		table.AppendBulk(data)
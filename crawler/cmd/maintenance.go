@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/italia/developers-italia-backend/crawler/crawler"
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var maintenanceJSON bool
+
+func init() {
+	maintenanceCmd.Flags().BoolVar(&maintenanceJSON, "json", false, "print the publishers as JSON, to be sent to their PEC addresses")
+	rootCmd.AddCommand(maintenanceCmd)
+}
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "List the expiring and expired maintenance contracts by publisher.",
+	Long: `List the maintenance contracts of the software in Elasticsearch that
+expire within MAINTENANCE_EXPIRING_DAYS or are expired, grouped by publisher
+with its PEC address in IndicePA.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		es, err := elastic.ClientFactory(
+			viper.GetString("ELASTIC_URL"),
+			viper.GetString("ELASTIC_USER"),
+			viper.GetString("ELASTIC_PWD"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		publishers, err := crawler.ExpiringContracts(es)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if maintenanceJSON {
+			out, err := json.MarshalIndent(publishers, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		// Prepare data table.
+		var data [][]string
+		contracts := 0
+		for _, p := range publishers {
+			for _, c := range p.Contracts {
+				data = append(data, []string{p.CodiceIPA, p.PEC, c.Software, c.Contractor, c.Until, c.Status})
+				contracts++
+			}
+		}
+
+		// Write data and render as table in os.Stdout.
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Publisher", "PEC", "Software", "Contractor", "Until", "Status"})
+		table.SetFooter([]string{"Publishers: " + strconv.Itoa(len(publishers)), "", "Contracts: " + strconv.Itoa(contracts), "", "", ""})
+		table.SetAutoMergeCells(true)
+		table.SetRowLine(true)
+		table.AppendBulk(data)
+		table.Render()
+	}}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/italia/developers-italia-backend/crawler/crawler"
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	"github.com/italia/developers-italia-backend/crawler/notify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var notifyDryRun bool
+
+func init() {
+	notifyCmd.Flags().BoolVar(&notifyDryRun, "dry-run", false, "print the digests that would be sent, without sending them")
+	rootCmd.AddCommand(notifyCmd)
+}
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Notify the administrations of the issues in their software.",
+	Long: `Send to the PEC address of every administration in IndicePA a digest of
+the issues found by the crawls in its software: invalid publiccode.yml
+files, codiceIPA mismatches, expired maintenance contracts and broken links.
+
+A recipient gets a digest at most every NOTIFY_INTERVAL, and only with the
+issues not notified in the last NOTIFY_REPEAT.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		es, err := elastic.ClientFactory(
+			viper.GetString("ELASTIC_URL"),
+			viper.GetString("ELASTIC_USER"),
+			viper.GetString("ELASTIC_PWD"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		digests, err := crawler.Notifications(es)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		notifier, err := crawler.NewNotifier()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if notifyDryRun {
+			for _, d := range digests {
+				d.Issues = notifier.Pending(d)
+				if len(d.Issues) == 0 {
+					continue
+				}
+				subject, body, err := notify.Render(d)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Fprintf(os.Stdout, "To: %s\nSubject: %s\n\n%s\n", d.PEC, subject, body)
+			}
+			return
+		}
+
+		sent, err := notifier.Send(digests)
+		// Record the notifications sent even if some failed.
+		if err := crawler.SaveNotificationLog(notifier); err != nil {
+			log.Errorf("cannot save the notification log: %v", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("%d administrations notified", sent)
+	}}
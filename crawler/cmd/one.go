@@ -21,36 +21,49 @@ var oneCmd = &cobra.Command{
 		according to the supplied whitelist file(s).
 		No organizations! Only single repositories!`,
 	Args: cobra.MinimumNArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
 		// check if repo url is not present in blacklist
 		// if so report error and exit.
 		if crawler.IsRepoInBlackList(args[0]) {
-			return
+			return nil
 		}
 
 		c := crawler.NewCrawler(dryRun)
 
 		repoURL, whitelists := args[0], args[1:]
-		err := c.CrawlRepo(repoURL, getPAfromWhiteList(repoURL, whitelists))
+		pa, opts, err := getPAfromWhiteList(repoURL, whitelists)
+		if err != nil {
+			return err
+		}
+		err = c.CrawlRepo(repoURL, pa, opts)
 		if err != nil {
 			log.Error(err)
 		}
 
+		// Send the changes to the catalogue to the webhook subscribers.
+		if !dryRun {
+			crawler.DeliverEvents()
+		}
+
 		// Generate the data files for Jekyll.
 		err = c.ExportForJekyll()
 		if err != nil {
 			log.Errorf("Error while exporting data for Jekyll: %v", err)
 		}
-	},
+
+		return nil
+	}),
 }
 
-func getPAfromWhiteList(repoURL string, args []string) (pa crawler.PA) {
+// getPAfromWhiteList returns the publisher of repoURL in the whitelists, and
+// the options of the repository or of its organization.
+func getPAfromWhiteList(repoURL string, args []string) (pa crawler.PA, opts crawler.CrawlOptions, err error) {
 	// Read the supplied whitelists.
 	var publishers []crawler.PA
 	for id := range args {
 		readWhitelist, err := crawler.ReadAndParseWhitelist(args[id])
 		if err != nil {
-			log.Fatal(err)
+			return pa, opts, err
 		}
 		publishers = append(publishers, readWhitelist...)
 	}
@@ -58,18 +71,18 @@ func getPAfromWhiteList(repoURL string, args []string) (pa crawler.PA) {
 	for _, paWl := range publishers {
 		// looking into repositories
 		for _, paWlRepo := range paWl.Repositories {
-			log.Tracef("matching %s with %s", paWlRepo, repoURL)
-			if paWlRepo == repoURL {
+			log.Tracef("matching %s with %s", paWlRepo.URL, repoURL)
+			if paWlRepo.URL == repoURL {
 				log.Debugf("PA found in whitelist %+v", paWl)
-				return paWl
+				return paWl, paWlRepo.CrawlOptions, nil
 			}
 		}
 		// looking into organizations
-		for _, paWlRepo := range paWl.Organizations {
-			log.Tracef("matching %s.* with %s", paWlRepo, repoURL)
-			if matched, _ := regexp.MatchString(paWlRepo+".*", repoURL); matched {
+		for _, paWlOrg := range paWl.Organizations {
+			log.Tracef("matching %s.* with %s", paWlOrg.URL, repoURL)
+			if matched, _ := regexp.MatchString(paWlOrg.URL+".*", repoURL); matched {
 				log.Debugf("PA found in whitelist %+v", paWl)
-				return paWl
+				return paWl, paWlOrg.CrawlOptions, nil
 			}
 		}
 	}
@@ -79,5 +92,5 @@ func getPAfromWhiteList(repoURL string, args []string) (pa crawler.PA) {
 	// that is not aware about whitelists
 	// this hack will skip IPA code match with those lists
 	pa.UnknownIPA = true
-	return pa
+	return pa, opts, nil
 }
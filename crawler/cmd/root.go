@@ -1,16 +1,34 @@
 package cmd
 
 import (
+	"fmt"
+	"sync"
+
+	"github.com/italia/developers-italia-backend/crawler/metrics"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+func init() {
+	rootCmd.PersistentFlags().String("log-level", "info", "log level (trace, debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("log-format", "text", "log format (text, json)")
+	_ = viper.BindPFlag("LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	_ = viper.BindPFlag("LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+}
+
 var dryRun bool
 var rootCmd = &cobra.Command{
 	Use:   "crawler",
 	Short: "A crawler for publiccode.yml files.",
 	Long: `A fast and robust publiccode.yml file crawler.
 Complete documentation is available at https://github.com/italia/developers-italia-backend`,
+	// The errors are logged by Execute.
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configureLogging(viper.GetString("LOG_LEVEL"), viper.GetString("LOG_FORMAT"))
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		err := cmd.Help()
 		if err != nil {
@@ -19,6 +37,49 @@ Complete documentation is available at https://github.com/italia/developers-ital
 	},
 }
 
+// configureLogging sets the level and the format (text or json) of the logs.
+func configureLogging(level, format string) error {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(lvl)
+
+	switch format {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %s (available: text, json)", format)
+	}
+
+	return nil
+}
+
+var exportMetricsOnce sync.Once
+
+// exportMetrics exports the metrics at the end of the batch commands, once.
+func exportMetrics(cmd *cobra.Command) {
+	exportMetricsOnce.Do(func() {
+		if err := metrics.Export(cmd.Name()); err != nil {
+			log.Errorf("Error while exporting metrics: %v", err)
+		}
+	})
+}
+
+// withMetrics makes run export the metrics on every exit path: when it
+// returns, with or without an error, and when the crawler exits through
+// log.Fatal.
+func withMetrics(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log.RegisterExitHandler(func() { exportMetrics(cmd) })
+		defer exportMetrics(cmd)
+
+		return run(cmd, args)
+	}
+}
+
 // Execute is the entrypoint for cmd package Cobra.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -16,17 +16,19 @@ var updateIPACmd = &cobra.Command{
 	Use:   "updateipa",
 	Short: "Update data from IndicePA.",
 	Long:  `Download data from IndicePA and inject it into Elasticsearch.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: withMetrics(func(cmd *cobra.Command, args []string) error {
 		es, err := elastic.ClientFactory(
 			viper.GetString("ELASTIC_URL"),
 			viper.GetString("ELASTIC_USER"),
 			viper.GetString("ELASTIC_PWD"))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		err = ipa.UpdateFromIndicePA(es)
 		if err != nil {
 			log.Error(err)
 		}
-	}}
+
+		return nil
+	})}
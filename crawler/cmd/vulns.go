@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/italia/developers-italia-backend/crawler/crawler"
+	"github.com/italia/developers-italia-backend/crawler/osv"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(vulnsCmd)
+}
+
+var vulnsCmd = &cobra.Command{
+	Use:   "vulns [repo url]",
+	Short: "List the known vulnerabilities of the dependencies of [repo url].",
+	Long: `List the known vulnerabilities of the dependencies of the repository
+defined with [repo url], as found in the OSV database in OSV_DATADIR.
+The repository must have been crawled, since its clone is analyzed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		findings, err := crawler.RepoVulnerabilities(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(findings) == 0 {
+			fmt.Printf("No known vulnerabilities in the dependencies of %s\n", args[0])
+			return
+		}
+
+		// Prepare data table.
+		var data [][]string
+		for _, f := range findings {
+			id := f.ID
+			if len(f.Aliases) > 0 {
+				id += "\n" + strings.Join(f.Aliases, "\n")
+			}
+			data = append(data, []string{
+				f.Ecosystem, f.Package, f.Version, id, f.Severity, strings.Join(f.Fixed, ", "), f.Summary,
+			})
+		}
+		counts := osv.Count(findings)
+
+		// Write data and render as table in os.Stdout.
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Ecosystem", "Package", "Version", "ID", "Severity", "Fixed in", "Summary"})
+		table.SetFooter([]string{"Total vulnerabilities: " + strconv.Itoa(counts.Total), "", "", "", "", "", ""})
+		table.SetRowLine(true)
+		table.AppendBulk(data)
+		table.Render()
+	}}
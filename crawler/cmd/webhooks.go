@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/italia/developers-italia-backend/crawler/crawler"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var webhooksList bool
+
+func init() {
+	webhooksCmd.Flags().BoolVar(&webhooksList, "list", false, "list the deliveries in the outbox without sending them")
+	rootCmd.AddCommand(webhooksCmd)
+}
+
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Send the pending events to the webhook subscribers.",
+	Long: `Send the events in the outbox that are due to the subscribers in WEBHOOKS,
+retrying the deliveries failed by the previous runs.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		d := crawler.GetDispatcher()
+		if d == nil {
+			log.Info("No webhook subscribers configured")
+			return
+		}
+
+		if !webhooksList {
+			crawler.DeliverEvents()
+			return
+		}
+
+		deliveries, err := d.Outbox.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Prepare data table.
+		var data [][]string
+		for _, delivery := range deliveries {
+			data = append(data, []string{
+				delivery.Event.Time.Format("2006-01-02 15:04:05"),
+				delivery.Event.Type,
+				delivery.Subscriber,
+				strconv.Itoa(delivery.Attempts),
+				delivery.NextAttempt.Format("2006-01-02 15:04:05"),
+				delivery.LastError,
+			})
+		}
+
+		// Write data and render as table in os.Stdout.
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Time", "Event", "Subscriber", "Attempts", "Next attempt", "Last error"})
+		table.SetFooter([]string{"Deliveries: " + strconv.Itoa(len(deliveries)), "", "", "", "", ""})
+		table.AppendBulk(data)
+		table.Render()
+	}}
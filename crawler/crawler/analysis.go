@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/ipa"
+	"github.com/italia/developers-italia-backend/crawler/license"
+	"github.com/italia/developers-italia-backend/crawler/linkcheck"
+	"github.com/italia/developers-italia-backend/crawler/maturity"
+	"github.com/italia/developers-italia-backend/crawler/osv"
+	"github.com/italia/developers-italia-backend/crawler/sbom"
+	"github.com/italia/developers-italia-backend/crawler/tracing"
+	"github.com/italia/developers-italia-backend/crawler/worktree"
+	publiccode "github.com/italia/publiccode-parser-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// repoAnalysis is what was found in the clone of a repository, checking
+// the links in its publiccode.yml and looking up its publisher in IndicePA.
+type repoAnalysis struct {
+	licenses     license.Report
+	dependencies []sbom.Dependency
+	// vulnerabilities is nil if there is no OSV database.
+	vulnerabilities *osv.Counts
+	maturity        *maturity.Report
+	brokenLinks     []linkcheck.Result
+	// codiceIPASuggestions are the administrations that likely publish
+	// the repositories with an unknown iPA code.
+	codiceIPASuggestions []ipa.Match
+}
+
+// analyzeClone looks at the files in the clone of repository, walking its
+// working tree once: the licenses, the maturity checks, the dependencies
+// and their known vulnerabilities.
+func (c *Crawler) analyzeClone(repository Repository, parser *publiccode.Parser, span *tracing.Span, logger *log.Entry) repoAnalysis {
+	var analysis repoAnalysis
+	dir := clonePath(repository.Hostname, repository.Name)
+
+	walk := tracing.Start(span, "walk")
+	start := time.Now()
+	var headers license.HeaderScanner
+	extractor := sbom.NewExtractor(dir)
+	checker := maturity.NewChecker(dir)
+	err := worktree.Walk(dir, &headers, extractor, checker)
+	walk.SetError(err)
+	walk.End()
+	stageLogger := logger.WithFields(log.Fields{"stage": "walk", "duration": time.Since(start)})
+	if err != nil {
+		stageLogger.WithField("error", err).Error("error reading the clone")
+		return analysis
+	}
+
+	// Compare the licenses in the repository with legal.license.
+	licenses := tracing.Start(span, "license")
+	start = time.Now()
+	licenseReport, err := headers.Check(dir, parser.PublicCode.Legal.License)
+	licenses.SetError(err)
+	licenses.End()
+	stageLogger = logger.WithFields(log.Fields{"stage": "license", "duration": time.Since(start)})
+	if err != nil {
+		stageLogger.WithField("error", err).Error("error detecting the licenses")
+	} else if licenseReport.Mismatch {
+		stageLogger.WithFields(log.Fields{
+			"declared": licenseReport.Declared,
+			"detected": licenseReport.Detected,
+		}).Warn("the licenses in the repository don't match legal.license")
+	} else {
+		stageLogger.WithField("detected", licenseReport.Detected).Info("licenses detected in the repository")
+	}
+	analysis.licenses = licenseReport
+
+	// Check the practices of a mature project.
+	checks := tracing.Start(span, "maturity")
+	start = time.Now()
+	tags, err := repositoryTags(repository.Hostname, repository.Name)
+	if err == nil {
+		var report maturity.Report
+		report, err = checker.Check(tags, maturityWeights())
+		if err == nil {
+			analysis.maturity = &report
+		}
+	}
+	checks.SetError(err)
+	checks.End()
+	stageLogger = logger.WithFields(log.Fields{"stage": "maturity", "duration": time.Since(start)})
+	if err != nil {
+		stageLogger.WithField("error", err).Error("error checking the maturity")
+	} else {
+		stageLogger.WithField("missing", analysis.maturity.Missing()).Infof("maturity score: %d", analysis.maturity.Score)
+	}
+
+	// Extract the dependencies and write the SBOM next to the log.
+	dependencies := tracing.Start(span, "sbom")
+	start = time.Now()
+	components, err := extractor.Components()
+	// The SBOM is written even if some manifests are invalid.
+	if components != nil {
+		if werr := c.writeSBOM(repository, parser.PublicCode.Name, components); werr != nil {
+			err = werr
+		}
+	}
+	dependencies.SetError(err)
+	dependencies.End()
+	stageLogger = logger.WithFields(log.Fields{"stage": "sbom", "duration": time.Since(start)})
+	if err != nil {
+		stageLogger.WithField("error", err).Warn("error extracting the dependencies")
+	}
+	stageLogger.Infof("%d dependencies found", len(components))
+	analysis.dependencies = sbom.Summary(components)
+
+	// Match the dependencies with the known vulnerabilities, if there is
+	// a copy of the OSV database.
+	if db := GetOSVDatabase(); db != nil {
+		vulnerabilities := tracing.Start(span, "vulnerabilities")
+		start = time.Now()
+		counts := osv.Count(db.Check(components))
+		vulnerabilities.End()
+		stageLogger = logger.WithFields(log.Fields{"stage": "vulnerabilities", "duration": time.Since(start)})
+		if counts.Total > 0 {
+			stageLogger.WithFields(log.Fields{
+				"critical": counts.Critical,
+				"high":     counts.High,
+				"medium":   counts.Medium,
+				"low":      counts.Low,
+				"unknown":  counts.Unknown,
+			}).Warnf("%d known vulnerabilities in the dependencies", counts.Total)
+		} else {
+			stageLogger.Info("no known vulnerabilities in the dependencies")
+		}
+		analysis.vulnerabilities = &counts
+	}
+
+	return analysis
+}
@@ -8,9 +8,7 @@ import (
 	"path"
 	"time"
 
-	httpclient "github.com/italia/httpclient-lib-go"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
 // Bitbucket is the complete response for the Bitbucket all repositories list.
@@ -133,6 +131,10 @@ type BitbucketRepo struct {
 	Slug        string    `json:"slug"`
 	IsPrivate   bool      `json:"is_private"`
 	Description string    `json:"description"`
+	Parent      *struct {
+		FullName string `json:"full_name"`
+		Links    Links  `json:"links"`
+	} `json:"parent,omitempty"`
 }
 
 // Links is the list of Links associated to the repository.
@@ -181,7 +183,7 @@ type Links struct {
 
 // RegisterBitbucketAPI register the crawler function for Bitbucket API.
 func RegisterBitbucketAPI() OrganizationHandler {
-	return func(domain Domain, link string, repositories chan Repository, pa PA) (string, error) {
+	return func(domain Domain, link string, repositories chan Repository, pa PA, opts CrawlOptions) (string, error) {
 		// Set BasicAuth header.
 		headers := make(map[string]string)
 		if domain.BasicAuth != nil {
@@ -201,12 +203,12 @@ func RegisterBitbucketAPI() OrganizationHandler {
 		domain.Host = u.Hostname()
 
 		// Get List of repositories.
-		resp, err := httpclient.GetURL(link, headers)
+		resp, err := getURL(link, headers)
 		if err != nil {
 			return link, err
 		}
 		if resp.Status.Code != http.StatusOK {
-			log.Warnf("Request returned: %s", string(resp.Body))
+			log.WithFields(log.Fields{"domain": domain.Host, "status": resp.Status.Code}).Warnf("Request returned: %s", string(resp.Body))
 			return "", errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
 		}
 
@@ -219,18 +221,23 @@ func RegisterBitbucketAPI() OrganizationHandler {
 
 		// Add repositories to the channel that will perform the check on everyone.
 		for _, v := range result.Values {
+			// Bitbucket has no archived repositories.
+			if reason := opts.skip(v.Slug, v.Parent.FullName != "", false); reason != "" {
+				log.WithFields(log.Fields{"repo": v.FullName, "domain": domain.Host}).Warnf("Skipping repository: %s", reason)
+				continue
+			}
 
 			// Join file raw URL.
 			u, err := url.Parse(v.Links.HTML.Href)
 			if err != nil {
 				return link, err
 			}
-			u.Path = path.Join(u.Path, "raw", v.Mainbranch.Name, viper.GetString("CRAWLED_FILENAME"))
+			u.Path = path.Join(u.Path, "raw", opts.branch(v.Mainbranch.Name), opts.filename())
 
 			// Marshal all the repository metadata.
 			metadata, err := json.Marshal(v)
 			if err != nil {
-				log.Errorf("bitbucket metadata: %v", err)
+				log.WithField("domain", domain.Host).Errorf("bitbucket metadata: %v", err)
 			}
 
 			// If the repository was never used, the Mainbranch is empty ("").
@@ -240,11 +247,12 @@ func RegisterBitbucketAPI() OrganizationHandler {
 					Hostname:    u.Hostname(),
 					FileRawURL:  u.String(),
 					GitCloneURL: v.Links.Clone[0].Href,
-					GitBranch:   v.Mainbranch.Name,
+					GitBranch:   opts.branch(v.Mainbranch.Name),
 					Domain:      domain,
 					Pa:          pa,
 					Headers:     headers,
 					Metadata:    metadata,
+					Upstream:    upstreamURL(v.Parent.Links.HTML.Href),
 				}
 			}
 		}
@@ -261,7 +269,7 @@ func RegisterBitbucketAPI() OrganizationHandler {
 
 // RegisterSingleBitbucketAPI register the crawler function for single Bitbucket repository.
 func RegisterSingleBitbucketAPI() SingleRepoHandler {
-	return func(domain Domain, link string, repositories chan Repository, pa PA) error {
+	return func(domain Domain, link string, repositories chan Repository, pa PA, opts CrawlOptions) error {
 		// Set BasicAuth header
 		headers := make(map[string]string)
 		if domain.BasicAuth != nil {
@@ -287,12 +295,12 @@ func RegisterSingleBitbucketAPI() SingleRepoHandler {
 		linkRepo := u.String()
 
 		// Get single Repo
-		resp, err := httpclient.GetURL(linkRepo, headers)
+		resp, err := getURL(linkRepo, headers)
 		if err != nil {
 			return err
 		}
 		if resp.Status.Code != http.StatusOK {
-			log.Warnf("Request returned: %s", string(resp.Body))
+			log.WithFields(log.Fields{"domain": domain.Host, "status": resp.Status.Code}).Warnf("Request returned: %s", string(resp.Body))
 			return errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
 		}
 
@@ -308,24 +316,30 @@ func RegisterSingleBitbucketAPI() SingleRepoHandler {
 		if err != nil {
 			return err
 		}
-		fullURL := path.Join(u.Hostname(), result.FullName, "raw", result.Mainbranch.Name, viper.GetString("CRAWLED_FILENAME"))
+		fullURL := path.Join(u.Hostname(), result.FullName, "raw", opts.branch(result.Mainbranch.Name), opts.filename())
 
 		// Marshal all the repository metadata.
 		metadata, err := json.Marshal(result)
 		if err != nil {
-			log.Errorf("bitbucket metadata: %v", err)
+			log.WithField("domain", domain.Host).Errorf("bitbucket metadata: %v", err)
+		}
+		upstream := ""
+		if result.Parent != nil {
+			upstream = upstreamURL(result.Parent.Links.HTML.Href)
 		}
+
 		// If the repository was never used, the Mainbranch is empty ("").
 		if result.Mainbranch.Name != "" {
 			repositories <- Repository{
 				Name:       result.FullName,
 				Hostname:   u.Hostname(),
 				FileRawURL: "https://" + fullURL,
-				GitBranch:  result.Mainbranch.Name,
+				GitBranch:  opts.branch(result.Mainbranch.Name),
 				Domain:     domain,
 				Pa:         pa,
 				Headers:    headers,
 				Metadata:   metadata,
+				Upstream:   upstream,
 			}
 		} else {
 			return errors.New("repository is: empty")
@@ -367,7 +381,7 @@ func IsBitbucket(link string) bool {
 	u.Path = "2.0/hook_events"
 	u.Host = "api." + u.Host
 
-	resp, err := httpclient.GetURL(u.String(), nil)
+	resp, err := getURL(u.String(), nil)
 	if err != nil {
 		log.Debugf("can %s use Bitbucket API? No.", link)
 		return false
@@ -1,10 +1,15 @@
 package crawler
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 )
 
 // IsBitbucket returns "true" if the url can use Bitbucket API.
@@ -58,3 +63,30 @@ func TestGenerateBitbucketAPIURL(t *testing.T) {
 	}
 
 }
+
+// Forks are linked to their parent repository.
+func TestBitbucketForkUpstream(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("CRAWLED_FILENAME", "publiccode.yml")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2.0/repositories/pa" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"values": [{"slug": "fork", "full_name": "pa/fork", "mainbranch": {"name": "main"},
+			"links": {"html": {"href": "https://bitbucket.org/pa/fork"}, "clone": [{"href": "https://bitbucket.org/pa/fork.git"}]},
+			"parent": {"full_name": "other/original", "links": {"html": {"href": "https://bitbucket.org/other/original"}}}}]}`)
+	}))
+	defer server.Close()
+
+	repositories := make(chan Repository, 1)
+	handler := RegisterBitbucketAPI()
+	_, err := handler(Domain{Host: "bitbucket.org"}, server.URL+"/2.0/repositories/pa", repositories, PA{}, CrawlOptions{Forks: true})
+	assert.Nil(t, err)
+	close(repositories)
+
+	repo := <-repositories
+	assert.Equal(t, "pa/fork", repo.Name)
+	assert.Equal(t, "https://bitbucket.org/other/original", repo.Upstream)
+}
@@ -3,77 +3,154 @@ package crawler
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/italia/developers-italia-backend/crawler/jekyll"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
 )
 
-// Blacklist contain a list of blocked repositories.
-type Blacklist struct {
+// blacklistFile is the content of a blacklist file.
+type blacklistFile struct {
 	Repos []Repo `yaml:"repos"`
 }
 
-// Repo matches a single repository.
+// untilLayout is the format of the Until dates.
+const untilLayout = "2006-01-02"
+
+// Repo matches a single repository, all the repositories of an organization
+// (Org) or the repositories matching a glob (a URL with "*").
+// The entry is ignored after the Until date, if set.
 type Repo struct {
 	URL         string `yaml:"url"`
+	Org         string `yaml:"org"`
 	Reason      string `yaml:"reason"`
 	Description string `yaml:"description"`
+	Until       string `yaml:"until"`
 }
 
-// GetAllBlackListedRepos return all blacklisted repositories
-func GetAllBlackListedRepos() map[string]string {
-	files := viper.GetString("BLACKLIST_FOLDER")
-	pattern := viper.GetString("BLACKLIST_PATTERN")
-	if files == "" || pattern == "" {
-		log.Warn("BLACKLIST_* vars are not defined in config.toml, please define both")
-		return nil
-	}
+// Blacklist contains the blocked repositories.
+type Blacklist struct {
+	repos []Repo
+}
 
-	readBlacklist, err := scanBlacklists(files, pattern)
+// NewBlacklist returns a Blacklist of repos.
+func NewBlacklist(repos []Repo) *Blacklist {
+	return &Blacklist{repos: repos}
+}
+
+// LoadBlacklist reads the blacklist files matching pattern in dir. The
+// invalid files are logged and skipped.
+func LoadBlacklist(dir, pattern string) (*Blacklist, error) {
+	repos, err := scanBlacklists(dir, pattern)
 	if err != nil {
-		log.Errorf("path not exists or you don't have permission: %s", err)
-		return nil
+		return nil, err
 	}
-	var repoListed = make(map[string]string)
-	for _, repo := range readBlacklist {
-		repoListed[appendGitExt(repo.URL)] = repo.URL
+
+	return NewBlacklist(repos), nil
+}
+
+var (
+	blacklistOnce sync.Once
+	blacklist     *Blacklist
+)
+
+// GetBlacklist returns the blacklist in BLACKLIST_FOLDER, which is loaded once.
+// If BLACKLIST_FOLDER can't be read the crawler exits, rather than crawling
+// the blacklisted repositories.
+func GetBlacklist() *Blacklist {
+	blacklistOnce.Do(func() {
+		blacklist = NewBlacklist(nil)
+
+		files := viper.GetString("BLACKLIST_FOLDER")
+		pattern := viper.GetString("BLACKLIST_PATTERN")
+		if files == "" || pattern == "" {
+			log.Warn("BLACKLIST_* vars are not defined in config.toml, please define both")
+			return
+		}
+
+		b, err := LoadBlacklist(files, pattern)
+		if err != nil {
+			log.Fatalf("cannot load the blacklist: %v", err)
+		}
+		blacklist = b
+	})
+
+	return blacklist
+}
+
+// Repos returns all the entries of the blacklist, including the expired ones.
+func (b *Blacklist) Repos() []Repo {
+	return b.repos
+}
+
+// Match returns the entry blacklisting repoURL, or nil if it's not blacklisted.
+func (b *Blacklist) Match(repoURL string) *Repo {
+	now := time.Now()
+	u := jekyll.NormalizeURL(repoURL)
+
+	for i := range b.repos {
+		repo := &b.repos[i]
+		if repo.Expired(now) {
+			continue
+		}
+		if repo.matches(u) {
+			return repo
+		}
 	}
-	return repoListed
+
+	return nil
 }
 
-// IsRepoInBlackList checks whether a repo is in blacklist
-func IsRepoInBlackList(repoURL string) bool {
-	files := viper.GetString("BLACKLIST_FOLDER")
-	pattern := viper.GetString("BLACKLIST_PATTERN")
-	if files == "" || pattern == "" {
-		log.Warn("BLACKLIST_* var are not defined in config.toml, please define both")
+// Expired returns true if the entry isn't valid anymore at t.
+// The Until dates of the blacklist files are checked when they are loaded.
+func (r Repo) Expired(t time.Time) bool {
+	if r.Until == "" {
 		return false
 	}
 
-	readBlacklist, err := scanBlacklists(files, pattern)
+	until, err := time.Parse(untilLayout, r.Until)
 	if err != nil {
-		log.Errorf("path not exists or you don't have permission: %s", err)
 		return false
 	}
-	for _, repo := range readBlacklist {
-		if repo.URL == repoURL {
-			log.Warnf("PA found in blacklist with reason: "+
-				"%s and description: %s, skipping...", repo.Reason, repo.Description)
-			return true
-		}
+
+	// The entry is valid for the whole until day.
+	return !t.Before(until.AddDate(0, 0, 1))
+}
+
+// matches returns true if the entry matches the normalized URL u.
+func (r Repo) matches(u string) bool {
+	if r.Org != "" {
+		return strings.HasPrefix(u, jekyll.NormalizeURL(r.Org)+"/")
 	}
-	return false
+	if r.URL == "" {
+		return false
+	}
+
+	pattern := jekyll.NormalizeURL(r.URL)
+	if strings.Contains(pattern, "*") {
+		matched, err := path.Match(pattern, u)
+		return err == nil && matched
+	}
+
+	return pattern == u
 }
 
-func appendGitExt(repo string) string {
-	re := regexp.MustCompile(`\.git$`)
-	if re.MatchString(repo) {
-		return repo
+// IsRepoInBlackList checks whether a repo is in blacklist
+func IsRepoInBlackList(repoURL string) bool {
+	repo := GetBlacklist().Match(repoURL)
+	if repo == nil {
+		return false
 	}
-	return repo + ".git"
+
+	log.WithField("repo", repoURL).Warnf("Repository found in blacklist with reason: "+
+		"%s and description: %s, skipping...", repo.Reason, repo.Description)
+	return true
 }
 
 // ReadAndParseBlacklist read the blacklist and return the parsed content in a slice of PA.
@@ -101,20 +178,36 @@ func scanBlacklists(dir string, pattern string) ([]Repo, error) {
 	}
 	var repos []Repo
 	for _, file := range files {
-		blacklistSlice, _ := ReadAndParseBlacklist(file)
+		// An invalid file doesn't drop the entries of the other files.
+		blacklistSlice, err := ReadAndParseBlacklist(file)
+		if err != nil {
+			log.Errorf("skipping the blacklist file: %v", err)
+			continue
+		}
 		repos = append(repos, blacklistSlice...)
 	}
 	return repos, nil
 }
 
 // parseBlacklistFile parses the blacklist file to build a slice of Repo.
-func parseBlacklistFile(data []byte) (Blacklist, error) {
-	var blacklist Blacklist
+func parseBlacklistFile(data []byte) (blacklistFile, error) {
+	var blacklist blacklistFile
 
 	// Unmarshal the yml in domains list.
 	err := yaml.Unmarshal(data, &blacklist)
 	if err != nil {
-		return Blacklist{}, err
+		return blacklistFile{}, err
+	}
+
+	for _, repo := range blacklist.Repos {
+		if (repo.URL == "") == (repo.Org == "") {
+			return blacklistFile{}, fmt.Errorf("blacklist entry must have either url or org: %+v", repo)
+		}
+		if repo.Until != "" {
+			if _, err := time.Parse(untilLayout, repo.Until); err != nil {
+				return blacklistFile{}, fmt.Errorf("invalid blacklist until date %s, must be YYYY-MM-DD: %+v", repo.Until, repo)
+			}
+		}
 	}
 
 	return blacklist, err
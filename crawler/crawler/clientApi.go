@@ -2,6 +2,7 @@ package crawler
 
 import (
 	"fmt"
+	"strings"
 )
 
 // ClientAPI contains all the API function in a single Client.
@@ -13,10 +14,12 @@ type ClientAPI struct {
 }
 
 // OrganizationHandler returns the client handler for an organization/team/group page (every domain has a different handler implementation).
-type OrganizationHandler func(domain Domain, url string, repositories chan Repository, pa PA) (string, error)
+// The repositories are filtered and crawled according to opts.
+type OrganizationHandler func(domain Domain, url string, repositories chan Repository, pa PA, opts CrawlOptions) (string, error)
 
 // SingleRepoHandler returns the client handler for an a single repository (every domain has a different handler implementation).
-type SingleRepoHandler func(domain Domain, url string, repositories chan Repository, pa PA) error
+// The repository is crawled according to opts, whose Include, Exclude and Forks are ignored.
+type SingleRepoHandler func(domain Domain, url string, repositories chan Repository, pa PA, opts CrawlOptions) error
 
 // GeneratorAPIURL returns the url in the api correct ecosystem.
 type GeneratorAPIURL func(url string) ([]string, error)
@@ -78,3 +81,9 @@ func GetAPIURL(clientAPI string) (GeneratorAPIURL, error) {
 func GetClients() map[string]ClientAPI {
 	return clientAPIs
 }
+
+// upstreamURL returns the URL of a forked or mirrored repository, as reported
+// by the code hosting APIs, without the ".git" suffix.
+func upstreamURL(u string) string {
+	return strings.TrimSuffix(strings.TrimSpace(u), ".git")
+}
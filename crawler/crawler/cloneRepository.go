@@ -6,13 +6,16 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/italia/developers-italia-backend/crawler/metrics"
 	"github.com/spf13/viper"
 )
 
 // CloneRepository clone the repository into DATADIR/repos/<hostname>/<vendor>/<repo>/gitClone
-func CloneRepository(domain Domain, hostname, name, gitURL, gitBranch, index string) error {
+func CloneRepository(domain Domain, hostname, name, gitURL, gitBranch string) error {
 	if domain.Host == "" {
 		return errors.New("cannot save a file without domain host")
 	}
@@ -23,8 +26,15 @@ func CloneRepository(domain Domain, hostname, name, gitURL, gitBranch, index str
 		return errors.New("cannot clone a repository without git URL")
 	}
 
-	vendor, repo := splitFullName(name)
-	path := filepath.Join(viper.GetString("CRAWLER_DATADIR"), "repos", hostname, vendor, repo, "gitClone")
+	path := clonePath(hostname, name)
+
+	start := time.Now()
+	defer func() {
+		metrics.CloneDuration.Observe(time.Since(start).Seconds())
+		if size, err := repositorySize(path); err == nil {
+			metrics.CloneSize.Observe(float64(size))
+		}
+	}()
 
 	// If folder already exists it will do a fetch instead of a clone.
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
@@ -48,6 +58,63 @@ func CloneRepository(domain Domain, hostname, name, gitURL, gitBranch, index str
 		return errors.New(fmt.Sprintf("cannot git clone the repository: %s: %s", err.Error(), out))
 	}
 
-	metrics.GetCounter("repository_cloned", index).Inc()
 	return err
 }
+
+// clonePath returns the path of the clone of the repository name.
+func clonePath(hostname, name string) string {
+	vendor, repo := splitFullName(name)
+
+	return filepath.Join(viper.GetString("CRAWLER_DATADIR"), "repos", hostname, vendor, repo, "gitClone")
+}
+
+// repositorySize returns the size of the git objects in the clone in path,
+// without walking the working tree.
+func repositorySize(path string) (int64, error) {
+	// Command is: git count-objects -v
+	out, err := exec.Command("git", "-C", path, "count-objects", "-v").CombinedOutput() // nolint: gas
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("cannot get the size of the repository: %s: %s", err.Error(), out))
+	}
+
+	var size int64
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (fields[0] != "size:" && fields[0] != "size-pack:") {
+			continue
+		}
+		kib, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		size += kib * 1024
+	}
+
+	return size, nil
+}
+
+// repositoryHead returns the SHA of the commit checked out in the clone of the repository.
+func repositoryHead(hostname, name string) (string, error) {
+	path := clonePath(hostname, name)
+
+	// Command is: git rev-parse HEAD
+	out, err := exec.Command("git", "-C", path, "rev-parse", "HEAD").CombinedOutput() // nolint: gas
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("cannot get the commit of the repository: %s: %s", err.Error(), out))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// repositoryTags returns the tags in the clone of the repository.
+func repositoryTags(hostname, name string) ([]string, error) {
+	path := clonePath(hostname, name)
+
+	// Command is: git tag --list
+	out, err := exec.Command("git", "-C", path, "tag", "--list").CombinedOutput() // nolint: gas
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("cannot get the tags of the repository: %s: %s", err.Error(), out))
+	}
+
+	return strings.Fields(string(out)), nil
+}
@@ -8,19 +8,25 @@ import (
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/italia/developers-italia-backend/crawler/elastic"
+	"github.com/italia/developers-italia-backend/crawler/history"
 	"github.com/italia/developers-italia-backend/crawler/ipa"
-	"github.com/italia/developers-italia-backend/crawler/jekyll"
+	"github.com/italia/developers-italia-backend/crawler/linkcheck"
 	"github.com/italia/developers-italia-backend/crawler/metrics"
-	httpclient "github.com/italia/httpclient-lib-go"
+	"github.com/italia/developers-italia-backend/crawler/notify"
+	"github.com/italia/developers-italia-backend/crawler/sbom"
+	"github.com/italia/developers-italia-backend/crawler/tracing"
+	"github.com/italia/developers-italia-backend/crawler/webhooks"
 	publiccode "github.com/italia/publiccode-parser-go"
 	es "github.com/olivere/elastic"
 	log "github.com/sirupsen/logrus"
@@ -38,6 +44,9 @@ type Crawler struct {
 	repositories   chan Repository
 	publishersWg   sync.WaitGroup
 	repositoriesWg sync.WaitGroup
+
+	// Root span of the trace of the crawl.
+	trace *tracing.Span
 }
 
 // Repository is a single code repository. FileRawURL contains the direct url to the raw file.
@@ -51,6 +60,8 @@ type Repository struct {
 	Pa          PA
 	Headers     map[string]string
 	Metadata    []byte
+	// Upstream is the URL of the repository forked or mirrored by this one.
+	Upstream string
 }
 
 // NewCrawler initializes a new Crawler object, updates the IPA list and connects to Elasticsearch (if dryRun == false).
@@ -74,12 +85,7 @@ func NewCrawler(dryRun bool) *Crawler {
 	// Initiate a channel of repositories.
 	c.repositories = make(chan Repository, 1000)
 
-	// Register Prometheus metrics.
-	metrics.RegisterPrometheusCounter("repository_processed", "Number of repository processed.", c.index)
-	metrics.RegisterPrometheusCounter("repository_file_saved", "Number of file saved.", c.index)
-	metrics.RegisterPrometheusCounter("repository_file_indexed", "Number of file indexed.", c.index)
-	metrics.RegisterPrometheusCounter("repository_cloned", "Number of repository cloned", c.index)
-	//metrics.RegisterPrometheusCounter("repository_file_saved_valid", "Number of valid file saved.", c.index)
+	tracing.Init()
 
 	if c.DryRun {
 		log.Info("Skipping ElasticSearch update (--dry-run)")
@@ -109,6 +115,12 @@ func NewCrawler(dryRun bool) *Crawler {
 		log.Fatal(err)
 	}
 
+	// Create ES index of the publiccode.yml versions.
+	err = elastic.CreateIndexMapping(viper.GetString("ELASTIC_HISTORY_INDEX"), elastic.HistoryMapping, c.es)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Create ES index with mapping "administration-codiceIPA".
 	err = elastic.CreateIndexMapping(viper.GetString("ELASTIC_PUBLISHERS_INDEX"), elastic.AdministrationsMapping, c.es)
 	if err != nil {
@@ -118,9 +130,21 @@ func NewCrawler(dryRun bool) *Crawler {
 	return &c
 }
 
-// CrawlRepo crawls a single repository.
-func (c *Crawler) CrawlRepo(repoURL string, pa PA) error {
-	log.Infof("Processing repository: %s", repoURL)
+// CrawlRepo crawls a single repository according to opts.
+func (c *Crawler) CrawlRepo(repoURL string, pa PA, opts CrawlOptions) (err error) {
+	publisherLogger(pa).WithField("repo", repoURL).Info("Processing repository")
+
+	c.trace = tracing.Start(nil, "CrawlRepo")
+	c.trace.SetAttribute("repository.url", repoURL)
+	// The trace is also exported if the repository is not crawled.
+	// Ending it again after crawl has no effect.
+	defer func() {
+		c.trace.SetError(err)
+		c.trace.End()
+		if err := tracing.Flush(); err != nil {
+			log.Errorf("Error exporting traces: %v", err)
+		}
+	}()
 
 	// Check if current host is in known in domains.yml hosts.
 	domain, err := c.KnownHost(repoURL)
@@ -129,15 +153,20 @@ func (c *Crawler) CrawlRepo(repoURL string, pa PA) error {
 	}
 
 	// Process repository.
-	err = domain.processSingleRepo(repoURL, c.repositories, pa)
+	err = domain.processSingleRepo(repoURL, c.repositories, pa, opts)
 	if err != nil {
 		return err
 	}
 	close(c.repositories)
-	return c.crawl()
+
+	// The repository is checked against the blacklist by the "one" command.
+	_, err = c.crawl(NewBlacklist(nil))
+	return err
 }
 
 // CrawlPublishers processes a list of publishers.
+// It returns the URLs of the blacklisted repositories, ready to be removed
+// from Elasticsearch.
 func (c *Crawler) CrawlPublishers(publishers []PA) ([]string, error) {
 	// Count configured orgs
 	orgCount := 0
@@ -147,6 +176,10 @@ func (c *Crawler) CrawlPublishers(publishers []PA) ([]string, error) {
 	log.Infof("%v organizations belonging to %v publishers are going to be scanned",
 		orgCount, len(publishers))
 
+	c.trace = tracing.Start(nil, "CrawlPublishers")
+	c.trace.SetAttribute("publishers", strconv.Itoa(len(publishers)))
+	c.trace.SetAttribute("organizations", strconv.Itoa(orgCount))
+
 	// Process every item in publishers.
 	for _, pa := range publishers {
 		c.publishersWg.Add(1)
@@ -159,43 +192,41 @@ func (c *Crawler) CrawlPublishers(publishers []PA) ([]string, error) {
 		close(c.repositories)
 	}()
 
-	// here we got all repos to be scanned
-	// it's time to check blacklist and wheter one of them
-	// is listed.
-	// we should return the ones listed to crawl command
-	// and call deleteFromES if present
-	toBeRemoved := c.removeBlackListedFromRepositories(GetAllBlackListedRepos())
-
-	return toBeRemoved, c.crawl()
+	return c.crawl(GetBlacklist())
 }
 
-// removeBlackListedFromRepositories this function is in charge
-// to discard repositories in blacklists.
-// It returns a slice of them, ready to be removed
-// from elasticsearch.
-func (c *Crawler) removeBlackListedFromRepositories(listedRepos map[string]string) (toBeRemoved []string) {
-	temp := make(chan Repository, 1000)
+// filterBlacklisted sends the repositories to out as they are found, leaving
+// out the ones in blacklist, until the repositories channel is closed.
+// It returns the URLs of the blacklisted repositories.
+func (c *Crawler) filterBlacklisted(blacklist *Blacklist, out chan<- Repository) (blacklisted []string) {
 	for repo := range c.repositories {
-		if val, ok := listedRepos[repo.GitCloneURL]; ok {
-			// add repository that should be processed but
-			// they are marked as blacklisted
-			// and then ready to be removed from ES if they exist
-			toBeRemoved = append(toBeRemoved, val)
-			log.Warnf("marked as blacklisted %s", val)
-		} else {
-			temp <- repo
+		if entry := blacklist.Match(repo.GitCloneURL); entry != nil {
+			repoURL := strings.TrimSuffix(repo.GitCloneURL, ".git")
+			blacklisted = append(blacklisted, repoURL)
+			log.WithFields(log.Fields{"repo": repoURL, "reason": entry.Reason}).Warn("marked as blacklisted")
+			continue
 		}
+		out <- repo
 	}
-	close(temp)
-	c.repositories = temp
+
 	return
 }
 
-func (c *Crawler) crawl() error {
+// crawl processes the repositories as they are found, skipping the ones in
+// blacklist. It returns the URLs of the skipped repositories.
+func (c *Crawler) crawl(blacklist *Blacklist) ([]string, error) {
 	reposChan := make(chan Repository)
 
+	// End the trace of the crawl and export it.
+	defer func() {
+		c.trace.End()
+		if err := tracing.Flush(); err != nil {
+			log.Errorf("Error exporting traces: %v", err)
+		}
+	}()
+
 	// Start the metrics server.
-	go metrics.StartPrometheusMetricsServer()
+	metrics.StartPrometheusMetricsServer()
 
 	defer c.publishersWg.Wait()
 
@@ -208,18 +239,24 @@ func (c *Crawler) crawl() error {
 		go c.ProcessRepositories(reposChan)
 	}
 
-	for repo := range c.repositories {
-		reposChan <- repo
-	}
+	// The publishers are still being crawled while the workers process the
+	// repositories found so far.
+	toBeRemoved := c.filterBlacklisted(blacklist, reposChan)
 	close(reposChan)
 	c.repositoriesWg.Wait()
 
+	// Keep the results of the link checks for the next crawls.
+	saveLinkCache()
+
 	if c.DryRun {
 		log.Info("Skipping ElasticSearch indexes update (--dry-run)")
 
-		return nil
+		return toBeRemoved, nil
 	}
 
+	// Keep the issues found for the notifications to the administrations.
+	saveReports()
+
 	// ElasticFlush to flush all the operations on ES.
 	err := elastic.Flush(c.index, c.es)
 	if err != nil {
@@ -229,69 +266,83 @@ func (c *Crawler) crawl() error {
 	// Update Elastic alias.
 	err = elastic.AliasUpdate(viper.GetString("ELASTIC_PUBLISHERS_INDEX"), viper.GetString("ELASTIC_ALIAS"), c.es)
 	if err != nil {
-		return fmt.Errorf("Error updating Elastic Alias: %v", err)
+		return toBeRemoved, fmt.Errorf("Error updating Elastic Alias: %v", err)
 	}
 	err = elastic.AliasUpdate(c.index, viper.GetString("ELASTIC_ALIAS"), c.es)
 	if err != nil {
-		return fmt.Errorf("Error updating Elastic Alias: %v", err)
+		return toBeRemoved, fmt.Errorf("Error updating Elastic Alias: %v", err)
 	}
 
-	return nil
+	metrics.LastSuccessfulCrawl.SetToCurrentTime()
+
+	return toBeRemoved, nil
 }
 
 // ExportForJekyll exports YAML data files for the Jekyll website.
 func (c *Crawler) ExportForJekyll() error {
-	if c.DryRun {
-		log.Info("Skipping YAML output (--dry-run)")
-		return nil;
-	}
-
-	return jekyll.GenerateJekyllYML(c.es)
+	return c.Export("yaml")
 }
 
 // CrawlPublisher delegates the work to single PA crawlers.
 func (c *Crawler) CrawlPublisher(pa PA) {
-	log.Infof("Processing publisher: %s", pa.Name)
+	logger := publisherLogger(pa)
+	logger.Info("Processing publisher")
 	defer c.publishersWg.Done()
 
-	for _, orgURL := range pa.Organizations {
+	span := tracing.Start(c.trace, "CrawlPublisher")
+	span.SetAttribute("publisher.name", pa.Name)
+	span.SetAttribute("publisher.codiceIPA", pa.CodiceIPA)
+	defer span.End()
+
+	for _, org := range pa.Organizations {
 		// Check if host is in list of known code hosting domains
-		domain, err := c.KnownHost(orgURL)
+		domain, err := c.KnownHost(org.URL)
 		if err != nil {
-			log.Error(err)
+			logger.WithField("org", org.URL).Error(err)
 		}
 
 		// Process the organization
-		c.CrawlOrg(orgURL, domain, pa)
+		c.CrawlOrg(org, domain, pa, span)
 	}
 
-	for _, repoURL := range pa.Repositories {
+	for _, repo := range pa.Repositories {
 		// Check if host is in list of known code hosting domains
-		domain, err := c.KnownHost(repoURL)
+		domain, err := c.KnownHost(repo.URL)
 		if err != nil {
-			log.Error(err)
+			logger.WithField("repo", repo.URL).Error(err)
 		}
 
-		domain.processSingleRepo(repoURL, c.repositories, pa)
+		domain.processSingleRepo(repo.URL, c.repositories, pa, repo.CrawlOptions)
 	}
 }
 
-// CrawlOrg fetches all the repositories belonging to an org and crawls them.
-func (c *Crawler) CrawlOrg(orgURL string, domain *Domain, pa PA) {
-	orgURLs, err := domain.generateAPIURLs(orgURL)
+// CrawlOrg fetches the repositories belonging to an org and crawls them,
+// according to the options of the org.
+// Every page of the org is traced as a child of the parent span.
+func (c *Crawler) CrawlOrg(org Org, domain *Domain, pa PA, parent *tracing.Span) {
+	logger := publisherLogger(pa).WithFields(log.Fields{"org": org.URL, "domain": domain.Host, "stage": "org"})
+
+	orgURLs, err := domain.generateAPIURLs(org.URL)
 	if err != nil {
-		log.Errorf("generateAPIURLs error: %v", err)
+		logger.Errorf("generateAPIURLs error: %v", err)
 	}
 
 ORG:
 	for _, orgURL := range orgURLs {
 		// Process the pages until the end is reached.
 		for {
-			nextURL, err := domain.processAndGetNextURL(orgURL, c.repositories, pa)
+			span := tracing.Start(parent, "processAndGetNextURL")
+			span.SetAttribute("url", orgURL)
+			start := time.Now()
+			nextURL, err := domain.processAndGetNextURL(orgURL, c.repositories, pa, org.CrawlOptions)
+			span.SetError(err)
+			span.End()
+			pageLogger := logger.WithFields(log.Fields{"url": orgURL, "duration": time.Since(start)})
 			if err != nil {
-				log.Errorf("error reading %s repository list: %v; nextURL: %v", orgURL, err, nextURL)
+				pageLogger.WithField("nextURL", nextURL).Errorf("error reading repository list: %v", err)
 				continue ORG
 			}
+			pageLogger.Debug("repository list read")
 
 			// If end is reached or fails, nextURL is empty.
 			if nextURL == "" {
@@ -319,26 +370,17 @@ func (c *Crawler) ProcessRepositories(repos chan Repository) {
 	}
 }
 
-type logEntry struct {
-	Datetime string `json:"datetime"`
-	Message  string `json:"message"`
-}
-
-func addLogEntry(logEntries *[]logEntry, message string) {
-	*logEntries = append(
-		*logEntries,
-		logEntry{Datetime: time.Now().UTC().Format(time.RFC3339), Message: message},
-	)
-}
-
 // ProcessRepo looks for a publiccode.yml file in a repository, and if found it processes it.
 func (c *Crawler) ProcessRepo(repository Repository) {
-	var logEntries []logEntry
+	span := tracing.Start(c.trace, "ProcessRepo")
+	span.SetAttribute("repository.name", repository.Name)
+	span.SetAttribute("repository.host", repository.Hostname)
+	defer span.End()
 
-	var message string = ""
+	logger, repoLog := newRepoLogger(repository, span.TraceID())
 
 	// Write the log to a file, so it can be accessed from outside at
-	// http://crawler-host/$codehosting/$org/$reponame/log.txt
+	// http://crawler-host/$codehosting/$org/$reponame/log.json
 	defer func() {
 		fname := path.Join(
 			viper.GetString("OUTPUT_DIR"),
@@ -348,76 +390,146 @@ func (c *Crawler) ProcessRepo(repository Repository) {
 		)
 
 		if err := os.MkdirAll(filepath.Dir(fname), 0775); err != nil {
-			log.Errorf("[%s]: %s", repository.Name, err.Error())
+			log.WithField("repo", repository.Name).Error(err)
 
 			return
 		}
 
-		jsonOut, _ := json.Marshal(logEntries)
+		jsonOut, _ := repoLog.JSON()
 		if err := ioutil.WriteFile(fname, jsonOut, 0644); err != nil {
-			log.Errorf("[%s]: %s", repository.Name, err.Error())
+			log.WithField("repo", repository.Name).Error(err)
 
 			return
 		}
 	}()
 
-	// Increment counter for the number of repositories processed.
-	metrics.GetCounter("repository_processed", c.index).Inc()
+	// Count the repositories processed by outcome.
+	outcome := metrics.OutcomeError
+	defer func() {
+		span.SetAttribute("outcome", outcome)
+		metrics.Repositories.WithLabelValues(
+			outcome,
+			repository.Domain.Host,
+			strings.ToLower(repository.Pa.CodiceIPA),
+		).Inc()
+	}()
 
-	resp, err := httpclient.GetURL(repository.FileRawURL, repository.Headers)
+	fetch := tracing.Start(span, "fetch")
+	start := time.Now()
+	resp, err := getURL(repository.FileRawURL, repository.Headers)
+	fetch.SetAttribute("http.status_code", strconv.Itoa(resp.Status.Code))
+	fetch.SetError(err)
+	fetch.End()
+	stageLogger := logger.WithFields(log.Fields{"stage": "fetch", "duration": time.Since(start)})
 
 	if resp.Status.Code != http.StatusOK || err != nil {
-		message = fmt.Sprintf("[%s] Failed to GET publiccode.yml\n", repository.Name)
-		log.Errorf(message)
+		if resp.Status.Code == http.StatusNotFound {
+			outcome = metrics.OutcomeNotFound
+		}
 
-		addLogEntry(&logEntries, message)
+		stageLogger.WithField("status", resp.Status.Code).Error("Failed to GET publiccode.yml")
 		return
 	}
 
-	message = fmt.Sprintf("[%s] publiccode.yml found at %s\n", repository.Name, repository.FileRawURL)
-	log.Infof(message)
-	addLogEntry(&logEntries, message)
+	stageLogger.Infof("publiccode.yml found at %s", repository.FileRawURL)
+
+	// Record the issues to notify to the administration. The publisher of
+	// the repositories with an unknown iPA code is not verified, so they
+	// are not notified.
+	var issues []notify.Issue
+	defer func() {
+		if !c.DryRun && !repository.Pa.UnknownIPA {
+			recordReport(repository, repository.Pa.CodiceIPA, issues)
+		}
+	}()
 
 	// Validate the publiccode.yml
+	validate := tracing.Start(span, "validate")
+	start = time.Now()
+	stageLogger = logger.WithField("stage", "validate")
+	var parser publiccode.Parser
+	var suggestions []ipa.Match
 	if repository.Pa.UnknownIPA {
-		message = fmt.Sprintf(
-			"[%s] When UnknownIPA is set to true IPA match with whitelists will be skipped\n",
-			repository.Name,
-		)
+		stageLogger.Warn("When UnknownIPA is set to true IPA match with whitelists will be skipped")
 
-		log.Warn(message)
-		addLogEntry(&logEntries, message)
+		// Parse errors are ignored here, we just need some hints
+		// about the publisher.
+		parser, _ = getRemoteFile(resp.Body, repository.FileRawURL, repository.Pa, repository.Domain)
+		if !ipa.Exists(parser.PublicCode.It.Riuso.CodiceIPA) {
+			suggestions = suggestCodiceIPA(repository, parser, stageLogger)
+		}
 	} else {
-		err = validateRemoteFile(resp.Body, repository.FileRawURL, repository.Pa, repository.Domain)
+		parser, err = getRemoteFile(resp.Body, repository.FileRawURL, repository.Pa, repository.Domain)
+		if err == nil {
+			err = validateFile(repository.Pa, parser, repository.FileRawURL)
+			if err != nil {
+				outcome = metrics.OutcomeIPAMismatch
+				issue := notify.Issue{
+					Kind:   notify.CodiceIPAMismatch,
+					URL:    repositoryURL(repository),
+					Detail: parser.PublicCode.It.Riuso.CodiceIPA,
+				}
+				for _, match := range suggestCodiceIPA(repository, parser, stageLogger) {
+					issue.Suggestions = append(issue.Suggestions, match.CodiceIPA)
+				}
+				issues = append(issues, issue)
+			}
+		} else {
+			outcome = metrics.OutcomeInvalid
+			issues = append(issues, notify.Issue{
+				Kind:   notify.InvalidPubliccode,
+				URL:    repositoryURL(repository),
+				Detail: err.Error(),
+			})
+		}
 		if err != nil {
-			message = fmt.Sprintf("[%s] BAD publiccode.yml: %+v\n", repository.Name, err)
-			log.Errorf(message)
-			addLogEntry(&logEntries, message)
+			stageLogger.WithFields(log.Fields{"duration": time.Since(start), "error": err}).Error("BAD publiccode.yml")
 
 			if ! c.DryRun {
 				logBadYamlToFile(repository.FileRawURL)
+
+				// Notify the subscribers once, not at every crawl.
+				if !reported(repository, issues[0]) {
+					emitEvent(webhooks.SoftwareInvalid, softwareEvent{
+						URL:        repositoryURL(repository),
+						CodiceIPA:  repository.Pa.CodiceIPA,
+						FileRawURL: repository.FileRawURL,
+						Error:      err.Error(),
+					})
+				}
 			}
 
+			validate.SetError(err)
+			validate.End()
 			return
 		}
 	}
+	validate.End()
 
-	message = fmt.Sprintf("[%s] GOOD publiccode.yml\n", repository.Name)
-	log.Infof(message)
-	addLogEntry(&logEntries, message)
+	stageLogger.WithField("duration", time.Since(start)).Info("GOOD publiccode.yml")
 
 	if c.DryRun {
-		log.Infof("[%s]: Skipping repository clone and save to ElasticSearch (--dry-run)", repository.Name)
+		outcome = metrics.OutcomeValid
+		logger.Info("Skipping repository clone and save to ElasticSearch (--dry-run)")
 		return;
 	}
 
 	// Clone repository.
-	err = CloneRepository(repository.Domain, repository.Hostname, repository.Name, repository.GitCloneURL, repository.GitBranch, c.index)
+	clone := tracing.Start(span, "clone")
+	start = time.Now()
+	stageLogger = logger.WithField("stage", "clone")
+	err = CloneRepository(repository.Domain, repository.Hostname, repository.Name, repository.GitCloneURL, repository.GitBranch)
 	if err != nil {
-		message = fmt.Sprintf("[%s] error while cloning: %v\n", repository.Name, err)
-		log.Errorf(message)
+		stageLogger.WithFields(log.Fields{"duration": time.Since(start), "error": err}).Error("error while cloning")
+	}
 
-		addLogEntry(&logEntries, message)
+	clone.SetError(err)
+	clone.End()
+
+	// Get the commit the publiccode.yml was found at.
+	commit, err := repositoryHead(repository.Hostname, repository.Name)
+	if err != nil {
+		stageLogger.WithField("error", err).Error("error getting the commit")
 	}
 
 	// Calculate Repository activity index and vitality. Defaults to 60 days.
@@ -425,52 +537,142 @@ func (c *Crawler) ProcessRepo(repository Repository) {
 	if viper.IsSet("ACTIVITY_DAYS") {
 		activityDays = viper.GetInt("ACTIVITY_DAYS")
 	}
+	activity := tracing.Start(span, "activity")
+	start = time.Now()
 	activityIndex, vitality, err := repository.CalculateRepoActivity(activityDays)
+	metrics.VitalityDuration.Observe(time.Since(start).Seconds())
+	activity.SetError(err)
+	activity.End()
+	stageLogger = logger.WithFields(log.Fields{"stage": "activity", "duration": time.Since(start)})
 	if err != nil {
-		message = fmt.Sprintf("[%s] error calculating activity index: %v\n", repository.Name, err)
-
-		log.Errorf(message)
-		addLogEntry(&logEntries, message)
+		stageLogger.WithField("error", err).Error("error calculating activity index")
 	}
-	message = fmt.Sprintf("[%s] activity index in the last %d days: %f\n", repository.Name, activityDays, activityIndex)
-	log.Infof(message)
-	addLogEntry(&logEntries, message)
+	stageLogger.Infof("activity index in the last %d days: %f", activityDays, activityIndex)
 
 	var vitalitySlice []int
 	for i := 0; i < len(vitality); i++ {
 		vitalitySlice = append(vitalitySlice, int(vitality[i]))
 	}
 
+	// What is found in the clone is saved with the publiccode.yml.
+	analysis := c.analyzeClone(repository, &parser, span, logger)
+	analysis.codiceIPASuggestions = suggestions
+
+	// Check the links and the assets in the publiccode.yml.
+	links := tracing.Start(span, "links")
+	start = time.Now()
+	results := GetLinkChecker().Check(linkcheck.Links(parser.PublicCode, parser.RemoteBaseURL))
+	links.End()
+	stageLogger = logger.WithFields(log.Fields{"stage": "links", "duration": time.Since(start)})
+	analysis.brokenLinks = linkcheck.Broken(results)
+	for _, r := range analysis.brokenLinks {
+		stageLogger.WithFields(log.Fields{
+			"field":  r.Field,
+			"url":    r.URL,
+			"status": r.Status,
+			"error":  r.Error,
+		}).Warnf("broken link in %s", r.Field)
+		issues = append(issues, notify.Issue{
+			Kind:   notify.BrokenLink,
+			URL:    repositoryURL(repository),
+			Detail: r.URL + " (" + r.Field + ")",
+		})
+	}
+	stageLogger.Infof("%d links checked, %d broken", len(results), len(analysis.brokenLinks))
+
 	// Save to ES.
-	err = c.saveToES(repository, activityIndex, vitalitySlice, resp.Body)
+	save := tracing.Start(span, "save")
+	defer save.End()
+	start = time.Now()
+	stageLogger = logger.WithField("stage", "save")
+	err = c.saveToES(repository, activityIndex, vitalitySlice, commit, analysis, resp.Body)
+	save.SetError(err)
 	if err != nil {
-		message = fmt.Sprintf("[%s] error saving to ElasticSearch: %v\n", repository.Name, err)
-		log.Errorf(message)
+		stageLogger.WithFields(log.Fields{"duration": time.Since(start), "error": err}).Error("error saving to ElasticSearch")
+		return
+	}
+	outcome = metrics.OutcomeIndexed
+
+	// Write the history of the publiccode.yml next to the log.
+	err = c.writeHistory(repository)
+	if err != nil {
+		stageLogger.WithField("error", err).Error("error writing the history")
+	}
+	stageLogger.WithField("duration", time.Since(start)).Debug("saved to ElasticSearch")
+}
 
-		addLogEntry(&logEntries, message)
+// writeSBOM writes the CycloneDX SBOM of the software name, made of the
+// components in its repository, next to the log.
+func (c *Crawler) writeSBOM(repository Repository, name string, components []sbom.Component) error {
+	fname := path.Join(
+		viper.GetString("OUTPUT_DIR"),
+		repository.Hostname,
+		path.Clean(repository.Name),
+		"sbom.json",
+	)
+	if err := os.MkdirAll(filepath.Dir(fname), 0775); err != nil {
+		return err
+	}
+
+	jsonOut, err := json.MarshalIndent(sbom.NewBOM(name, strings.TrimSuffix(repository.GitCloneURL, ".git"), components, time.Now()), "", "  ")
+	if err != nil {
+		return err
 	}
+
+	return ioutil.WriteFile(fname, jsonOut, 0644)
 }
 
-func validateRemoteFile(data []byte, fileRawURL string, pa PA, domain Domain) error {
-	parser, err := getRemoteFile(data, fileRawURL, pa, domain)
+// writeHistory writes the versions of the publiccode.yml of repository, with
+// their changes, to OUTPUT_DIR/<hostname>/<vendor>/<repo>/history.json.
+func (c *Crawler) writeHistory(repository Repository) error {
+	versions, err := history.ListSoftware(repository.generateID(), viper.GetString("ELASTIC_HISTORY_INDEX"), c.es)
 	if err != nil {
 		return err
 	}
-	return validateFile(pa, parser, fileRawURL)
+
+	fname := path.Join(
+		viper.GetString("OUTPUT_DIR"),
+		repository.Hostname,
+		path.Clean(repository.Name),
+		"history.json",
+	)
+	if err := os.MkdirAll(filepath.Dir(fname), 0775); err != nil {
+		return err
+	}
+
+	jsonOut, err := json.Marshal(history.Entries(versions, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fname, jsonOut, 0644)
 }
 
 func getRemoteFile(data []byte, fileRawURL string, pa PA, domain Domain) (publiccode.Parser, error) {
 	parser := publiccode.NewParser()
 	parser.Strict = false
-	parser.RemoteBaseURL = strings.TrimRight(fileRawURL, viper.GetString("CRAWLED_FILENAME"))
+	parser.RemoteBaseURL = remoteBaseURL(fileRawURL)
 	err := parser.ParseInDomain(data, domain.Host, domain.UseTokenFor, domain.BasicAuth)
 	if err != nil {
-		log.Errorf("Error parsing publiccode.yml for %s.", fileRawURL)
+		log.WithFields(log.Fields{"url": fileRawURL, "error": err}).Error("Error parsing publiccode.yml")
 		return *parser, err
 	}
 	return *parser, nil
 }
 
+// remoteBaseURL returns the URL of the directory of the publiccode.yml at
+// fileRawURL, which the relative paths in the file are resolved against.
+func remoteBaseURL(fileRawURL string) string {
+	u, err := url.Parse(fileRawURL)
+	if err != nil {
+		return fileRawURL
+	}
+	u.Path = strings.TrimSuffix(path.Dir(u.Path), "/") + "/"
+	u.RawPath, u.RawQuery, u.Fragment = "", "", ""
+
+	return u.String()
+}
+
 // validateFile will check if codiceIPA match
 // with relative entry in whitelist.
 // Using `one` command this check will be skipped.
@@ -484,3 +686,31 @@ func validateFile(pa PA, parser publiccode.Parser, fileRawURL string) error {
 
 	return nil
 }
+
+// suggestCodiceIPA looks up IndicePA for the administrations that likely
+// publish the repository, and adds them to the repository log. They are also
+// saved with the software or notified with the codiceIPA mismatch, so that
+// curators and publishers can fix the whitelists or the publiccode.yml file.
+func suggestCodiceIPA(repository Repository, parser publiccode.Parser, logger *log.Entry) []ipa.Match {
+	vendor, _ := splitFullName(repository.Name)
+	pc := parser.PublicCode
+
+	hints := ipa.MatchHints{
+		Names:     []string{vendor, repository.Pa.Name, pc.Legal.RepoOwner, pc.Legal.MainCopyrightOwner},
+		URLs:      []string{pc.URLString, pc.LandingURLString},
+		CodiceIPA: pc.It.Riuso.CodiceIPA,
+	}
+
+	matches := ipa.SuggestCodiceIPA(hints, 3)
+	for _, match := range matches {
+		logger.WithFields(log.Fields{
+			"suggestedCodiceIPA": match.CodiceIPA,
+			"score":              match.Score,
+		}).Infof(
+			"suggested codiceIPA: %s (%s), score %.2f, matched by %s",
+			match.CodiceIPA, match.Name, match.Score, strings.Join(match.Reasons, ", "),
+		)
+	}
+
+	return matches
+}
@@ -1,13 +1,21 @@
 package crawler
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	publiccode "github.com/italia/publiccode-parser-go"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
-	yaml "gopkg.in/yaml.v2"
 )
 
 var whitelist string = `
@@ -27,15 +35,14 @@ var whitelist string = `
     - "https://github.com/test/testrepo"
 `
 
-// validateRemoteFile will parse and validate
+// validateFile will validate
 // crawled publiccode.yml. It will thrown errors
 // if parse fails and if IPA code mismatch between
 // whithelist file and publiccode itself
 func TestIPAMatch(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
-	var pas []PA
 	var parser publiccode.Parser
-	err := yaml.Unmarshal([]byte(whitelist), &pas)
+	pas, err := parseWhitelistFile([]byte(whitelist))
 	if err != nil {
 		t.Errorf("error on unmarsalling whitelist %s", err)
 	}
@@ -76,14 +83,117 @@ func TestRemovingRepoAsBlacklisted(t *testing.T) {
 	close(c.repositories)
 
 	// Faking blacklist entries
-	var repoListed = make(map[string]string)
-	repoListed["https://github.com/italia/repo1.git"] = "https://github.com/italia/repo1"
-	repoListed["https://github.com/italia/repo3.git"] = "https://github.com/italia/repo3"
+	blacklist := NewBlacklist([]Repo{
+		{URL: "https://github.com/italia/repo1"},
+		{URL: "https://github.com/italia/repo3/"},
+	})
 
-	toBeRemoved := c.removeBlackListedFromRepositories(repoListed)
+	out := make(chan Repository, 3)
+	toBeRemoved := c.filterBlacklisted(blacklist, out)
+	close(out)
 
-	assert.Len(t, toBeRemoved, 2)
-	for _, entry := range toBeRemoved {
-		assert.NotEmpty(t, repoListed[appendGitExt(entry)])
+	assert.Equal(t, []string{"https://github.com/italia/repo1", "https://github.com/italia/repo3"}, toBeRemoved)
+
+	var left []string
+	for repo := range out {
+		left = append(left, repo.Name)
+	}
+	assert.Equal(t, []string{"repo2"}, left)
+}
+
+// More repositories than the repositories channel can buffer are
+// processed while they are still being found.
+func TestCrawlManyRepositories(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir, err := ioutil.TempDir("", "crawl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("OUTPUT_DIR", dir)
+	viper.Set("METRICS_ADDR", "127.0.0.1:0")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	const count = 1500
+	c := Crawler{DryRun: true, repositories: make(chan Repository, 1000)}
+	c.publishersWg.Add(1)
+	go func() {
+		defer c.publishersWg.Done()
+		for i := 0; i < count; i++ {
+			name := fmt.Sprintf("repo%d", i)
+			repo := createFakeRepo(name, "https://github.com/italia/"+name+".git")
+			repo.Hostname = "github.com"
+			repo.FileRawURL = server.URL + "/" + name + "/publiccode.yml"
+			c.repositories <- repo
+		}
+	}()
+	go func() {
+		c.publishersWg.Wait()
+		close(c.repositories)
+	}()
+
+	blacklist := NewBlacklist([]Repo{{URL: "https://github.com/italia/repo1*"}})
+
+	type result struct {
+		toBeRemoved []string
+		err         error
 	}
+	done := make(chan result)
+	go func() {
+		toBeRemoved, err := c.crawl(blacklist)
+		done <- result{toBeRemoved, err}
+	}()
+
+	select {
+	case r := <-done:
+		assert.Nil(t, r.err)
+		// repo1, repo10-19, repo100-199 and repo1000-1499.
+		assert.Len(t, r.toBeRemoved, 1+10+100+500)
+		assert.Equal(t, int32(count-len(r.toBeRemoved)), atomic.LoadInt32(&requests))
+	case <-time.After(30 * time.Second):
+		t.Fatal("crawl didn't end")
+	}
+}
+
+func TestRepositorySize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = repositorySize(dir)
+	assert.Error(t, err)
+
+	// The working tree isn't counted, only the objects.
+	if out, err := exec.Command("git", "init", dir).CombinedOutput(); err != nil {
+		t.Skipf("git not available: %v: %s", err, out)
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 1<<20), 0644))
+	size, err := repositorySize(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	out, err := exec.Command("git", "-C", dir, "add", "data").CombinedOutput()
+	assert.NoError(t, err, string(out))
+	size, err = repositorySize(dir)
+	assert.NoError(t, err)
+	assert.True(t, size > 0)
+}
+
+func TestRemoteBaseURL(t *testing.T) {
+	assert.Equal(t, "https://raw.githubusercontent.com/italia/repo/master/",
+		remoteBaseURL("https://raw.githubusercontent.com/italia/repo/master/publiccode.yml"))
+	// The directory isn't trimmed along with the filename.
+	assert.Equal(t, "https://raw.githubusercontent.com/italia/code/master/docs/",
+		remoteBaseURL("https://raw.githubusercontent.com/italia/code/master/docs/publiccode.yaml"))
+	assert.Equal(t, "https://gitlab.com/pa/repo/raw/main/",
+		remoteBaseURL("https://gitlab.com/pa/repo/raw/main/publiccode.yml?inline=false"))
 }
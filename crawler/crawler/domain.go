@@ -59,20 +59,20 @@ func parseDomainsFile(data []byte) ([]Domain, error) {
 	return domains, err
 }
 
-func (domain Domain) processAndGetNextURL(url string, repositories chan Repository, pa PA) (string, error) {
+func (domain Domain) processAndGetNextURL(url string, repositories chan Repository, pa PA, opts CrawlOptions) (string, error) {
 	crawler, err := GetClientAPICrawler(domain.API())
 	if err != nil {
 		return "", err
 	}
-	return crawler(domain, url, repositories, pa)
+	return crawler(domain, url, repositories, pa, opts)
 }
 
-func (domain Domain) processSingleRepo(url string, repositories chan Repository, pa PA) error {
+func (domain Domain) processSingleRepo(url string, repositories chan Repository, pa PA, opts CrawlOptions) error {
 	crawler, err := GetSingleClientAPICrawler(domain.API())
 	if err != nil {
 		return err
 	}
-	return crawler(domain, url, repositories, pa)
+	return crawler(domain, url, repositories, pa, opts)
 }
 
 func (domain Domain) generateAPIURLs(u string) ([]string, error) {
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/italia/developers-italia-backend/crawler/dcat"
+	"github.com/italia/developers-italia-backend/crawler/jekyll"
+	"github.com/italia/developers-italia-backend/crawler/staging"
+	"github.com/italia/developers-italia-backend/crawler/staticapi"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Exporter writes the catalog stored in Elasticsearch to outputDir in a
+// format suitable for a frontend (every format has a different implementation),
+// returning the number of software in the snapshot of the index it scrolled,
+// less the ones it skipped because they are not valid, which are logged.
+// Validate checks that the files exported to outputDir contain all the count software.
+type Exporter interface {
+	Export(outputDir string, elasticClient *es.Client) (int64, error)
+	Validate(outputDir string, count int64) error
+}
+
+var exporters = map[string]Exporter{
+	"yaml": jekyll.Exporter{},
+	"json": staticapi.Exporter{},
+	"dcat": dcat.Exporter{},
+}
+
+// ExportFormats returns the names of the available export formats.
+func ExportFormats() []string {
+	var formats []string
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	return formats
+}
+
+// Export exports the catalog to OUTPUT_DIR in the given format.
+// The files are written to a staging directory and published all together
+// only if they are valid, so the live files are never partial.
+func (c *Crawler) Export(format string) error {
+	exporter, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %s (available: %v)", format, ExportFormats())
+	}
+
+	if c.DryRun {
+		log.Infof("Skipping %s output (--dry-run)", format)
+		return nil
+	}
+
+	// Make sure the output directory exists
+	outputDir := viper.GetString("OUTPUT_DIR")
+	if stat, err := os.Stat(outputDir); err != nil || !stat.IsDir() {
+		return fmt.Errorf("the configured output directory (%v) does not exist: %v", outputDir, err)
+	}
+
+	set, err := staging.New(outputDir, format)
+	if err != nil {
+		return err
+	}
+
+	// The software are counted in the same snapshot of the index they are
+	// exported from, so a crawl writing to it doesn't affect the validation.
+	count, err := exporter.Export(set.Dir, c.es)
+	if err == nil {
+		err = set.Check()
+	}
+	if err == nil {
+		err = exporter.Validate(set.Dir, count)
+	}
+	if err != nil {
+		set.Discard() // nolint: errcheck
+		return fmt.Errorf("%s export not published: %v", format, err)
+	}
+
+	log.Infof("Publishing %s export", format)
+	return set.Publish()
+}
+
+// RollbackExport publishes again the previous export in the given format.
+func RollbackExport(format string) error {
+	if _, ok := exporters[format]; !ok {
+		return fmt.Errorf("unknown export format %s (available: %v)", format, ExportFormats())
+	}
+
+	return staging.Rollback(viper.GetString("OUTPUT_DIR"), format)
+}
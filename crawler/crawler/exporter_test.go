@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic/elastictest"
+	"github.com/italia/developers-italia-backend/crawler/jekyll"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// truncatingExporter exports a softwares.yml missing the last software.
+type truncatingExporter struct{}
+
+func (e truncatingExporter) Export(outputDir string, elasticClient *es.Client) (int64, error) {
+	return 2, ioutil.WriteFile(path.Join(outputDir, "softwares.yml"), []byte("- truncated\n"), 0644)
+}
+
+func (e truncatingExporter) Validate(outputDir string, count int64) error {
+	return jekyll.Exporter{}.Validate(outputDir, count)
+}
+
+func TestExport(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("OUTPUT_DIR", dir)
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+
+	docs := []interface{}{
+		map[string]interface{}{"id": "1", "slug": "software-1", "publiccode": map[string]interface{}{"name": "Software 1"}},
+		map[string]interface{}{"id": "2", "slug": "software-2", "publiccode": map[string]interface{}{"name": "Software 2"}},
+	}
+	server, client := elastictest.NewClient(t, docs)
+	defer server.Close()
+	c := Crawler{es: client}
+
+	assert.Nil(t, c.Export("json"))
+	data, err := ioutil.ReadFile(path.Join(dir, "software", "software-1.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "Software 1")
+
+	assert.EqualError(t, c.Export("pdf"), "unknown export format pdf (available: [dcat json yaml])")
+
+	// An incomplete export is not published.
+	exporters["truncating"] = truncatingExporter{}
+	defer delete(exporters, "truncating")
+	assert.EqualError(t, c.Export("truncating"),
+		"truncating export not published: softwares.yml contains 1 software, 2 expected")
+	_, err = os.Lstat(path.Join(dir, "softwares.yml"))
+	assert.True(t, os.IsNotExist(err))
+
+	// There's no previous json export to roll back to.
+	assert.Error(t, RollbackExport("json"))
+}
@@ -13,7 +13,6 @@ import (
 
 	httpclient "github.com/italia/httpclient-lib-go"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
 // GithubOrgs is the complete result from the Github API respose for /orgs/<Name>/repos.
@@ -75,6 +74,7 @@ type GithubOrgs []struct {
 	StargazersCount  int       `json:"stargazers_count"`
 	WatchersCount    int       `json:"watchers_count"`
 	Language         string    `json:"language"`
+	Topics           []string  `json:"topics"`
 	HasIssues        bool      `json:"has_issues"`
 	HasProjects      bool      `json:"has_projects"`
 	HasDownloads     bool      `json:"has_downloads"`
@@ -160,6 +160,7 @@ type GithubRepo struct {
 	StargazersCount  int         `json:"stargazers_count"`
 	WatchersCount    int         `json:"watchers_count"`
 	Language         string      `json:"language"`
+	Topics           []string    `json:"topics"`
 	HasIssues        bool        `json:"has_issues"`
 	HasProjects      bool        `json:"has_projects"`
 	HasDownloads     bool        `json:"has_downloads"`
@@ -168,6 +169,9 @@ type GithubRepo struct {
 	ForksCount       int         `json:"forks_count"`
 	MirrorURL        interface{} `json:"mirror_url"`
 	Archived         bool        `json:"archived"`
+	Parent           *struct {
+		HTMLURL string `json:"html_url"`
+	} `json:"parent,omitempty"`
 	OpenIssuesCount  int         `json:"open_issues_count"`
 	License          interface{} `json:"license"`
 	Forks            int         `json:"forks"`
@@ -230,7 +234,7 @@ func githubBasicAuth(domain Domain) string {
 // If a next page is available return its url.
 // Otherwise returns an empty ("") string.
 func RegisterGithubAPI() OrganizationHandler {
-	return func(domain Domain, link string, repositories chan Repository, pa PA) (string, error) {
+	return func(domain Domain, link string, repositories chan Repository, pa PA, opts CrawlOptions) (string, error) {
 		// Set BasicAuth header
 		headers := make(map[string]string)
 		headers["Authorization"] = githubBasicAuth(domain)
@@ -244,12 +248,12 @@ func RegisterGithubAPI() OrganizationHandler {
 		domain.Host = u.Hostname()
 
 		// Get List of repositories.
-		resp, err := httpclient.GetURL(link, headers)
+		resp, err := getURL(link, headers)
 		if err != nil {
 			return link, err
 		}
 		if resp.Status.Code != http.StatusOK {
-			log.Warnf("Request returned: %s", string(resp.Body))
+			log.WithFields(log.Fields{"domain": domain.Host, "status": resp.Status.Code}).Warnf("Request returned: %s", string(resp.Body))
 			return "", errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
 		}
 
@@ -262,19 +266,23 @@ func RegisterGithubAPI() OrganizationHandler {
 
 		// Add repositories to the channel that will perform the check on everyone.
 		for _, v := range results {
-			if v.Private || v.Archived {
-				log.Warnf("Skipping %s: repo is private or archived", v.FullName)
+			if v.Private {
+				log.WithFields(log.Fields{"repo": v.FullName, "domain": domain.Host}).Warn("Skipping repository: private")
+				continue
+			}
+			if reason := opts.skip(v.Name, v.Fork, v.Archived); reason != "" {
+				log.WithFields(log.Fields{"repo": v.FullName, "domain": domain.Host}).Warnf("Skipping repository: %s", reason)
 				continue
 			}
 
 			// Marshal all the repository metadata.
 			metadata, err := json.Marshal(v)
 			if err != nil {
-				log.Errorf("github metadata: %v", err)
+				log.WithField("domain", domain.Host).Errorf("github metadata: %v", err)
 			}
-			contents := strings.Replace(v.ContentsURL, "{+path}", "", -1)
+			contents := githubContentsURL(v.ContentsURL, opts)
 			// Get List of files.
-			resp, err := httpclient.GetURL(contents, headers)
+			resp, err := getURL(contents, headers)
 			if err != nil {
 				log.Errorf("Request returned an error: %v", err)
 				continue
@@ -289,7 +297,16 @@ func RegisterGithubAPI() OrganizationHandler {
 				log.Infof("Repository is empty: %s", link)
 			}
 
-			err = addGithubProjectsToRepositories(files, v.FullName, v.CloneURL, v.DefaultBranch, domain.Host, domain, pa, headers, metadata, repositories)
+			// The list of repositories doesn't tell what a fork is based on.
+			upstream := v.MirrorURL
+			if v.Fork {
+				upstream, err = githubParent(v.URL, headers)
+				if err != nil {
+					log.WithFields(log.Fields{"repo": v.FullName, "domain": domain.Host}).Warnf("Can't find the upstream of the fork: %v", err)
+				}
+			}
+
+			err = addGithubProjectsToRepositories(files, v.FullName, v.CloneURL, upstreamURL(upstream), opts.branch(v.DefaultBranch), domain.Host, domain, pa, opts, headers, metadata, repositories)
 			if err != nil {
 				log.Infof("addGithubProectsToRepositories %v", err)
 			}
@@ -312,7 +329,7 @@ func RegisterGithubAPI() OrganizationHandler {
 // Return nil if the repository was successfully added to repositories channel.
 // Otherwise return the generated error.
 func RegisterSingleGithubAPI() SingleRepoHandler {
-	return func(domain Domain, link string, repositories chan Repository, pa PA) error {
+	return func(domain Domain, link string, repositories chan Repository, pa PA, opts CrawlOptions) error {
 		// Set BasicAuth header.
 		headers := make(map[string]string)
 		headers["Authorization"] = githubBasicAuth(domain)
@@ -331,12 +348,12 @@ func RegisterSingleGithubAPI() SingleRepoHandler {
 		u.Host = "api." + u.Host
 
 		// Get List of repositories.
-		resp, err := httpclient.GetURL(u.String(), headers)
+		resp, err := getURL(u.String(), headers)
 		if err != nil {
 			return err
 		}
 		if resp.Status.Code != http.StatusOK {
-			log.Warnf("Request returned: %s", string(resp.Body))
+			log.WithFields(log.Fields{"domain": domain.Host, "status": resp.Status.Code}).Warnf("Request returned: %s", string(resp.Body))
 			return errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
 		}
 
@@ -346,21 +363,21 @@ func RegisterSingleGithubAPI() SingleRepoHandler {
 			return err
 		}
 
-		if v.Private || v.Archived {
-			log.Warnf("Skipping %s: repo is private or archived", v.FullName)
+		if v.Private || (v.Archived && !opts.Archived) {
+			log.WithFields(log.Fields{"repo": v.FullName, "domain": domain.Host}).Warn("Skipping repository: private or archived")
 			return errors.New("Skipping private or archived repo")
 		}
 
 		// Marshal all the repository metadata.
 		metadata, err := json.Marshal(v)
 		if err != nil {
-			log.Errorf("github metadata: %v", err)
+			log.WithField("domain", domain.Host).Errorf("github metadata: %v", err)
 			return err
 		}
-		contents := strings.Replace(v.ContentsURL, "{+path}", "", -1)
+		contents := githubContentsURL(v.ContentsURL, opts)
 
 		// Get List of files.
-		resp, err = httpclient.GetURL(contents, headers)
+		resp, err = getURL(contents, headers)
 		if err != nil {
 			return err
 		}
@@ -375,49 +392,56 @@ func RegisterSingleGithubAPI() SingleRepoHandler {
 			log.Infof("Repository is empty: %s", link)
 		}
 
+		upstream, _ := v.MirrorURL.(string)
+		if v.Parent != nil {
+			upstream = v.Parent.HTMLURL
+		}
+
 		foundIt := false
 		// Search a file with a valid name and a downloadURL.
 		for _, f := range files {
-			if f.Name == viper.GetString("CRAWLED_FILENAME") && f.DownloadURL != "" {
+			if f.Name == path.Base(opts.filename()) && f.DownloadURL != "" {
 				// Add repository to channel.
 				repositories <- Repository{
 					Name:        v.FullName,
 					Hostname:    u.Hostname(),
 					FileRawURL:  f.DownloadURL,
 					GitCloneURL: v.CloneURL,
-					GitBranch:   v.DefaultBranch,
+					GitBranch:   opts.branch(v.DefaultBranch),
 					Domain:      domain,
 					Pa:          pa,
 					Headers:     headers,
 					Metadata:    metadata,
+					Upstream:    upstreamURL(upstream),
 				}
 				foundIt = true
 			}
 		}
 		if !foundIt {
-			return errors.New("Repository does not contain " + viper.GetString("CRAWLED_FILENAME"))
+			return errors.New("Repository does not contain " + opts.filename())
 		}
 		return nil
 	}
 }
 
 // addGithubProjectsToRepositories adds the projects from api response to repository channel.
-func addGithubProjectsToRepositories(files GithubFiles, fullName, cloneURL, defaultBranch, hostname string,
-	domain Domain, pa PA, headers map[string]string, metadata []byte, repositories chan Repository) error {
+func addGithubProjectsToRepositories(files GithubFiles, fullName, cloneURL, upstream, branch, hostname string,
+	domain Domain, pa PA, opts CrawlOptions, headers map[string]string, metadata []byte, repositories chan Repository) error {
 	// Search a file with a valid name and a downloadURL.
 	for _, f := range files {
-		if f.Name == viper.GetString("CRAWLED_FILENAME") && f.DownloadURL != "" {
+		if f.Name == path.Base(opts.filename()) && f.DownloadURL != "" {
 			// Add repository to channel.
 			repositories <- Repository{
 				Name:        fullName,
 				Hostname:    hostname,
 				FileRawURL:  f.DownloadURL,
 				GitCloneURL: cloneURL,
-				GitBranch:   defaultBranch,
+				GitBranch:   branch,
 				Domain:      domain,
 				Pa:          pa,
 				Headers:     headers,
 				Metadata:    metadata,
+				Upstream:    upstream,
 			}
 		}
 	}
@@ -425,6 +449,41 @@ func addGithubProjectsToRepositories(files GithubFiles, fullName, cloneURL, defa
 	return nil
 }
 
+// githubParent returns the URL of the repository forked by the repository
+// at the API URL repoURL.
+func githubParent(repoURL string, headers map[string]string) (string, error) {
+	resp, err := getURL(repoURL, headers)
+	if err != nil {
+		return "", err
+	}
+	if resp.Status.Code != http.StatusOK {
+		return "", errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
+	}
+
+	var v GithubRepo
+	err = json.Unmarshal(resp.Body, &v)
+	if err != nil || v.Parent == nil {
+		return "", err
+	}
+
+	return v.Parent.HTMLURL, nil
+}
+
+// githubContentsURL returns the API URL listing the files in the directory
+// of the publiccode.yml, in the branch to crawl.
+func githubContentsURL(contentsURL string, opts CrawlOptions) string {
+	dir := path.Dir(opts.filename())
+	if dir == "." {
+		dir = ""
+	}
+	contents := strings.Replace(contentsURL, "{+path}", dir, -1)
+	if opts.Branch != "" {
+		contents += "?ref=" + url.QueryEscape(opts.Branch)
+	}
+
+	return contents
+}
+
 // GenerateGithubAPIURL returns the api url of given Gitlab organization link.
 // IN: https://github.com/italia
 // OUT:https://api.github.com/orgs/italia/repos,https://api.github.com/users/italia/repos
@@ -467,7 +526,7 @@ func IsGithub(link string) bool {
 	u.Path = "rate_limit"
 	u.Host = "api." + u.Host
 
-	resp, err := httpclient.GetURL(u.String(), nil)
+	resp, err := getURL(u.String(), nil)
 	if err != nil {
 		log.Debugf("can %s use Github API? No.", link)
 		return false
@@ -1,10 +1,15 @@
 package crawler
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 )
 
 // IsGithub returns "true" if the url can use Github API.
@@ -58,3 +63,35 @@ func TestGenerateGithubAPIURL(t *testing.T) {
 	}
 
 }
+
+// Forks are linked to the repository they are based on.
+func TestGithubForkUpstream(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("CRAWLED_FILENAME", "publiccode.yml")
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs/pa/repos":
+			fmt.Fprintf(w, `[{"name": "fork", "full_name": "pa/fork", "fork": true, "url": "%[1]s/repos/pa/fork",
+				"contents_url": "%[1]s/repos/pa/fork/contents/{+path}", "default_branch": "main"}]`, server.URL)
+		case "/repos/pa/fork":
+			fmt.Fprint(w, `{"full_name": "pa/fork", "parent": {"html_url": "https://github.com/other/original"}}`)
+		case "/repos/pa/fork/contents/":
+			fmt.Fprint(w, `[{"name": "publiccode.yml", "download_url": "https://example.org/publiccode.yml"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repositories := make(chan Repository, 1)
+	handler := RegisterGithubAPI()
+	_, err := handler(Domain{Host: "github.com"}, server.URL+"/orgs/pa/repos", repositories, PA{}, CrawlOptions{Forks: true})
+	assert.Nil(t, err)
+	close(repositories)
+
+	repo := <-repositories
+	assert.Equal(t, "pa/fork", repo.Name)
+	assert.Equal(t, "https://github.com/other/original", repo.Upstream)
+}
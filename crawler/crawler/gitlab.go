@@ -11,7 +11,6 @@ import (
 
 	httpclient "github.com/italia/httpclient-lib-go"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
 // GitlabGroups is the complete result from the Gitlab API respose.
@@ -46,13 +45,19 @@ type GitlabRepo struct {
 	CreatedAt         time.Time     `json:"created_at"`
 	DefaultBranch     string        `json:"default_branch"`
 	TagList           []interface{} `json:"tag_list"`
+	Topics            []string      `json:"topics"`
 	SSHURLToRepo      string        `json:"ssh_url_to_repo"`
 	HTTPURLToRepo     string        `json:"http_url_to_repo"`
 	WebURL            string        `json:"web_url"`
 	AvatarURL         interface{}   `json:"avatar_url"`
 	StarCount         int           `json:"star_count"`
 	ForksCount        int           `json:"forks_count"`
+	OpenIssuesCount   int           `json:"open_issues_count,omitempty"`
 	LastActivityAt    time.Time     `json:"last_activity_at"`
+	Archived          bool          `json:"archived"`
+	ForkedFromProject *GitlabRepo   `json:"forked_from_project,omitempty"`
+	Mirror            bool          `json:"mirror,omitempty"`
+	ImportURL         string        `json:"import_url,omitempty"`
 }
 
 // GitlabProject is a software project hosted on Gitlab.
@@ -66,6 +71,7 @@ type GitlabProject struct {
 	CreatedAt         time.Time     `json:"created_at"`
 	DefaultBranch     string        `json:"default_branch"`
 	TagList           []interface{} `json:"tag_list"`
+	Topics            []string      `json:"topics"`
 	SSHURLToRepo      string        `json:"ssh_url_to_repo"`
 	HTTPURLToRepo     string        `json:"http_url_to_repo"`
 	WebURL            string        `json:"web_url"`
@@ -102,6 +108,9 @@ type GitlabProject struct {
 		FullPath string      `json:"full_path"`
 		ParentID interface{} `json:"parent_id"`
 	} `json:"namespace"`
+	ForkedFromProject                         GitlabRepo    `json:"forked_from_project,omitempty"`
+	Mirror                                    bool          `json:"mirror,omitempty"`
+	ImportURL                                 string        `json:"import_url,omitempty"`
 	ImportStatus                              string        `json:"import_status"`
 	OpenIssuesCount                           int           `json:"open_issues_count,omitempty"`
 	PublicJobs                                bool          `json:"public_jobs"`
@@ -126,6 +135,7 @@ type GitlabSharedProject struct {
 	CreatedAt         time.Time     `json:"created_at"`
 	DefaultBranch     string        `json:"default_branch"`
 	TagList           []interface{} `json:"tag_list"`
+	Topics            []string      `json:"topics"`
 	SSHURLToRepo      string        `json:"ssh_url_to_repo"`
 	HTTPURLToRepo     string        `json:"http_url_to_repo"`
 	WebURL            string        `json:"web_url"`
@@ -163,6 +173,8 @@ type GitlabSharedProject struct {
 		ParentID interface{} `json:"parent_id"`
 	} `json:"namespace"`
 	ForkedFromProject GitlabRepo  `json:"forked_from_project,omitempty"`
+	Mirror            bool        `json:"mirror,omitempty"`
+	ImportURL         string      `json:"import_url,omitempty"`
 	ImportStatus      string      `json:"import_status"`
 	OpenIssuesCount   int         `json:"open_issues_count,omitempty"`
 	PublicJobs        bool        `json:"public_jobs"`
@@ -202,7 +214,7 @@ func useGitlabGroups(u *url.URL) bool {
 
 // RegisterGitlabAPI register the crawler function for Gitlab API.
 func RegisterGitlabAPI() OrganizationHandler {
-	return func(domain Domain, link string, repositories chan Repository, pa PA) (string, error) {
+	return func(domain Domain, link string, repositories chan Repository, pa PA, opts CrawlOptions) (string, error) {
 		log.Debugf("RegisterGitlabAPI: %s ", link)
 
 		// Set BasicAuth header.
@@ -222,12 +234,12 @@ func RegisterGitlabAPI() OrganizationHandler {
 		// Set domain host to new host.
 		domain.Host = u.Hostname()
 
-		resp, err := httpclient.GetURL(link, headers)
+		resp, err := getURL(link, headers)
 		if err != nil {
 			return link, err
 		}
 		if resp.Status.Code != http.StatusOK {
-			log.Warnf("Request returned: %s", string(resp.Body))
+			log.WithFields(log.Fields{"domain": domain.Host, "status": resp.Status.Code}).Warnf("Request returned: %s", string(resp.Body))
 			return "", errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
 		}
 
@@ -238,11 +250,11 @@ func RegisterGitlabAPI() OrganizationHandler {
 				return link, err
 			}
 
-			err = addGitlabProjectsToRepositories(result.Projects, domain, pa, headers, repositories)
+			err = addGitlabProjectsToRepositories(result.Projects, domain, pa, opts, headers, repositories)
 			if err != nil {
 				return link, err
 			}
-			err = addGitlabSharedProjectsToRepositories(result.SharedProjects, domain, pa, headers, repositories)
+			err = addGitlabSharedProjectsToRepositories(result.SharedProjects, domain, pa, opts, headers, repositories)
 			if err != nil {
 				return link, err
 			}
@@ -256,7 +268,7 @@ func RegisterGitlabAPI() OrganizationHandler {
 				return plink, err
 			}
 
-			resp, err := httpclient.GetURL(url.String(), headers)
+			resp, err := getURL(url.String(), headers)
 			if err != nil {
 				return plink, err
 			}
@@ -270,7 +282,7 @@ func RegisterGitlabAPI() OrganizationHandler {
 				return plink, err;
 			}
 
-			err = addGitlabProjectsToRepositories(projects, domain, pa, headers, repositories)
+			err = addGitlabProjectsToRepositories(projects, domain, pa, opts, headers, repositories)
 			if err != nil {
 				return plink, err
 			}
@@ -293,7 +305,7 @@ func RegisterGitlabAPI() OrganizationHandler {
 
 // RegisterSingleGitlabAPI register the crawler function for single Bitbucket API.
 func RegisterSingleGitlabAPI() SingleRepoHandler {
-	return func(domain Domain, link string, repositories chan Repository, pa PA) error {
+	return func(domain Domain, link string, repositories chan Repository, pa PA, opts CrawlOptions) error {
 		// Set BasicAuth header
 		headers := make(map[string]string)
 		if domain.BasicAuth != nil {
@@ -319,12 +331,12 @@ func RegisterSingleGitlabAPI() SingleRepoHandler {
 		fullURL := "https://" + u.Hostname() + "/api/v4/projects/" + url.QueryEscape(repoString)
 
 		// Get single Repo
-		resp, err := httpclient.GetURL(fullURL, headers)
+		resp, err := getURL(fullURL, headers)
 		if err != nil {
 			return err
 		}
 		if resp.Status.Code != http.StatusOK {
-			log.Warnf("Request returned: %s", string(resp.Body))
+			log.WithFields(log.Fields{"domain": domain.Host, "status": resp.Status.Code}).Warnf("Request returned: %s", string(resp.Body))
 			return errors.New("request returned an incorrect http.Status: " + resp.Status.Text)
 		}
 
@@ -335,8 +347,18 @@ func RegisterSingleGitlabAPI() SingleRepoHandler {
 			return err
 		}
 
+		if result.Archived && !opts.Archived {
+			log.WithFields(log.Fields{"repo": result.PathWithNamespace, "domain": domain.Host}).Warn("Skipping repository: archived")
+			return errors.New("Skipping archived repo")
+		}
+
+		forkedFrom := ""
+		if result.ForkedFromProject != nil {
+			forkedFrom = result.ForkedFromProject.WebURL
+		}
+
 		// Join file raw URL string.
-		fileRawURL, err := generateGitlabRawURL(result.WebURL, result.DefaultBranch)
+		fileRawURL, err := generateGitlabRawURL(result.WebURL, opts.branch(result.DefaultBranch), opts.filename())
 		if err != nil {
 			return err
 		}
@@ -344,7 +366,7 @@ func RegisterSingleGitlabAPI() SingleRepoHandler {
 		// Marshal all the repository metadata.
 		metadata, err := json.Marshal(result)
 		if err != nil {
-			log.Errorf("gitlab metadata: %v", err)
+			log.WithField("domain", domain.Host).Errorf("gitlab metadata: %v", err)
 			return err
 		}
 
@@ -354,12 +376,13 @@ func RegisterSingleGitlabAPI() SingleRepoHandler {
 				Name:        result.PathWithNamespace,
 				FileRawURL:  fileRawURL,
 				GitCloneURL: result.HTTPURLToRepo,
-				GitBranch:   result.DefaultBranch,
+				GitBranch:   opts.branch(result.DefaultBranch),
 				Hostname:    u.Hostname(),
 				Domain:      domain,
 				Pa:          pa,
 				Headers:     headers,
 				Metadata:    metadata,
+				Upstream:    gitlabUpstream(forkedFrom, result.Mirror, result.ImportURL),
 			}
 		} else {
 			return errors.New("repository is empty." + result.WebURL)
@@ -370,21 +393,48 @@ func RegisterSingleGitlabAPI() SingleRepoHandler {
 }
 
 // generateGitlabRawURL returns the file Gitlab specific file raw url.
-func generateGitlabRawURL(baseURL, defaultBranch string) (string, error) {
+func generateGitlabRawURL(baseURL, branch, filename string) (string, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", err
 	}
-	u.Path = path.Join(u.Path, "raw", defaultBranch, viper.GetString("CRAWLED_FILENAME"))
+	u.Path = path.Join(u.Path, "raw", branch, filename)
 
 	return u.String(), err
 }
 
+// gitlabUpstream returns the URL of the project forked, or pulled by a
+// mirror, if any. The API reports the URL pulled by a mirror only to the
+// maintainers of the project.
+func gitlabUpstream(forkedFrom string, mirror bool, importURL string) string {
+	if forkedFrom != "" {
+		return upstreamURL(forkedFrom)
+	}
+	// The projects imported once also have an import URL.
+	if !mirror || importURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(importURL)
+	if err != nil {
+		return ""
+	}
+	// The credentials are masked, but they are not part of the URL.
+	u.User = nil
+
+	return upstreamURL(u.String())
+}
+
 // addGitlabProjectsToRepositories adds the projects from api response to repository channel.
-func addGitlabProjectsToRepositories(projects []GitlabProject, domain Domain, pa PA, headers map[string]string, repositories chan Repository) error {
+func addGitlabProjectsToRepositories(projects []GitlabProject, domain Domain, pa PA, opts CrawlOptions, headers map[string]string, repositories chan Repository) error {
 	for _, v := range projects {
+		if reason := opts.skip(v.Path, v.ForkedFromProject.ID != 0, v.Archived); reason != "" {
+			log.WithFields(log.Fields{"repo": v.PathWithNamespace, "domain": domain.Host}).Warnf("Skipping repository: %s", reason)
+			continue
+		}
+
 		// Join file raw URL string.
-		rawURL, err := generateGitlabRawURL(v.WebURL, v.DefaultBranch)
+		rawURL, err := generateGitlabRawURL(v.WebURL, opts.branch(v.DefaultBranch), opts.filename())
 		if err != nil {
 			return err
 		}
@@ -392,7 +442,7 @@ func addGitlabProjectsToRepositories(projects []GitlabProject, domain Domain, pa
 		// Marshal all the repository metadata.
 		metadata, err := json.Marshal(v)
 		if err != nil {
-			log.Errorf("gitlab metadata: %v", err)
+			log.WithField("domain", domain.Host).Errorf("gitlab metadata: %v", err)
 			return err
 		}
 
@@ -402,11 +452,12 @@ func addGitlabProjectsToRepositories(projects []GitlabProject, domain Domain, pa
 				Hostname:    domain.Host,
 				FileRawURL:  rawURL,
 				GitCloneURL: v.HTTPURLToRepo,
-				GitBranch:   v.DefaultBranch,
+				GitBranch:   opts.branch(v.DefaultBranch),
 				Domain:      domain,
 				Pa:          pa,
 				Headers:     headers,
 				Metadata:    metadata,
+				Upstream:    gitlabUpstream(v.ForkedFromProject.WebURL, v.Mirror, v.ImportURL),
 			}
 		}
 	}
@@ -415,10 +466,15 @@ func addGitlabProjectsToRepositories(projects []GitlabProject, domain Domain, pa
 }
 
 // addGitlabSharedProjectsToRepositories adds the shared projects from api response to repository channel.
-func addGitlabSharedProjectsToRepositories(projects []GitlabSharedProject, domain Domain, pa PA, headers map[string]string, repositories chan Repository) error {
+func addGitlabSharedProjectsToRepositories(projects []GitlabSharedProject, domain Domain, pa PA, opts CrawlOptions, headers map[string]string, repositories chan Repository) error {
 	for _, v := range projects {
+		if reason := opts.skip(v.Path, v.ForkedFromProject.ID != 0, v.Archived); reason != "" {
+			log.WithFields(log.Fields{"repo": v.PathWithNamespace, "domain": domain.Host}).Warnf("Skipping repository: %s", reason)
+			continue
+		}
+
 		// Join file raw URL string.
-		rawURL, err := generateGitlabRawURL(v.WebURL, v.DefaultBranch)
+		rawURL, err := generateGitlabRawURL(v.WebURL, opts.branch(v.DefaultBranch), opts.filename())
 		if err != nil {
 			return err
 		}
@@ -426,7 +482,7 @@ func addGitlabSharedProjectsToRepositories(projects []GitlabSharedProject, domai
 		// Marshal all the repository metadata.
 		metadata, err := json.Marshal(v)
 		if err != nil {
-			log.Errorf("gitlab metadata: %v", err)
+			log.WithField("domain", domain.Host).Errorf("gitlab metadata: %v", err)
 			return err
 		}
 
@@ -436,11 +492,12 @@ func addGitlabSharedProjectsToRepositories(projects []GitlabSharedProject, domai
 				Hostname:    domain.Host,
 				FileRawURL:  rawURL,
 				GitCloneURL: v.HTTPURLToRepo,
-				GitBranch:   v.DefaultBranch,
+				GitBranch:   opts.branch(v.DefaultBranch),
 				Domain:      domain,
 				Pa:          pa,
 				Headers:     headers,
 				Metadata:    metadata,
+				Upstream:    gitlabUpstream(v.ForkedFromProject.WebURL, v.Mirror, v.ImportURL),
 			}
 		}
 	}
@@ -479,7 +536,7 @@ func IsGitlab(link string) bool {
 
 	u.Path = "api/v4/templates/gitlab_ci_ymls"
 
-	resp, err := httpclient.GetURL(u.String(), nil)
+	resp, err := getURL(u.String(), nil)
 	if err != nil {
 		log.Debugf("can %s use Gitlab API? No.", link)
 		return false
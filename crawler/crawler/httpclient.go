@@ -0,0 +1,23 @@
+package crawler
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/metrics"
+	httpclient "github.com/italia/httpclient-lib-go"
+)
+
+// getURL wraps httpclient.GetURL recording the duration and status of the request.
+func getURL(link string, headers map[string]string) (httpclient.HTTPResponse, error) {
+	start := time.Now()
+	resp, err := httpclient.GetURL(link, headers)
+
+	host := "unknown"
+	if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	metrics.ObserveHTTPRequest(host, resp.Status.Code, start)
+
+	return resp, err
+}
@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/linkcheck"
+	"github.com/italia/developers-italia-backend/crawler/version"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	linkCheckerOnce sync.Once
+	linkChecker     *linkcheck.Checker
+)
+
+// linkCachePath is the file with the results of the link checks, kept
+// across crawls.
+func linkCachePath() string {
+	return filepath.Join(viper.GetString("CRAWLER_DATADIR"), "linkcheck.json")
+}
+
+// GetLinkChecker returns the checker of the links in the publiccode.yml
+// files, configured with LINKCHECK_TIMEOUT, LINKCHECK_TTL,
+// LINKCHECK_FAILURE_TTL and LINKCHECK_PER_HOST. It's created once, with the results cached by the
+// previous crawls.
+func GetLinkChecker() *linkcheck.Checker {
+	linkCheckerOnce.Do(func() {
+		timeout := 10 * time.Second
+		if viper.IsSet("LINKCHECK_TIMEOUT") {
+			timeout = viper.GetDuration("LINKCHECK_TIMEOUT")
+		}
+		ttl := 72 * time.Hour
+		if viper.IsSet("LINKCHECK_TTL") {
+			ttl = viper.GetDuration("LINKCHECK_TTL")
+		}
+		perHost := 2
+		if viper.IsSet("LINKCHECK_PER_HOST") {
+			perHost = viper.GetInt("LINKCHECK_PER_HOST")
+		}
+
+		linkChecker = linkcheck.NewChecker(timeout, ttl, perHost)
+		if viper.IsSet("LINKCHECK_FAILURE_TTL") {
+			linkChecker.FailureTTL = viper.GetDuration("LINKCHECK_FAILURE_TTL")
+		}
+		linkChecker.UserAgent = "developers-italia-backend/" + version.VERSION
+		if err := linkChecker.Load(linkCachePath()); err != nil {
+			log.Errorf("cannot load the link cache, checking all the links again: %v", err)
+		}
+	})
+
+	return linkChecker
+}
+
+// saveLinkCache saves the results of the link checks for the next crawls.
+func saveLinkCache() {
+	if linkChecker == nil {
+		return
+	}
+	if err := linkChecker.Save(linkCachePath()); err != nil {
+		log.Errorf("cannot save the link cache: %v", err)
+	}
+}
@@ -3,7 +3,10 @@ package crawler
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
+	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -43,6 +46,129 @@ func TestReadWhitelists(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestParseWhitelistV2(t *testing.T) {
+	payload := `version: 2
+publishers:
+  - name: pcm
+    codice-iPA: pcm
+    contacts:
+      - name: Ufficio
+        email: ufficio@example.org
+    orgs:
+      - https://github.com/italia
+      - url: https://github.com/teamdigitale
+        include: ["app-*"]
+        exclude: ["*-old"]
+        forks: true
+        branch: develop
+        publiccode-path: docs/publiccode.yml
+    repos:
+      - https://github.com/pagopa/repo1
+      - url: https://github.com/pagopa/repo2
+        archived: true`
+
+	result, err := parseWhitelistFile([]byte(payload))
+	assert.Nil(t, err)
+	if assert.Len(t, result, 1) {
+		pa := result[0]
+		assert.Equal(t, []Contact{{Name: "Ufficio", Email: "ufficio@example.org"}}, pa.Contacts)
+		assert.Equal(t, []Org{
+			{URL: "https://github.com/italia"},
+			{URL: "https://github.com/teamdigitale", CrawlOptions: CrawlOptions{
+				Include:        []string{"app-*"},
+				Exclude:        []string{"*-old"},
+				Forks:          true,
+				Branch:         "develop",
+				PubliccodePath: "docs/publiccode.yml",
+			}},
+		}, pa.Organizations)
+		assert.Equal(t, []WhitelistRepo{
+			{URL: "https://github.com/pagopa/repo1"},
+			{URL: "https://github.com/pagopa/repo2", CrawlOptions: CrawlOptions{Archived: true}},
+		}, pa.Repositories)
+	}
+
+	// The options are written back, and the URL alone without options.
+	data, err := MarshalWhitelist(result)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "version: 2\n")
+	assert.Contains(t, string(data), "- https://github.com/italia\n")
+	roundtrip, err := parseWhitelistFile(data)
+	assert.Nil(t, err)
+	assert.Equal(t, result, roundtrip)
+}
+
+func TestParseWhitelistV1(t *testing.T) {
+	result, err := parseWhitelistFile([]byte(`
+- name: pcm
+  orgs:
+    - https://github.com/italia
+    - https://gitlab.com/pcm
+  repos:
+    - https://github.com/pagopa/repo1
+    - https://gitlab.comune.example.it/pcm/repo2`))
+	assert.Nil(t, err)
+	// The archived repositories were skipped only on GitHub.
+	assert.Equal(t, []Org{
+		{URL: "https://github.com/italia", CrawlOptions: CrawlOptions{Forks: true}},
+		{URL: "https://gitlab.com/pcm", CrawlOptions: CrawlOptions{Forks: true, Archived: true}},
+	}, result[0].Organizations)
+	assert.Equal(t, []WhitelistRepo{
+		{URL: "https://github.com/pagopa/repo1"},
+		{URL: "https://gitlab.comune.example.it/pcm/repo2", CrawlOptions: CrawlOptions{Archived: true}},
+	}, result[0].Repositories)
+
+	// The unknown fields are ignored, as they always were.
+	result, err = parseWhitelistFile([]byte("- name: pcm\n  codice-ipa: pcm\n  orgs:\n    - https://github.com/italia"))
+	assert.Nil(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, "pcm", result[0].Name)
+		assert.Len(t, result[0].Organizations, 1)
+	}
+}
+
+func TestParseWhitelistErrors(t *testing.T) {
+	for payload, expected := range map[string]string{
+		"version: 3\npublishers: []":                                                                "line 1: unsupported whitelist version 3 (supported: 1, 2)",
+		"version: 1\npublishers: []":                                                                "line 1: a version 1 whitelist must be a plain list of publishers, without version",
+		"version: 2\npublishers: []\nfoo: 1":                                                        `line 3: unknown field "foo"`,
+		"version: 2\npublishers:\n  - name: pcm\n    codice-ipa: pcm":                               `line 4: unknown field "codice-ipa"`,
+		"version: 2\npublishers:\n  - name: pcm\n    orgs:\n      - include: [a]":                   "line 5: org without url",
+		"version: 2\npublishers:\n  - name: pcm\n    orgs:\n      - url: u\n        exclude: ['[']": `line 5: invalid pattern "[" for org u: syntax error in pattern`,
+		"version: 2\npublishers:\n  - name: pcm\n    repos:\n      - url: u\n        include: [a]":  `line 6: unknown field "include"`,
+	} {
+		_, err := parseWhitelistFile([]byte(payload))
+		if assert.NotNil(t, err, payload) {
+			assert.Equal(t, expected, err.Error())
+		}
+	}
+
+	fileReaderInject = FakeReadFiler{Str: "version: 2\npublishers:\n  - orgs: [{}]"}.ReadFile
+	defer func() { fileReaderInject = ioutil.ReadFile }()
+	_, err := ReadAndParseWhitelist("whitelist/pa.yml")
+	assert.EqualError(t, err, "error in parsing whitelist/pa.yml file: line 3: org without url")
+}
+
+func TestCrawlOptionsSkip(t *testing.T) {
+	opts := CrawlOptions{Include: []string{"app-*", "site"}, Exclude: []string{"*-old"}}
+
+	assert.Equal(t, "", opts.skip("italia/app-io", false, false))
+	assert.Equal(t, "", opts.skip("site", false, false))
+	assert.Equal(t, "not included", opts.skip("italia/lib", false, false))
+	assert.Equal(t, "excluded", opts.skip("italia/app-old", false, false))
+	assert.Equal(t, "fork", opts.skip("italia/app-io", true, false))
+	assert.Equal(t, "archived", opts.skip("italia/app-io", false, true))
+	assert.Equal(t, "", CrawlOptions{Forks: true, Archived: true}.skip("italia/lib", true, true))
+
+	assert.Equal(t, "main", CrawlOptions{}.branch("main"))
+	assert.Equal(t, "develop", CrawlOptions{Branch: "develop"}.branch("main"))
+
+	assert.Equal(t, "https://api.github.com/repos/italia/app/contents/",
+		githubContentsURL("https://api.github.com/repos/italia/app/contents/{+path}", CrawlOptions{PubliccodePath: "publiccode.yml"}))
+	assert.Equal(t, "https://api.github.com/repos/italia/app/contents/docs?ref=develop",
+		githubContentsURL("https://api.github.com/repos/italia/app/contents/{+path}", CrawlOptions{Branch: "develop", PubliccodePath: "docs/publiccode.yml"}))
+}
+
 func TestReadBlacklists(t *testing.T) {
 	payload := `---
 repos:
@@ -73,3 +199,88 @@ repos:
 	// assert.True(t, IsRepoInBlackList("https://github.com/italia/repo2"))
 	// assert.False(t, IsRepoInBlackList("https://github.com/italia/repo3"))
 }
+
+func TestBlacklistMatch(t *testing.T) {
+	blacklist := NewBlacklist([]Repo{
+		{URL: "https://github.com/italia/Repo1", Reason: "1"},
+		{Org: "https://gitlab.com/blocked/", Reason: "2"},
+		{URL: "https://github.com/italia/*-test", Reason: "3"},
+		{URL: "https://github.com/italia/expired", Until: "2020-01-01"},
+		{URL: "https://github.com/italia/temporary", Until: "2999-01-01"},
+	})
+
+	for _, u := range []string{
+		"https://github.com/italia/repo1",
+		"http://github.com/italia/repo1.git",
+		"https://GitHub.com/italia/Repo1/",
+	} {
+		if assert.NotNil(t, blacklist.Match(u), u) {
+			assert.Equal(t, "1", blacklist.Match(u).Reason)
+		}
+	}
+
+	assert.Equal(t, "2", blacklist.Match("https://gitlab.com/blocked/any.git").Reason)
+	assert.Equal(t, "2", blacklist.Match("https://gitlab.com/Blocked/group/repo").Reason)
+	assert.Nil(t, blacklist.Match("https://gitlab.com/blocked"))
+	assert.Nil(t, blacklist.Match("https://gitlab.com/blocked-not/repo"))
+
+	assert.Equal(t, "3", blacklist.Match("https://github.com/italia/app-test.git").Reason)
+	assert.Nil(t, blacklist.Match("https://github.com/italia/app-test/other"))
+
+	assert.Nil(t, blacklist.Match("https://github.com/italia/expired"))
+	assert.NotNil(t, blacklist.Match("https://github.com/italia/temporary"))
+
+	assert.Nil(t, blacklist.Match("https://github.com/italia/repo10"))
+}
+
+func TestRepoExpired(t *testing.T) {
+	repo := Repo{URL: "https://github.com/italia/repo1", Until: "2020-01-31"}
+
+	assert.False(t, repo.Expired(time.Date(2020, 1, 31, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, repo.Expired(time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, Repo{}.Expired(time.Now()))
+}
+
+func TestLoadBlacklist(t *testing.T) {
+	fileReaderInject = ioutil.ReadFile
+
+	dir, err := ioutil.TempDir("", "blacklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	payload := `---
+repos:
+  - url: https://github.com/italia/repo1
+    reason: 1
+    description: GitHub takedown
+    until: 2999-12-31
+  - org: https://github.com/blocked
+    reason: 2`
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "blacklist.yml"), []byte(payload), 0644))
+
+	blacklist, err := LoadBlacklist(dir, "*.yml")
+	assert.Nil(t, err)
+	assert.Len(t, blacklist.Repos(), 2)
+	assert.Equal(t, "2999-12-31", blacklist.Repos()[0].Until)
+	assert.NotNil(t, blacklist.Match("https://github.com/italia/repo1.git"))
+	assert.NotNil(t, blacklist.Match("https://github.com/blocked/repo"))
+
+	// Entries must have either url or org, the invalid files are skipped.
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "invalid.yml"), []byte("repos:\n  - reason: 1"), 0644))
+	blacklist, err = LoadBlacklist(dir, "*.yml")
+	assert.Nil(t, err)
+	assert.Len(t, blacklist.Repos(), 2)
+	assert.NotNil(t, blacklist.Match("https://github.com/italia/repo1.git"))
+
+	// And so are the ones with an invalid until date.
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "typo.yml"), []byte("repos:\n  - url: https://github.com/italia/typo\n    until: 2020-31-12"), 0644))
+	blacklist, err = LoadBlacklist(dir, "*.yml")
+	assert.Nil(t, err)
+	assert.Len(t, blacklist.Repos(), 2)
+	assert.Nil(t, blacklist.Match("https://github.com/italia/typo"))
+
+	_, err = LoadBlacklist(path.Join(dir, "missing"), "*.yml")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logEntry is an entry of the log.json of a repository.
+type logEntry struct {
+	Datetime string                 `json:"datetime"`
+	Level    string                 `json:"level"`
+	Message  string                 `json:"message"`
+	TraceID  string                 `json:"traceId,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+// repoFields are the fields shared by all the log events of a repository,
+// which are left out of the fields of its log.json entries.
+var repoFields = []string{"repo", "publisher", "codiceIPA", "domain", "traceId"}
+
+// repoLog collects the log events of a repository for its log.json,
+// and forwards them to the standard logger.
+type repoLog struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+// newRepoLogger returns a logger with the fields of repository, whose events
+// with level info or more severe are also collected in the returned repoLog.
+func newRepoLogger(repository Repository, traceID string) (*log.Entry, *repoLog) {
+	rl := &repoLog{}
+
+	// The events are forwarded to the standard logger by the hook, so that
+	// they are collected even if the standard logger level filters them out.
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.Level = log.TraceLevel
+	logger.AddHook(rl)
+
+	fields := log.Fields{
+		"repo":      repository.Name,
+		"publisher": repository.Pa.Name,
+		"codiceIPA": repository.Pa.CodiceIPA,
+		"domain":    repository.Domain.Host,
+	}
+	if traceID != "" {
+		fields["traceId"] = traceID
+	}
+
+	return logger.WithFields(fields), rl
+}
+
+// publisherLogger returns a logger with the fields of the publisher pa.
+func publisherLogger(pa PA) *log.Entry {
+	return log.WithFields(log.Fields{"publisher": pa.Name, "codiceIPA": pa.CodiceIPA})
+}
+
+// Levels implements log.Hook.
+func (rl *repoLog) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook.
+func (rl *repoLog) Fire(e *log.Entry) error {
+	if e.Level <= log.InfoLevel {
+		entry := logEntry{
+			Datetime: e.Time.UTC().Format(time.RFC3339),
+			Level:    e.Level.String(),
+			Message:  strings.TrimSpace(e.Message),
+		}
+		if traceID, ok := e.Data["traceId"].(string); ok {
+			entry.TraceID = traceID
+		}
+		for key, value := range e.Data {
+			if contains(repoFields, key) {
+				continue
+			}
+			if entry.Fields == nil {
+				entry.Fields = map[string]interface{}{}
+			}
+			// Errors and durations are marshalled as strings.
+			switch v := value.(type) {
+			case error:
+				value = v.Error()
+			case time.Duration:
+				value = v.String()
+			}
+			entry.Fields[key] = value
+		}
+
+		rl.mu.Lock()
+		rl.entries = append(rl.entries, entry)
+		rl.mu.Unlock()
+	}
+
+	log.StandardLogger().WithFields(e.Data).WithTime(e.Time).Log(e.Level, e.Message)
+
+	return nil
+}
+
+// JSON returns the collected entries for log.json.
+func (rl *repoLog) JSON() ([]byte, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	entries := rl.entries
+	if entries == nil {
+		entries = []logEntry{}
+	}
+
+	return json.Marshal(entries)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoLogger(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	log.SetLevel(log.ErrorLevel)
+	defer log.SetLevel(log.InfoLevel)
+
+	repository := Repository{
+		Name:   "italia/example",
+		Domain: Domain{Host: "github.com"},
+		Pa:     PA{Name: "Comune di Esempio", CodiceIPA: "c_a001"},
+	}
+	logger, repoLog := newRepoLogger(repository, "0123456789abcdef0123456789abcdef")
+
+	logger.Debug("not in log.json")
+	logger.WithField("stage", "fetch").Info("publiccode.yml found")
+	logger.WithFields(log.Fields{
+		"stage":    "validate",
+		"duration": 1500 * time.Millisecond,
+		"error":    errors.New("invalid"),
+	}).Error("BAD publiccode.yml\n")
+
+	data, err := repoLog.JSON()
+	assert.Nil(t, err)
+
+	var entries []logEntry
+	assert.Nil(t, json.Unmarshal(data, &entries))
+
+	// Entries are collected even if the standard logger filters them out.
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "info", entries[0].Level)
+	assert.Equal(t, "publiccode.yml found", entries[0].Message)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", entries[0].TraceID)
+	assert.Equal(t, map[string]interface{}{"stage": "fetch"}, entries[0].Fields)
+
+	assert.Equal(t, "error", entries[1].Level)
+	assert.Equal(t, "BAD publiccode.yml", entries[1].Message)
+	assert.Equal(t, map[string]interface{}{
+		"stage":    "validate",
+		"duration": "1.5s",
+		"error":    "invalid",
+	}, entries[1].Fields)
+}
+
+func TestRepoLoggerEmpty(t *testing.T) {
+	_, repoLog := newRepoLogger(Repository{Name: "italia/example"}, "")
+
+	data, err := repoLog.JSON()
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", string(data))
+}
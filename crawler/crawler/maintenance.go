@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	"github.com/italia/developers-italia-backend/crawler/maintenance"
+	publiccode "github.com/italia/publiccode-parser-go"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maintenanceExpiringDays returns the days before the end of a maintenance
+// contract it's expiring, MAINTENANCE_EXPIRING_DAYS. Defaults to 60 days.
+func maintenanceExpiringDays() int {
+	if viper.IsSet("MAINTENANCE_EXPIRING_DAYS") {
+		return viper.GetInt("MAINTENANCE_EXPIRING_DAYS")
+	}
+
+	return 60
+}
+
+// maintenanceStatus returns the maintenance status of pc at the time now.
+func maintenanceStatus(pc publiccode.PublicCode, now time.Time) maintenance.Status {
+	var contractors []maintenance.Contractor
+	for _, c := range pc.Maintenance.Contractors {
+		contractors = append(contractors, maintenance.Contractor{Name: c.Name, Until: c.UntilString})
+	}
+
+	return maintenance.Compute(pc.Maintenance.Type, contractors, now, maintenanceExpiringDays())
+}
+
+// ExpiringContracts returns the publishers of the software in Elasticsearch
+// whose maintenance contracts are expiring or expired, with their PEC
+// address in IndicePA.
+func ExpiringContracts(elasticClient *es.Client) ([]maintenance.Publisher, error) {
+	var software []maintenance.Software
+	query := elastic.NewBoolQuery("software").
+		Must(es.NewTermQuery("publiccode.maintenance.type", "contract"))
+	fields := es.NewFetchSourceContext(true).
+		Include("slug", "it-riuso-codiceIPA-label", "publiccode.name", "publiccode.url", "publiccode.maintenance.*", "publiccode.it.riuso.codiceIPA")
+	err := elastic.Scroll(viper.GetString("ELASTIC_PUBLICCODE_INDEX"), query, fields, elasticClient, func(hit *es.SearchHit) error {
+		var sw maintenance.Software
+		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
+			log.Error(err)
+			return nil
+		}
+		software = append(software, sw)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The status is computed again, since it may have changed since the crawl.
+	publishers := maintenance.Publishers(software, time.Now(), maintenanceExpiringDays())
+	for i := range publishers {
+		pec, err := publisherPEC(publishers[i].CodiceIPA, elasticClient)
+		if err != nil {
+			return nil, err
+		}
+		if pec == "" {
+			log.WithField("codiceIPA", publishers[i].CodiceIPA).Warn("no PEC address in IndicePA")
+		}
+		publishers[i].PEC = pec
+	}
+
+	return publishers, nil
+}
+
+// publisherPEC returns the PEC address of the administration with codiceIPA
+// in the IndicePA index, empty if not found.
+func publisherPEC(codiceIPA string, elasticClient *es.Client) (string, error) {
+	result, err := elasticClient.Search(viper.GetString("ELASTIC_INDICEPA_INDEX")).
+		Query(es.NewTermQuery("ipa.keyword", codiceIPA)).
+		Size(1).
+		Do(context.Background())
+	if err != nil {
+		if es.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	for _, hit := range result.Hits.Hits {
+		var amm struct {
+			PEC string `json:"pec"`
+		}
+		if err := json.Unmarshal(*hit.Source, &amm); err != nil {
+			return "", err
+		}
+		return amm.PEC, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,35 @@
+package crawler
+
+import (
+	"sync"
+
+	"github.com/italia/developers-italia-backend/crawler/maturity"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	weightsOnce sync.Once
+	weights     maturity.Weights
+)
+
+// maturityWeights returns the weights of the maturity checks in
+// MATURITY_WEIGHTS, which are read once. If they are not valid, the
+// default weights are used.
+func maturityWeights() maturity.Weights {
+	weightsOnce.Do(func() {
+		weights = maturity.DefaultWeights()
+		if !viper.IsSet("MATURITY_WEIGHTS") {
+			return
+		}
+
+		w, err := maturity.ParseWeights(viper.GetStringMap("MATURITY_WEIGHTS"))
+		if err != nil {
+			log.Errorf("invalid MATURITY_WEIGHTS in config.toml, using the default weights: %v", err)
+			return
+		}
+		weights = w
+	})
+
+	return weights
+}
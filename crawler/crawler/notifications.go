@@ -0,0 +1,187 @@
+package crawler
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/ipa"
+	"github.com/italia/developers-italia-backend/crawler/maintenance"
+	"github.com/italia/developers-italia-backend/crawler/notify"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// reportsMaxAge is the age of the reports of the repositories no longer
+// crawled after which they are dropped.
+const reportsMaxAge = 30 * 24 * time.Hour
+
+var (
+	reportsMu sync.Mutex
+	reports   = map[string]notify.Report{}
+
+	previousReportsOnce sync.Once
+	previousReports     *notify.Reports
+)
+
+// reportsPath is the file with the issues found by the crawls.
+func reportsPath() string {
+	return filepath.Join(viper.GetString("CRAWLER_DATADIR"), "reports.json")
+}
+
+// notificationLogPath is the file with the notifications sent.
+func notificationLogPath() string {
+	return filepath.Join(viper.GetString("CRAWLER_DATADIR"), "notifications.json")
+}
+
+// recordReport records the issues found in repository, to be notified to
+// the administration with codiceIPA.
+func recordReport(repository Repository, codiceIPA string, issues []notify.Issue) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+
+	reports[repositoryURL(repository)] = notify.Report{
+		CodiceIPA: strings.TrimSpace(codiceIPA),
+		Time:      time.Now(),
+		Issues:    issues,
+	}
+}
+
+// reported returns whether issue was found in repository by the previous
+// crawls.
+func reported(repository Repository, issue notify.Issue) bool {
+	previousReportsOnce.Do(func() {
+		var err error
+		previousReports, err = notify.LoadReports(reportsPath())
+		if err != nil {
+			log.Errorf("cannot load the previous reports: %v", err)
+			previousReports = &notify.Reports{Repositories: map[string]notify.Report{}}
+		}
+	})
+
+	for _, i := range previousReports.Repositories[repositoryURL(repository)].Issues {
+		if i.Same(issue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// repositoryURL returns the URL of repository shown to the administrations.
+func repositoryURL(repository Repository) string {
+	return strings.TrimSuffix(repository.GitCloneURL, ".git")
+}
+
+// saveReports merges the reports of this crawl with the ones of the
+// previous crawls.
+func saveReports() {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+
+	if len(reports) == 0 {
+		return
+	}
+
+	saved, err := notify.LoadReports(reportsPath())
+	if err != nil {
+		log.Errorf("cannot load the previous reports, replacing them: %v", err)
+		saved = &notify.Reports{Repositories: map[string]notify.Report{}}
+	}
+	for url, report := range reports {
+		saved.Repositories[url] = report
+	}
+	saved.Prune(time.Now(), reportsMaxAge)
+
+	if err := saved.Save(reportsPath()); err != nil {
+		log.Errorf("cannot save the reports: %v", err)
+	}
+}
+
+// Notifications returns the digests of the issues found by the crawls and
+// of the expired maintenance contracts of the software in Elasticsearch, by
+// administration, with their PEC address in IndicePA.
+func Notifications(elasticClient *es.Client) ([]notify.Digest, error) {
+	saved, err := notify.LoadReports(reportsPath())
+	if err != nil {
+		return nil, err
+	}
+	issues := saved.Issues()
+
+	publishers, err := ExpiringContracts(elasticClient)
+	if err != nil {
+		return nil, err
+	}
+	pecs := map[string]string{}
+	for _, p := range publishers {
+		pecs[p.CodiceIPA] = p.PEC
+		for _, c := range p.Contracts {
+			if c.Status != maintenance.Expired {
+				continue
+			}
+			issues[p.CodiceIPA] = append(issues[p.CodiceIPA], notify.Issue{
+				Kind:   notify.MaintenanceExpired,
+				URL:    c.URL,
+				Detail: c.Until,
+			})
+		}
+	}
+
+	digests := notify.Digests(issues)
+	for i := range digests {
+		d := &digests[i]
+		d.Name = ipa.GetAdministrationName(d.CodiceIPA)
+
+		pec, ok := pecs[d.CodiceIPA]
+		if !ok {
+			pec, err = publisherPEC(d.CodiceIPA, elasticClient)
+			if err != nil {
+				return nil, err
+			}
+		}
+		d.PEC = pec
+	}
+
+	return digests, nil
+}
+
+// NewNotifier returns the notifier of the administrations, sending through
+// SMTP_ADDR from NOTIFY_FROM, authenticated by SMTP_USER and
+// SMTP_PASSWORD if set. A recipient gets a digest at most every
+// NOTIFY_INTERVAL (default a week), an issue is notified again after
+// NOTIFY_REPEAT (default 30 days) and the emails are NOTIFY_DELAY apart
+// (default 2 seconds).
+func NewNotifier() (*notify.Notifier, error) {
+	notificationLog, err := notify.LoadLog(notificationLogPath())
+	if err != nil {
+		return nil, err
+	}
+
+	interval := 7 * 24 * time.Hour
+	if viper.IsSet("NOTIFY_INTERVAL") {
+		interval = viper.GetDuration("NOTIFY_INTERVAL")
+	}
+	repeat := 30 * 24 * time.Hour
+	if viper.IsSet("NOTIFY_REPEAT") {
+		repeat = viper.GetDuration("NOTIFY_REPEAT")
+	}
+	delay := 2 * time.Second
+	if viper.IsSet("NOTIFY_DELAY") {
+		delay = viper.GetDuration("NOTIFY_DELAY")
+	}
+
+	sender := notify.NewSMTPSender(
+		viper.GetString("SMTP_ADDR"),
+		viper.GetString("NOTIFY_FROM"),
+		viper.GetString("SMTP_USER"),
+		viper.GetString("SMTP_PASSWORD"))
+
+	return notify.NewNotifier(sender, notificationLog, interval, repeat, delay), nil
+}
+
+// SaveNotificationLog saves the log of the notifications sent by n.
+func SaveNotificationLog(n *notify.Notifier) error {
+	return n.Log.Save(notificationLogPath())
+}
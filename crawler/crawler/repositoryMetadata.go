@@ -0,0 +1,164 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// repositoryMetadata is the data about a repository on its code hosting
+// service, the same for all the services. It's the "repository" section of
+// the software in Elasticsearch.
+type repositoryMetadata struct {
+	Stars         int      `json:"stars"`
+	Forks         int      `json:"forks"`
+	OpenIssues    int      `json:"openIssues"`
+	Language      string   `json:"language,omitempty"`
+	Topics        []string `json:"topics,omitempty"`
+	CreatedAt     string   `json:"createdAt,omitempty"`
+	PushedAt      string   `json:"pushedAt,omitempty"`
+	DefaultBranch string   `json:"defaultBranch,omitempty"`
+	// Size in KiB.
+	Size     int  `json:"size"`
+	Archived bool `json:"archived"`
+	Fork     bool `json:"fork"`
+}
+
+// githubMetadata, gitlabMetadata and bitbucketMetadata are the fields of the
+// metadata of the repositories read from the APIs of the code hosting
+// services, in any of the structs they are marshalled from.
+type githubMetadata struct {
+	StargazersCount int       `json:"stargazers_count"`
+	ForksCount      int       `json:"forks_count"`
+	OpenIssuesCount int       `json:"open_issues_count"`
+	Language        string    `json:"language"`
+	Topics          []string  `json:"topics"`
+	CreatedAt       time.Time `json:"created_at"`
+	PushedAt        time.Time `json:"pushed_at"`
+	DefaultBranch   string    `json:"default_branch"`
+	Size            int       `json:"size"`
+	Archived        bool      `json:"archived"`
+	Fork            bool      `json:"fork"`
+}
+
+type gitlabMetadata struct {
+	StarCount         int           `json:"star_count"`
+	ForksCount        int           `json:"forks_count"`
+	OpenIssuesCount   int           `json:"open_issues_count"`
+	Topics            []string      `json:"topics"`
+	TagList           []interface{} `json:"tag_list"`
+	CreatedAt         time.Time     `json:"created_at"`
+	LastActivityAt    time.Time     `json:"last_activity_at"`
+	DefaultBranch     string        `json:"default_branch"`
+	Archived          bool          `json:"archived"`
+	ForkedFromProject *struct {
+		ID int `json:"id"`
+	} `json:"forked_from_project"`
+}
+
+type bitbucketMetadata struct {
+	Language   string `json:"language"`
+	CreatedOn  string `json:"created_on"`
+	UpdatedOn  string `json:"updated_on"`
+	Mainbranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	// Size in bytes.
+	Size   int `json:"size"`
+	Parent *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+}
+
+// parseRepositoryMetadata returns the metadata of repo read from the API
+// of its code hosting service, or nil if there is none.
+func parseRepositoryMetadata(repo Repository) (*repositoryMetadata, error) {
+	if len(repo.Metadata) == 0 {
+		return nil, nil
+	}
+
+	switch repo.Domain.API() {
+	case "github":
+		var m githubMetadata
+		if err := json.Unmarshal(repo.Metadata, &m); err != nil {
+			return nil, err
+		}
+
+		return &repositoryMetadata{
+			Stars:         m.StargazersCount,
+			Forks:         m.ForksCount,
+			OpenIssues:    m.OpenIssuesCount,
+			Language:      m.Language,
+			Topics:        m.Topics,
+			CreatedAt:     formatMetadataTime(m.CreatedAt),
+			PushedAt:      formatMetadataTime(m.PushedAt),
+			DefaultBranch: m.DefaultBranch,
+			Size:          m.Size,
+			Archived:      m.Archived,
+			Fork:          m.Fork,
+		}, nil
+	case "gitlab":
+		var m gitlabMetadata
+		if err := json.Unmarshal(repo.Metadata, &m); err != nil {
+			return nil, err
+		}
+
+		// tag_list is the name of topics before GitLab 14.
+		topics := m.Topics
+		if len(topics) == 0 {
+			for _, tag := range m.TagList {
+				if s, ok := tag.(string); ok {
+					topics = append(topics, s)
+				}
+			}
+		}
+
+		return &repositoryMetadata{
+			Stars:         m.StarCount,
+			Forks:         m.ForksCount,
+			OpenIssues:    m.OpenIssuesCount,
+			Topics:        topics,
+			CreatedAt:     formatMetadataTime(m.CreatedAt),
+			PushedAt:      formatMetadataTime(m.LastActivityAt),
+			DefaultBranch: m.DefaultBranch,
+			Archived:      m.Archived,
+			Fork:          m.ForkedFromProject != nil && m.ForkedFromProject.ID != 0,
+		}, nil
+	case "bitbucket":
+		var m bitbucketMetadata
+		if err := json.Unmarshal(repo.Metadata, &m); err != nil {
+			return nil, err
+		}
+
+		return &repositoryMetadata{
+			Language:      m.Language,
+			CreatedAt:     parseMetadataTime(m.CreatedOn),
+			PushedAt:      parseMetadataTime(m.UpdatedOn),
+			DefaultBranch: m.Mainbranch.Name,
+			Size:          m.Size / 1024,
+			Fork:          m.Parent != nil && m.Parent.FullName != "",
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown code hosting service %s", repo.Domain.Host)
+}
+
+// parseMetadataTime returns the time s in RFC 3339, without the fractional
+// seconds, or an empty string if it's not valid.
+func parseMetadataTime(s string) string {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return ""
+	}
+
+	return formatMetadataTime(t)
+}
+
+// formatMetadataTime returns t in RFC 3339, or an empty string if it's unknown.
+func formatMetadataTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
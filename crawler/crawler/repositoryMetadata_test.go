@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepositoryMetadata(t *testing.T) {
+	created := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	pushed := time.Date(2020, 6, 30, 8, 30, 0, 0, time.UTC)
+
+	// GitHub
+	var github GithubOrgs
+	assert.Nil(t, json.Unmarshal([]byte(`[{
+		"stargazers_count": 10,
+		"forks_count": 3,
+		"open_issues_count": 2,
+		"language": "Go",
+		"topics": ["pa", "italia"],
+		"created_at": "2019-03-01T10:00:00Z",
+		"pushed_at": "2020-06-30T08:30:00Z",
+		"default_branch": "main",
+		"size": 2048,
+		"fork": true,
+		"watchers_count": 10
+	}]`), &github))
+	metadata, _ := json.Marshal(github[0])
+	m, err := parseRepositoryMetadata(Repository{Domain: Domain{Host: "github.com"}, Metadata: metadata})
+	assert.Nil(t, err)
+	// The lists of repositories have no subscribers_count, and watchers_count
+	// is the number of stars, so there are no watchers.
+	saved, _ := json.Marshal(m)
+	assert.NotContains(t, string(saved), "watchers")
+	assert.Equal(t, &repositoryMetadata{
+		Stars:         10,
+		Forks:         3,
+		OpenIssues:    2,
+		Language:      "Go",
+		Topics:        []string{"pa", "italia"},
+		CreatedAt:     "2019-03-01T10:00:00Z",
+		PushedAt:      "2020-06-30T08:30:00Z",
+		DefaultBranch: "main",
+		Size:          2048,
+		Fork:          true,
+	}, m)
+
+	// GitLab, with the topics in the tag list of older versions.
+	gitlab := GitlabProject{
+		StarCount:      5,
+		ForksCount:     1,
+		TagList:        []interface{}{"comune"},
+		CreatedAt:      created,
+		LastActivityAt: pushed,
+		DefaultBranch:  "master",
+		Archived:       true,
+	}
+	metadata, _ = json.Marshal(gitlab)
+	m, err = parseRepositoryMetadata(Repository{Domain: Domain{Host: "gitlab.com"}, Metadata: metadata})
+	assert.Nil(t, err)
+	assert.Equal(t, &repositoryMetadata{
+		Stars:         5,
+		Forks:         1,
+		Topics:        []string{"comune"},
+		CreatedAt:     "2019-03-01T10:00:00Z",
+		PushedAt:      "2020-06-30T08:30:00Z",
+		DefaultBranch: "master",
+		Archived:      true,
+	}, m)
+
+	gitlab.ForkedFromProject.ID = 42
+	metadata, _ = json.Marshal(gitlab)
+	m, _ = parseRepositoryMetadata(Repository{Domain: Domain{Host: "gitlab.com"}, Metadata: metadata})
+	assert.True(t, m.Fork)
+
+	// Bitbucket
+	var bitbucket Bitbucket
+	assert.Nil(t, json.Unmarshal([]byte(`{"values": [{
+		"language": "php",
+		"created_on": "2019-03-01T10:00:00.123456+00:00",
+		"updated_on": "2020-06-30T10:30:00.5+02:00",
+		"mainbranch": {"name": "default"},
+		"size": 1048576,
+		"parent": {"full_name": "other/repo"}
+	}]}`), &bitbucket))
+	metadata, _ = json.Marshal(bitbucket.Values[0])
+	m, err = parseRepositoryMetadata(Repository{Domain: Domain{Host: "bitbucket.org"}, Metadata: metadata})
+	assert.Nil(t, err)
+	assert.Equal(t, &repositoryMetadata{
+		Language:      "php",
+		CreatedAt:     "2019-03-01T10:00:00Z",
+		PushedAt:      "2020-06-30T08:30:00Z",
+		DefaultBranch: "default",
+		Size:          1024,
+		Fork:          true,
+	}, m)
+
+	// No metadata.
+	m, err = parseRepositoryMetadata(Repository{Domain: Domain{Host: "github.com"}})
+	assert.Nil(t, err)
+	assert.Nil(t, m)
+
+	_, err = parseRepositoryMetadata(Repository{Domain: Domain{Host: "example.org"}, Metadata: []byte("{}")})
+	assert.EqualError(t, err, "unknown code hosting service example.org")
+}
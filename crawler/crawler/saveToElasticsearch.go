@@ -3,14 +3,22 @@ package crawler
 import (
 	"context"
 	"crypto/sha1"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/italia/developers-italia-backend/crawler/history"
 	"github.com/italia/developers-italia-backend/crawler/ipa"
+	"github.com/italia/developers-italia-backend/crawler/linkcheck"
+	"github.com/italia/developers-italia-backend/crawler/maintenance"
+	"github.com/italia/developers-italia-backend/crawler/maturity"
 	"github.com/italia/developers-italia-backend/crawler/metrics"
+	"github.com/italia/developers-italia-backend/crawler/osv"
+	"github.com/italia/developers-italia-backend/crawler/sbom"
+	"github.com/italia/developers-italia-backend/crawler/webhooks"
 	pcode "github.com/italia/publiccode-parser-go"
 	"github.com/olivere/elastic"
 	log "github.com/sirupsen/logrus"
@@ -23,25 +31,38 @@ type administration struct {
 }
 
 // saveToES save the chosen data []byte in elasticsearch
-// data contains the raw publiccode.yml file
-func (c *Crawler) saveToES(repo Repository, activityIndex float64, vitality []int, data []byte) error {
+// data contains the raw publiccode.yml file, found at commit (empty if unknown)
+func (c *Crawler) saveToES(repo Repository, activityIndex float64, vitality []int, commit string, analysis repoAnalysis, data []byte) error {
 	// softwareES represents a software record in Elasticsearch
 	type softwareES struct {
-		FileRawURL            string            `json:"fileRawURL"`
-		ID                    string            `json:"id"`
-		CrawlTime             string            `json:"crawltime"`
-		ItRiusoCodiceIPALabel string            `json:"it-riuso-codiceIPA-label"`
-		Slug                  string            `json:"slug"`
-		PublicCode            interface{}       `json:"publiccode"`
-		VitalityScore         float64           `json:"vitalityScore"`
-		VitalityDataChart     []int             `json:"vitalityDataChart"`
-		OEmbedHTML            map[string]string `json:"oEmbedHTML"`
+		FileRawURL            string              `json:"fileRawURL"`
+		ID                    string              `json:"id"`
+		CrawlTime             string              `json:"crawltime"`
+		FirstSeen             string              `json:"firstSeen"`
+		LastChanged           string              `json:"lastChanged"`
+		ContentHash           string              `json:"contentHash"`
+		ItRiusoCodiceIPALabel string              `json:"it-riuso-codiceIPA-label"`
+		Slug                  string              `json:"slug"`
+		PublicCode            interface{}         `json:"publiccode"`
+		VitalityScore         float64             `json:"vitalityScore"`
+		VitalityDataChart     []int               `json:"vitalityDataChart"`
+		OEmbedHTML            map[string]string   `json:"oEmbedHTML"`
+		Upstream              string              `json:"upstream,omitempty"`
+		Repository            *repositoryMetadata `json:"repository,omitempty"`
+		DetectedLicenses      []string            `json:"detectedLicenses,omitempty"`
+		LicenseMismatch       bool                `json:"licenseMismatch"`
+		Dependencies          []sbom.Dependency   `json:"dependencies,omitempty"`
+		Vulnerabilities       *osv.Counts         `json:"vulnerabilities,omitempty"`
+		Maturity              *maturity.Report    `json:"maturity,omitempty"`
+		BrokenLinks           []linkcheck.Result  `json:"brokenLinks,omitempty"`
+		CodiceIPASuggestions  []ipa.Match         `json:"codiceIPASuggestions,omitempty"`
+		MaintenanceStatus     maintenance.Status  `json:"maintenanceStatus"`
 	}
 
 	// Parse the publiccode.yml file
 	parser := pcode.NewParser()
 	parser.Strict = false
-	parser.RemoteBaseURL = strings.TrimRight(repo.FileRawURL, viper.GetString("CRAWLED_FILENAME"))
+	parser.RemoteBaseURL = remoteBaseURL(repo.FileRawURL)
 	err := parser.ParseInDomain(data, repo.Domain.Host, repo.Domain.UseTokenFor, repo.Domain.BasicAuth)
 	if err != nil {
 		log.Errorf("Error parsing publiccode.yml: %v", err)
@@ -57,6 +78,21 @@ func (c *Crawler) saveToES(repo Repository, activityIndex float64, vitality []in
 		VitalityScore:         activityIndex,
 		VitalityDataChart:     vitality,
 		OEmbedHTML:            parser.OEmbed,
+		Upstream:              repo.Upstream,
+		DetectedLicenses:      analysis.licenses.Detected,
+		LicenseMismatch:       analysis.licenses.Mismatch,
+		Dependencies:          analysis.dependencies,
+		Vulnerabilities:       analysis.vulnerabilities,
+		Maturity:              analysis.maturity,
+		BrokenLinks:           analysis.brokenLinks,
+		CodiceIPASuggestions:  analysis.codiceIPASuggestions,
+		MaintenanceStatus:     maintenanceStatus(parser.PublicCode, time.Now()),
+	}
+
+	// The metadata from the code hosting service are not essential.
+	file.Repository, err = parseRepositoryMetadata(repo)
+	if err != nil {
+		log.WithField("repo", repo.Name).Warnf("Error parsing the repository metadata: %v", err)
 	}
 
 	// Convert parser.PublicCode to YAML and parse it again into the softwareES record
@@ -66,24 +102,65 @@ func (c *Crawler) saveToES(repo Repository, activityIndex float64, vitality []in
 	}
 	err = yaml.Unmarshal(yml, &file.PublicCode)
 
+	// crawltime changes at every crawl, so keep track of when the software
+	// was first seen and when its publiccode.yml last changed.
+	file.ContentHash = fmt.Sprintf("%x", sha1.Sum(yml))
+	previous, err := c.getSoftwareTimes(file.ID)
+	if err != nil {
+		return err
+	}
+	file.FirstSeen, file.LastChanged = changeTimes(previous, file.ContentHash, file.CrawlTime)
+
 	// Put publiccode data in ES.
 	ctx := context.Background()
+	start := time.Now()
 	_, err = c.es.Index().
 		Index(c.index).
 		Type("software").
 		Id(file.ID).
 		BodyJson(file).
 		Do(ctx)
+	metrics.ObserveElasticsearch("index", start)
 	if err != nil {
 		return err
 	}
 
-	metrics.GetCounter("repository_file_indexed", c.index).Inc()
+	if previous == nil || previous.ContentHash != file.ContentHash {
+		// Keep every distinct version of the publiccode.yml, once the
+		// software it belongs to is indexed.
+		err = c.saveVersion(repo, parser, commit, file.ContentHash, file.CrawlTime, yml, data)
+		if err != nil {
+			return err
+		}
+
+		// Notify the subscribers of the new and changed software. The
+		// software indexed before the content hash was tracked just get
+		// it: as in changeTimes, they are assumed not to have changed.
+		if previous == nil || previous.ContentHash != "" {
+			eventType := webhooks.SoftwareUpdated
+			if previous == nil {
+				eventType = webhooks.SoftwareAdded
+			}
+			event := softwareEvent{
+				ID:         file.ID,
+				URL:        repositoryURL(repo),
+				Slug:       file.Slug,
+				Name:       parser.PublicCode.Name,
+				CodiceIPA:  parser.PublicCode.It.Riuso.CodiceIPA,
+				FileRawURL: repo.FileRawURL,
+			}
+			if parser.PublicCode.URL != nil {
+				event.URL = parser.PublicCode.URL.String()
+			}
+			emitEvent(eventType, event)
+		}
+	}
 
 	// Add administration data.
 	if parser.PublicCode.It.Riuso.CodiceIPA != "" {
 		// Put administrations data in ES.
-		_, err = c.es.Index().
+		start = time.Now()
+		res, err := c.es.Index().
 			Index(viper.GetString("ELASTIC_PUBLISHERS_INDEX")).
 			Type("administration").
 			Id(parser.PublicCode.It.Riuso.CodiceIPA).
@@ -92,14 +169,136 @@ func (c *Crawler) saveToES(repo Repository, activityIndex float64, vitality []in
 				CodiceIPA: parser.PublicCode.It.Riuso.CodiceIPA,
 			}).
 			Do(ctx)
+		metrics.ObserveElasticsearch("index_publisher", start)
 		if err != nil {
 			return err
 		}
+		if res.Result == "created" {
+			emitEvent(webhooks.PublisherAdded, publisherEvent{
+				CodiceIPA: parser.PublicCode.It.Riuso.CodiceIPA,
+				Name:      file.ItRiusoCodiceIPALabel,
+			})
+		}
 	}
 
 	return nil
 }
 
+// saveVersion stores a new version of the publiccode.yml of repo in the history
+// index and in CRAWLER_DATADIR.
+func (c *Crawler) saveVersion(repo Repository, parser *pcode.Parser, commit, hash, crawltime string, yml, data []byte) error {
+	version := history.Version{
+		SoftwareID:  repo.generateID(),
+		Commit:      commit,
+		CrawlTime:   crawltime,
+		ContentHash: hash,
+		PublicCode:  string(yml),
+	}
+	if parser.PublicCode.URL != nil {
+		version.URL = parser.PublicCode.URL.String()
+	} else {
+		version.URL = repo.GitCloneURL
+	}
+
+	start := time.Now()
+	err := history.Save(version, viper.GetString("ELASTIC_HISTORY_INDEX"), c.es)
+	metrics.ObserveElasticsearch("index_history", start)
+	if err != nil {
+		return err
+	}
+
+	// The content hash identifies the version when the commit is unknown.
+	if commit == "" {
+		commit = hash
+	}
+	err = SaveToFile(repo.Domain, repo.Hostname, repo.Name, commit, data)
+	if err != nil {
+		log.Errorf("[%s] error saving publiccode.yml: %v", repo.Name, err)
+	}
+
+	return nil
+}
+
+// softwareTimes contains the fields of an indexed software that are needed
+// to track its changes.
+type softwareTimes struct {
+	CrawlTime   string `json:"crawltime"`
+	FirstSeen   string `json:"firstSeen"`
+	LastChanged string `json:"lastChanged"`
+	ContentHash string `json:"contentHash"`
+	PublicCode  struct {
+		ReleaseDate string `json:"releaseDate"`
+	} `json:"publiccode"`
+}
+
+// getSoftwareTimes returns the change tracking fields of the indexed
+// software with the given id, or nil if it's not indexed yet.
+func (c *Crawler) getSoftwareTimes(id string) (*softwareTimes, error) {
+	start := time.Now()
+	res, err := c.es.Get().
+		Index(c.index).
+		Type("software").
+		Id(id).
+		FetchSourceContext(elastic.NewFetchSourceContext(true).Include(
+			"crawltime", "firstSeen", "lastChanged", "contentHash", "publiccode.releaseDate",
+		)).
+		Do(context.Background())
+	metrics.ObserveElasticsearch("get", start)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !res.Found || res.Source == nil {
+		return nil, nil
+	}
+
+	var times softwareTimes
+	if err := json.Unmarshal(*res.Source, &times); err != nil {
+		return nil, err
+	}
+
+	return &times, nil
+}
+
+// changeTimes returns when a software with publiccode.yml hash was first seen
+// and last changed, given its previously indexed version (nil if it's new).
+func changeTimes(previous *softwareTimes, hash, now string) (firstSeen, lastChanged string) {
+	if previous == nil {
+		return now, now
+	}
+
+	firstSeen = previous.FirstSeen
+	if firstSeen == "" {
+		// The software was indexed before we started tracking it: the release
+		// date is the best guess, so that the whole catalog doesn't look new.
+		if t, err := time.Parse("2006-01-02", previous.PublicCode.ReleaseDate); err == nil {
+			firstSeen = t.Format(time.RFC3339)
+		} else {
+			firstSeen = previous.CrawlTime
+		}
+	}
+
+	switch {
+	case previous.ContentHash == "":
+		// We can't tell whether it changed, assume it didn't.
+		lastChanged = previous.CrawlTime
+	case previous.ContentHash != hash:
+		lastChanged = now
+	default:
+		lastChanged = previous.LastChanged
+	}
+	if lastChanged == "" {
+		lastChanged = now
+	}
+	if firstSeen == "" {
+		firstSeen = lastChanged
+	}
+
+	return firstSeen, lastChanged
+}
+
 // generateID generates a hash based on unique git repo URL.
 func (repo *Repository) generateID() string {
 	hash := sha1.New()
@@ -127,8 +326,9 @@ func (repo *Repository) generateSlug() string {
 // DeleteByQueryFromES delete record from elasticsearch
 // that will match search string for publiccode.url field
 func (c *Crawler) DeleteByQueryFromES(search string) error {
-	// Search with a term query
-	termQuery := elastic.NewTermQuery("publiccode.url", search)
+	// Search with a terms query, with and without .git
+	search = strings.TrimSuffix(search, ".git")
+	termQuery := elastic.NewTermsQuery("publiccode.url", search, search+".git")
 
 	// Put publiccode data in ES.
 	ctx := context.Background()
@@ -150,5 +350,7 @@ func (c *Crawler) DeleteByQueryFromES(search string) error {
 	}
 
 	log.Infof("Deleted %d record from ES linked to %s", searchResult.Deleted, search)
+	emitEvent(webhooks.SoftwareRemoved, softwareEvent{URL: search})
+
 	return nil
 }
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/webhooks"
+	"github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeTimes(t *testing.T) {
+	now := "2020-10-20T10:00:00Z"
+	before := "2020-10-19T10:00:00Z"
+	longBefore := "2020-01-01T10:00:00Z"
+
+	// New software.
+	firstSeen, lastChanged := changeTimes(nil, "hash", now)
+	assert.Equal(t, now, firstSeen)
+	assert.Equal(t, now, lastChanged)
+
+	// Unchanged software.
+	previous := &softwareTimes{CrawlTime: before, FirstSeen: longBefore, LastChanged: longBefore, ContentHash: "hash"}
+	firstSeen, lastChanged = changeTimes(previous, "hash", now)
+	assert.Equal(t, longBefore, firstSeen)
+	assert.Equal(t, longBefore, lastChanged)
+
+	// Changed software.
+	firstSeen, lastChanged = changeTimes(previous, "new hash", now)
+	assert.Equal(t, longBefore, firstSeen)
+	assert.Equal(t, now, lastChanged)
+
+	// Software indexed before firstSeen and lastChanged were tracked.
+	previous = &softwareTimes{CrawlTime: before}
+	previous.PublicCode.ReleaseDate = "2019-05-01"
+	firstSeen, lastChanged = changeTimes(previous, "hash", now)
+	assert.Equal(t, "2019-05-01T00:00:00Z", firstSeen)
+	assert.Equal(t, before, lastChanged)
+
+	previous.PublicCode.ReleaseDate = ""
+	firstSeen, _ = changeTimes(previous, "hash", now)
+	assert.Equal(t, before, firstSeen)
+}
+
+func TestSaveToESEvents(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir, err := ioutil.TempDir("", "crawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("CRAWLER_DATADIR", dir)
+	viper.Set("CRAWLED_FILENAME", "publiccode.yml")
+	viper.Set("ELASTIC_HISTORY_INDEX", "history")
+	viper.Set("WEBHOOKS", []map[string]interface{}{{"url": "https://example.org/hook"}})
+	defer viper.Set("WEBHOOKS", nil)
+	dispatcherOnce, dispatcher = sync.Once{}, nil
+	defer func() { dispatcherOnce, dispatcher = sync.Once{}, nil }()
+
+	// A stand-in for Elasticsearch, with the previously indexed software.
+	var previous map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
+				"_index": "publiccode", "_type": "software", "_id": "id", "found": true, "_source": previous,
+			})
+			return
+		}
+		fmt.Fprint(w, `{"_index": "publiccode", "_type": "software", "_id": "id", "result": "updated"}`)
+	}))
+	defer server.Close()
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Crawler{es: client, index: "publiccode"}
+	repo := Repository{
+		Name:        "italia/app",
+		Hostname:    "github.com",
+		GitCloneURL: "https://github.com/italia/app.git",
+		Domain:      Domain{Host: "github.com"},
+	}
+	outbox, err := webhooks.NewOutbox(filepath.Join(dir, "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The software indexed before the content hash was tracked get it,
+	// without an event.
+	previous = map[string]interface{}{"crawltime": "2020-10-19T10:00:00Z"}
+	assert.Nil(t, c.saveToES(repo, 0, nil, "", repoAnalysis{}, []byte("name: app\n")))
+	deliveries, err := outbox.List()
+	assert.Nil(t, err)
+	assert.Empty(t, deliveries)
+
+	// The changed software have one.
+	previous["contentHash"] = "other"
+	assert.Nil(t, c.saveToES(repo, 0, nil, "", repoAnalysis{}, []byte("name: app\n")))
+	deliveries, err = outbox.List()
+	assert.Nil(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, webhooks.SoftwareUpdated, deliveries[0].Event.Type)
+	}
+}
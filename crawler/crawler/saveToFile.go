@@ -14,16 +14,20 @@ import (
 	"github.com/spf13/viper"
 )
 
-// SaveToFile save the chosen <file_name> in DATADIR/repos/<source>/<vendor>/<repo>/<crawler_timestamp>_<file_name>.
-func SaveToFile(domain Domain, hostname string, name string, data []byte, index string) error {
+// SaveToFile save the version of the chosen <file_name> in DATADIR/<source>/<vendor>/<repo>/<version>_<file_name>.
+// version is the commit the file was found at, so that every version is kept.
+func SaveToFile(domain Domain, hostname string, name string, version string, data []byte) error {
 	if domain.Host == "" {
 		return errors.New("cannot save a file without domain host")
 	}
 	if name == "" {
 		return errors.New("cannot save a file without name")
 	}
+	if version == "" {
+		return errors.New("cannot save a file without version")
+	}
 
-	fileName := index + "_" + viper.GetString("CRAWLED_FILENAME")
+	fileName := version + "_" + viper.GetString("CRAWLED_FILENAME")
 	vendor, repo := splitFullName(name)
 
 	path := filepath.Join(viper.GetString("CRAWLER_DATADIR"), hostname, vendor, repo)
@@ -41,7 +45,7 @@ func SaveToFile(domain Domain, hostname string, name string, data []byte, index
 		return err
 	}
 
-	metrics.GetCounter("repository_file_saved", index).Inc()
+	metrics.FilesSaved.Inc()
 	return err
 }
 
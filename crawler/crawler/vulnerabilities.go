@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/italia/developers-italia-backend/crawler/osv"
+	"github.com/italia/developers-italia-backend/crawler/sbom"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	osvOnce     sync.Once
+	osvDatabase *osv.Database
+)
+
+// GetOSVDatabase returns the OSV database in OSV_DATADIR, which is loaded once.
+// It's nil if OSV_DATADIR is not set or the database can't be loaded.
+func GetOSVDatabase() *osv.Database {
+	osvOnce.Do(func() {
+		dir := viper.GetString("OSV_DATADIR")
+		if dir == "" {
+			log.Debug("OSV_DATADIR is not defined in config.toml, not matching vulnerabilities")
+			return
+		}
+
+		db, err := osv.Load(dir)
+		if err != nil {
+			log.Errorf("cannot load the OSV database: %v", err)
+			return
+		}
+		log.Infof("Loaded %d vulnerabilities from %s", db.Len(), dir)
+		osvDatabase = db
+	})
+
+	return osvDatabase
+}
+
+// RepoVulnerabilities returns the known vulnerabilities of the dependencies
+// in the clone of the repository at repoURL, which must have been crawled.
+func RepoVulnerabilities(repoURL string) ([]osv.Finding, error) {
+	db := GetOSVDatabase()
+	if db == nil {
+		return nil, errors.New("no OSV database, please set OSV_DATADIR in config.toml")
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Hostname() == "" || !strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid repository URL %s", repoURL)
+	}
+
+	dir := clonePath(u.Hostname(), name)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("%s has not been cloned yet, crawl it first: %v", repoURL, err)
+	}
+
+	// Invalid manifests are just skipped, as when crawling.
+	components, err := sbom.Extract(dir)
+	if components == nil {
+		return nil, err
+	}
+	if err != nil {
+		log.Warn(err)
+	}
+
+	return db.Check(components), nil
+}
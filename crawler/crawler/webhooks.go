@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/version"
+	"github.com/italia/developers-italia-backend/crawler/webhooks"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	dispatcherOnce sync.Once
+	dispatcher     *webhooks.Dispatcher
+)
+
+// softwareEvent is the data of the events of a software.
+type softwareEvent struct {
+	ID         string `json:"id,omitempty"`
+	URL        string `json:"url"`
+	Slug       string `json:"slug,omitempty"`
+	Name       string `json:"name,omitempty"`
+	CodiceIPA  string `json:"codiceIPA,omitempty"`
+	FileRawURL string `json:"fileRawURL,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// publisherEvent is the data of the events of a publisher.
+type publisherEvent struct {
+	CodiceIPA string `json:"codiceIPA"`
+	Name      string `json:"name"`
+}
+
+// GetDispatcher returns the dispatcher of the events to the subscribers in
+// WEBHOOKS, with the deliveries in CRAWLER_DATADIR/outbox. A delivery is
+// attempted WEBHOOKS_MAX_ATTEMPTS times (default 10), waiting from
+// WEBHOOKS_BACKOFF (default 1 minute) up to WEBHOOKS_MAX_BACKOFF (default 6
+// hours) between the attempts. It's nil if there are no subscribers.
+func GetDispatcher() *webhooks.Dispatcher {
+	dispatcherOnce.Do(func() {
+		var subscribers []webhooks.Subscriber
+		if err := viper.UnmarshalKey("WEBHOOKS", &subscribers); err != nil {
+			log.Errorf("invalid WEBHOOKS, no events will be sent: %v", err)
+			return
+		}
+		if len(subscribers) == 0 {
+			return
+		}
+
+		outbox, err := webhooks.NewOutbox(filepath.Join(viper.GetString("CRAWLER_DATADIR"), "outbox"))
+		if err != nil {
+			log.Errorf("cannot create the outbox, no events will be sent: %v", err)
+			return
+		}
+
+		timeout := 10 * time.Second
+		if viper.IsSet("WEBHOOKS_TIMEOUT") {
+			timeout = viper.GetDuration("WEBHOOKS_TIMEOUT")
+		}
+		maxAttempts := 10
+		if viper.IsSet("WEBHOOKS_MAX_ATTEMPTS") {
+			maxAttempts = viper.GetInt("WEBHOOKS_MAX_ATTEMPTS")
+		}
+		backoff := time.Minute
+		if viper.IsSet("WEBHOOKS_BACKOFF") {
+			backoff = viper.GetDuration("WEBHOOKS_BACKOFF")
+		}
+		maxBackoff := 6 * time.Hour
+		if viper.IsSet("WEBHOOKS_MAX_BACKOFF") {
+			maxBackoff = viper.GetDuration("WEBHOOKS_MAX_BACKOFF")
+		}
+
+		dispatcher = webhooks.NewDispatcher(subscribers, outbox, timeout, maxAttempts, backoff, maxBackoff)
+		dispatcher.UserAgent = "developers-italia-backend/" + version.VERSION
+	})
+
+	return dispatcher
+}
+
+// emitEvent writes the event of type eventType with data to the outbox, if
+// there are subscribers.
+func emitEvent(eventType string, data interface{}) {
+	d := GetDispatcher()
+	if d == nil {
+		return
+	}
+	if err := d.Emit(eventType, data); err != nil {
+		log.WithField("event", eventType).Errorf("cannot write the event to the outbox: %v", err)
+	}
+}
+
+// DeliverEvents sends the events in the outbox to the subscribers. The
+// deliveries failed are retried by the following runs.
+func DeliverEvents() {
+	d := GetDispatcher()
+	if d == nil {
+		return
+	}
+
+	sent, dropped, err := d.Deliver()
+	if err != nil {
+		log.Errorf("cannot deliver the events: %v", err)
+	}
+	log.Infof("%d events delivered, %d given up", sent, dropped)
+}
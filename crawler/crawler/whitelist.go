@@ -3,25 +3,92 @@ package crawler
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 var fileReaderInject = ioutil.ReadFile
 
+// WhitelistVersion is the latest version of the whitelist schema.
+//
+// Version 1 files are a plain list of publishers, version 2 files are a
+// mapping with the version and the publishers, whose orgs and repos can
+// have options.
+const WhitelistVersion = 2
+
 // Whitelist contain a list of Public Administrations.
 type Whitelist []PA
 
+// whitelistFile is the content of a version 2 whitelist file.
+type whitelistFile struct {
+	Version    int  `yaml:"version"`
+	Publishers []PA `yaml:"publishers"`
+}
+
 // PA is a Public Administration.
 type PA struct {
-	Name          string   `yaml:"name"`
-	CodiceIPA     string   `yaml:"codice-iPA"`
-	Organizations []string `yaml:"orgs"`
-	Repositories  []string `yaml:"repos"`
-	UnknownIPA    bool     `yaml:"unknown-iPA"`
+	Name          string          `yaml:"name"`
+	CodiceIPA     string          `yaml:"codice-iPA"`
+	Organizations []Org           `yaml:"orgs"`
+	Repositories  []WhitelistRepo `yaml:"repos"`
+	UnknownIPA    bool            `yaml:"unknown-iPA"`
+	Contacts      []Contact       `yaml:"contacts,omitempty"`
+}
+
+// Contact is a person or office to contact about the software of a publisher.
+type Contact struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email,omitempty"`
+	Phone string `yaml:"phone,omitempty"`
+}
+
+// CrawlOptions tell which repositories of an organization are crawled and
+// where their publiccode.yml is.
+type CrawlOptions struct {
+	// Include and Exclude are glob patterns matching the names of the
+	// repositories, without the organization.
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// Forks and Archived include the forked and archived repositories.
+	Forks    bool `yaml:"forks,omitempty"`
+	Archived bool `yaml:"archived,omitempty"`
+
+	// Branch is crawled instead of the default branch.
+	Branch string `yaml:"branch,omitempty"`
+
+	// PubliccodePath is the path of the publiccode.yml in the repository.
+	PubliccodePath string `yaml:"publiccode-path,omitempty"`
+}
+
+// Org is an organization in a whitelist, written as its URL or as a
+// mapping with the URL and its CrawlOptions.
+type Org struct {
+	URL          string `yaml:"url"`
+	CrawlOptions `yaml:",inline"`
+}
+
+// WhitelistRepo is a repository in a whitelist, written as its URL or as a
+// mapping with the URL, the branch, the publiccode.yml path and whether
+// it's crawled when archived.
+type WhitelistRepo struct {
+	URL          string `yaml:"url"`
+	CrawlOptions `yaml:",inline"`
 }
 
+// whitelistPAFields, whitelistOrgFields and whitelistRepoFields are the keys
+// allowed in the mappings of PA, Org and WhitelistRepo.
+var (
+	whitelistPAFields   = []string{"name", "codice-iPA", "orgs", "repos", "unknown-iPA", "contacts"}
+	whitelistOrgFields  = []string{"url", "include", "exclude", "forks", "archived", "branch", "publiccode-path"}
+	whitelistRepoFields = []string{"url", "archived", "branch", "publiccode-path"}
+)
+
 // ReadAndParseWhitelist read the whitelist and return the parsed content in a slice of PA.
 func ReadAndParseWhitelist(whitelistFile string) ([]PA, error) {
 	// Open and read whitelist file.
@@ -41,14 +108,250 @@ func ReadAndParseWhitelist(whitelistFile string) ([]PA, error) {
 }
 
 // parseWhitelistFile parses the whitelist file to build a slice of PA.
+// The errors point to the line of the file.
 func parseWhitelistFile(data []byte) ([]PA, error) {
-	var whitelist []PA
-
-	// Unmarshal the yml in domains list.
-	err := yaml.Unmarshal(data, &whitelist)
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
 	if err != nil {
 		return nil, err
 	}
+	// Empty file.
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
 
-	return whitelist, err
+	// Version 1: a list of publishers. The unknown fields were always
+	// ignored, so they are only warned about.
+	if root.Kind == yaml.SequenceNode {
+		type publisherV1 PA
+		var publishers []publisherV1
+		if err := root.Decode(&publishers); err != nil {
+			return nil, err
+		}
+		for _, node := range root.Content {
+			if err := checkFields(node, whitelistPAFields...); err != nil {
+				log.Warnf("%v, ignored", err)
+			}
+		}
+
+		whitelist := make([]PA, len(publishers))
+		for i := range publishers {
+			whitelist[i] = PA(publishers[i])
+			for j := range whitelist[i].Organizations {
+				org := &whitelist[i].Organizations[j]
+				org.CrawlOptions = NewOrg(org.URL).CrawlOptions
+			}
+			for j := range whitelist[i].Repositories {
+				repo := &whitelist[i].Repositories[j]
+				repo.Archived = crawledArchived(repo.URL)
+			}
+		}
+
+		return whitelist, nil
+	}
+
+	if err := checkFields(root, "version", "publishers"); err != nil {
+		return nil, err
+	}
+	var header struct {
+		Version int `yaml:"version"`
+	}
+	if err := root.Decode(&header); err != nil {
+		return nil, err
+	}
+	switch header.Version {
+	case WhitelistVersion:
+	case 1:
+		return nil, fmt.Errorf("line %d: a version 1 whitelist must be a plain list of publishers, without version", root.Line)
+	default:
+		return nil, fmt.Errorf("line %d: unsupported whitelist version %d (supported: 1, %d)",
+			root.Line, header.Version, WhitelistVersion)
+	}
+
+	// The version 2 whitelists are checked strictly.
+	var whitelist whitelistFile
+	if err := root.Decode(&whitelist); err != nil {
+		return nil, err
+	}
+
+	return whitelist.Publishers, nil
+}
+
+// NewOrg returns the organization at url with the options of the version 1
+// whitelists: the forks were always crawled, and the archived repositories
+// were skipped only on GitHub.
+func NewOrg(url string) Org {
+	return Org{URL: url, CrawlOptions: CrawlOptions{Forks: true, Archived: crawledArchived(url)}}
+}
+
+// crawledArchived returns whether the version 1 whitelists crawled the
+// archived repositories at url.
+func crawledArchived(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	return !strings.Contains(strings.ToLower(parsed.Hostname()), "github")
+}
+
+// MarshalWhitelist returns the whitelist of publishers as a version 2 file.
+func MarshalWhitelist(publishers []PA) ([]byte, error) {
+	return yaml.Marshal(whitelistFile{Version: WhitelistVersion, Publishers: publishers})
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (pa *PA) UnmarshalYAML(node *yaml.Node) error {
+	if err := checkFields(node, whitelistPAFields...); err != nil {
+		return err
+	}
+
+	type publisher PA
+	return node.Decode((*publisher)(pa))
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (o *Org) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&o.URL)
+	}
+	if err := checkFields(node, whitelistOrgFields...); err != nil {
+		return err
+	}
+
+	type org Org
+	if err := node.Decode((*org)(o)); err != nil {
+		return err
+	}
+
+	return o.validate(node.Line)
+}
+
+// MarshalYAML implements yaml.Marshaler, writing just the URL if the
+// organization has no options.
+func (o Org) MarshalYAML() (interface{}, error) {
+	if o.isZero() {
+		return o.URL, nil
+	}
+
+	type org Org
+	return org(o), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (r *WhitelistRepo) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.URL)
+	}
+	if err := checkFields(node, whitelistRepoFields...); err != nil {
+		return err
+	}
+
+	type repo WhitelistRepo
+	if err := node.Decode((*repo)(r)); err != nil {
+		return err
+	}
+	if r.URL == "" {
+		return fmt.Errorf("line %d: repo without url", node.Line)
+	}
+
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler, writing just the URL if the
+// repository has no options.
+func (r WhitelistRepo) MarshalYAML() (interface{}, error) {
+	if r.isZero() {
+		return r.URL, nil
+	}
+
+	type repo WhitelistRepo
+	return repo(r), nil
+}
+
+func (o Org) validate(line int) error {
+	if o.URL == "" {
+		return fmt.Errorf("line %d: org without url", line)
+	}
+	for _, patterns := range [][]string{o.Include, o.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("line %d: invalid pattern %q for org %s: %v", line, pattern, o.URL, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isZero returns true if there are no options.
+func (o CrawlOptions) isZero() bool {
+	return len(o.Include) == 0 && len(o.Exclude) == 0 && !o.Forks && !o.Archived &&
+		o.Branch == "" && o.PubliccodePath == ""
+}
+
+// skip returns why the repository name of an organization is not crawled,
+// or an empty string if it is.
+func (o CrawlOptions) skip(name string, fork, archived bool) string {
+	name = path.Base(name)
+
+	if fork && !o.Forks {
+		return "fork"
+	}
+	if archived && !o.Archived {
+		return "archived"
+	}
+	if len(o.Include) > 0 && !matchAny(o.Include, name) {
+		return "not included"
+	}
+	if matchAny(o.Exclude, name) {
+		return "excluded"
+	}
+
+	return ""
+}
+
+// branch returns the branch to crawl, given the default branch.
+func (o CrawlOptions) branch(defaultBranch string) string {
+	if o.Branch != "" {
+		return o.Branch
+	}
+
+	return defaultBranch
+}
+
+// filename returns the path of the publiccode.yml in the repository.
+func (o CrawlOptions) filename() string {
+	if o.PubliccodePath != "" {
+		return o.PubliccodePath
+	}
+
+	return viper.GetString("CRAWLED_FILENAME")
+}
+
+// checkFields returns an error if the mapping node has keys other than fields,
+// so that typos don't go unnoticed.
+func checkFields(node *yaml.Node, fields ...string) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !contains(fields, key.Value) {
+			return fmt.Errorf("line %d: unknown field %q", key.Line, key.Value)
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
 }
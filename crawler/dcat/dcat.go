@@ -0,0 +1,347 @@
+// Package dcat exports the catalog for the open data portals, as a
+// DCAT-AP_IT catalog (RDF/XML and Turtle) where every software is a dataset
+// and as a schema.org SoftwareSourceCode JSON-LD feed.
+package dcat
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Exporter exports the catalog in DCAT-AP_IT and schema.org formats.
+type Exporter struct{}
+
+const (
+	catalogTitle       = "Catalogo del software a riuso e open source per la Pubblica Amministrazione"
+	catalogDescription = "Il software a riuso delle Pubbliche Amministrazioni e il software open source di interesse per la Pubblica Amministrazione."
+)
+
+// Catalog is the catalog of software, mapped to the DCAT-AP_IT model.
+type Catalog struct {
+	URI         string
+	Title       string
+	Description string
+	Homepage    string
+	// Modified is the date of the most recently crawled software.
+	Modified  string
+	Publisher *Agent
+	Datasets  []Dataset
+}
+
+// Dataset is a software in the catalog.
+type Dataset struct {
+	URI         string
+	ID          string
+	Title       string
+	Description string
+	// Lang is the language of Title and Description.
+	Lang        string
+	LandingPage string
+	Repository  string
+	Version     string
+	Issued      string
+	Modified    string
+	Keywords    []string
+	Licenses    []License
+	Publisher   *Agent
+}
+
+// Agent is an organization publishing a catalog or a software.
+type Agent struct {
+	Name string
+	// Identifier is the iPA code of the administration, if any.
+	Identifier string
+}
+
+// License is a license from the SPDX list.
+type License struct {
+	ID  string
+	URI string
+}
+
+// software contains the fields of the software objects stored in
+// Elasticsearch that are exported.
+type software struct {
+	ID         string `json:"id"`
+	Slug       string `json:"slug"`
+	CrawlTime  string `json:"crawltime"`
+	IPALabel   string `json:"it-riuso-codiceIPA-label"`
+	PublicCode struct {
+		Name            string   `json:"name"`
+		URL             string   `json:"url"`
+		LandingURL      string   `json:"landingURL"`
+		SoftwareVersion string   `json:"softwareVersion"`
+		ReleaseDate     string   `json:"releaseDate"`
+		Categories      []string `json:"categories"`
+		Description     map[string]struct {
+			LocalisedName    string `json:"localisedName"`
+			ShortDescription string `json:"shortDescription"`
+		} `json:"description"`
+		Legal struct {
+			License            string `json:"license"`
+			MainCopyrightOwner string `json:"mainCopyrightOwner"`
+		} `json:"legal"`
+		It struct {
+			Riuso struct {
+				CodiceIPA string `json:"codiceIPA"`
+			} `json:"riuso"`
+		} `json:"it"`
+	} `json:"publiccode"`
+}
+
+// Files written by the exporter.
+const (
+	rdfFile    = "catalog.rdf"
+	turtleFile = "catalog.ttl"
+	jsonLDFile = "software.jsonld"
+)
+
+// Export writes the catalog to outputDir. It returns the number of software
+// in Elasticsearch when the export started, less the ones skipped because
+// they are not valid.
+func (Exporter) Export(outputDir string, elasticClient *es.Client) (int64, error) {
+	siteURL := strings.TrimRight(viper.GetString("SITE_URL"), "/")
+	if siteURL == "" {
+		return 0, errors.New("SITE_URL is not set")
+	}
+
+	cat := newCatalog(siteURL)
+	var skipped int64
+	query := elastic.NewBoolQuery("software")
+	total, err := elastic.ScrollTotal(viper.GetString("ELASTIC_PUBLICCODE_INDEX"), query, nil, elasticClient, func(hit *es.SearchHit) error {
+		var sw software
+		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
+			log.Errorf("Skipping software %s: %v", hit.Id, err)
+			skipped++
+			return nil
+		}
+		cat.add(&sw)
+		return nil
+	})
+	if err != nil {
+		return total, err
+	}
+	total -= skipped
+	cat.sort()
+
+	for filename, write := range map[string]func(io.Writer, *Catalog) error{
+		rdfFile:    WriteRDF,
+		turtleFile: WriteTurtle,
+		jsonLDFile: WriteJSONLD,
+	} {
+		log.Infof("Generating %s", path.Join(outputDir, filename))
+		if err := writeFile(path.Join(outputDir, filename), cat, write); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Validate checks that the RDF/XML catalog and the JSON-LD feed contain all the software.
+func (Exporter) Validate(outputDir string, count int64) error {
+	f, err := os.Open(path.Join(outputDir, rdfFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var datasets int64
+	decoder := xml.NewDecoder(f)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s is not valid XML: %v", rdfFile, err)
+		}
+		if el, ok := token.(xml.StartElement); ok && el.Name.Space == dcatapitNS && el.Name.Local == "Dataset" {
+			datasets++
+		}
+	}
+	if datasets != count {
+		return fmt.Errorf("%s contains %d datasets, %d expected", rdfFile, datasets, count)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(outputDir, jsonLDFile))
+	if err != nil {
+		return err
+	}
+	var feed struct {
+		Elements []interface{} `json:"dataFeedElement"`
+	}
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return err
+	}
+	if int64(len(feed.Elements)) != count {
+		return fmt.Errorf("%s contains %d software, %d expected", jsonLDFile, len(feed.Elements), count)
+	}
+
+	return nil
+}
+
+func newCatalog(siteURL string) *Catalog {
+	cat := Catalog{
+		URI:         siteURL + "/catalog",
+		Title:       catalogTitle,
+		Description: catalogDescription,
+		Homepage:    siteURL,
+	}
+	if name := viper.GetString("DCAT_PUBLISHER_NAME"); name != "" {
+		cat.Publisher = &Agent{Name: name, Identifier: viper.GetString("DCAT_PUBLISHER_IPA")}
+	}
+
+	return &cat
+}
+
+// add maps a software to a dataset and adds it to the catalog.
+func (cat *Catalog) add(sw *software) {
+	pc := &sw.PublicCode
+
+	d := Dataset{
+		URI:         cat.Homepage + "/it/software/" + sw.Slug,
+		ID:          sw.ID,
+		Title:       pc.Name,
+		LandingPage: pc.LandingURL,
+		Repository:  pc.URL,
+		Version:     pc.SoftwareVersion,
+		Issued:      date(pc.ReleaseDate),
+		Modified:    date(sw.CrawlTime),
+		Keywords:    pc.Categories,
+		Licenses:    licenses(pc.Legal.License),
+	}
+	if d.LandingPage == "" {
+		d.LandingPage = pc.URL
+	}
+
+	// Prefer the Italian description, then the English one.
+	d.Lang = descriptionLang(sw)
+	if desc, ok := pc.Description[d.Lang]; ok {
+		if desc.LocalisedName != "" {
+			d.Title = desc.LocalisedName
+		}
+		d.Description = strings.TrimSpace(desc.ShortDescription)
+	}
+	// The description is mandatory in DCAT-AP_IT.
+	if d.Description == "" {
+		d.Description = d.Title
+	}
+
+	if codiceIPA := strings.ToLower(pc.It.Riuso.CodiceIPA); codiceIPA != "" {
+		name := sw.IPALabel
+		if name == "" {
+			name = codiceIPA
+		}
+		d.Publisher = &Agent{Name: name, Identifier: codiceIPA}
+	} else if pc.Legal.MainCopyrightOwner != "" {
+		d.Publisher = &Agent{Name: pc.Legal.MainCopyrightOwner}
+	}
+
+	if d.Modified > cat.Modified {
+		cat.Modified = d.Modified
+	}
+
+	cat.Datasets = append(cat.Datasets, d)
+}
+
+// sort makes the output stable.
+func (cat *Catalog) sort() {
+	sort.Slice(cat.Datasets, func(i, j int) bool { return cat.Datasets[i].URI < cat.Datasets[j].URI })
+}
+
+func descriptionLang(sw *software) string {
+	for _, lang := range []string{"it", "en"} {
+		if _, ok := sw.PublicCode.Description[lang]; ok {
+			return lang
+		}
+	}
+
+	// The language ends up unescaped in the Turtle language tags, skip the
+	// keys that aren't well-formed BCP 47 tags.
+	var langs []string
+	for lang := range sw.PublicCode.Description {
+		if langTagRegexp.MatchString(lang) {
+			langs = append(langs, lang)
+		}
+	}
+	if len(langs) == 0 {
+		return "it"
+	}
+	sort.Strings(langs)
+
+	return langs[0]
+}
+
+// date returns the date (YYYY-MM-DD) in a date or RFC3339 timestamp, or "".
+func date(s string) string {
+	if len(s) < 10 {
+		return ""
+	}
+	if _, err := time.Parse("2006-01-02", s[:10]); err != nil {
+		return ""
+	}
+
+	return s[:10]
+}
+
+var spdxIDRegexp = regexp.MustCompile(`[A-Za-z0-9.+-]+`)
+
+// langTagRegexp matches the syntax of the BCP 47 language tags.
+var langTagRegexp = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// licenses returns the licenses in an SPDX license expression
+// (eg. "MIT OR Apache-2.0", "GPL-2.0-only WITH Classpath-exception-2.0").
+func licenses(expression string) []License {
+	var list []License
+	seen := map[string]bool{}
+
+	ids := spdxIDRegexp.FindAllString(expression, -1)
+	for i := 0; i < len(ids); i++ {
+		id := ids[i]
+		switch strings.ToUpper(id) {
+		case "AND", "OR":
+			continue
+		case "WITH":
+			// Skip the exception, it's not a license.
+			i++
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		list = append(list, License{ID: id, URI: "http://spdx.org/licenses/" + strings.TrimSuffix(id, "+")})
+	}
+
+	return list
+}
+
+func writeFile(filename string, cat *Catalog, write func(io.Writer, *Catalog) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f, cat); err != nil {
+		f.Close() // nolint: errcheck
+		return err
+	}
+
+	return f.Close()
+}
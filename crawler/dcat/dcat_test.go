@@ -0,0 +1,145 @@
+package dcat
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic/elastictest"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the expected files in testdata")
+
+func TestExport(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+	viper.Set("SITE_URL", "https://developers.italia.it/")
+	viper.Set("DCAT_PUBLISHER_NAME", "Agenzia per l'Italia Digitale")
+	viper.Set("DCAT_PUBLISHER_IPA", "agid")
+
+	data, err := ioutil.ReadFile("testdata/software.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []interface{}
+	if err := json.Unmarshal(data, &docs); err != nil {
+		t.Fatal(err)
+	}
+
+	server, client := elastictest.NewClient(t, docs)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "dcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	count, err := Exporter{}.Export(dir, client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+
+	for _, filename := range []string{rdfFile, turtleFile, jsonLDFile} {
+		actual, err := ioutil.ReadFile(path.Join(dir, filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err := ioutil.WriteFile(path.Join("testdata", filename), actual, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected, err := ioutil.ReadFile(path.Join("testdata", filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(expected), string(actual), filename)
+	}
+
+	assert.Nil(t, Exporter{}.Validate(dir, 3))
+	assert.EqualError(t, Exporter{}.Validate(dir, 4), "catalog.rdf contains 3 datasets, 4 expected")
+}
+
+func TestLicenses(t *testing.T) {
+	assert.Equal(t, []License{{"MIT", "http://spdx.org/licenses/MIT"}}, licenses("MIT"))
+	assert.Equal(t, []License{
+		{"EUPL-1.2", "http://spdx.org/licenses/EUPL-1.2"},
+		{"GPL-2.0-only", "http://spdx.org/licenses/GPL-2.0-only"},
+	}, licenses("(EUPL-1.2 OR GPL-2.0-only WITH Classpath-exception-2.0) AND EUPL-1.2"))
+	assert.Empty(t, licenses(""))
+}
+
+func TestAddWithoutURLs(t *testing.T) {
+	var sw software
+	err := json.Unmarshal([]byte(`{
+		"id": "1",
+		"slug": "sw",
+		"publiccode": {
+			"name": "Software",
+			"description": {
+				"en\"@evil": {"shortDescription": "Bad tag"},
+				"de-AT": {"shortDescription": "Beschreibung"}
+			}
+		}
+	}`), &sw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cat := newCatalog("https://developers.italia.it")
+	cat.add(&sw)
+	assert.Equal(t, "de-AT", cat.Datasets[0].Lang)
+	assert.Equal(t, "", cat.Datasets[0].LandingPage)
+
+	var rdf, turtle, jsonld strings.Builder
+	assert.Nil(t, WriteRDF(&rdf, cat))
+	assert.Nil(t, WriteTurtle(&turtle, cat))
+	assert.Nil(t, WriteJSONLD(&jsonld, cat))
+	assert.NotContains(t, rdf.String(), "landingPage")
+	assert.NotContains(t, turtle.String(), "landingPage")
+	assert.Contains(t, turtle.String(), `"Beschreibung"@de-AT ;`)
+	assert.NotContains(t, jsonld.String(), `"url": ""`)
+
+	// Without valid languages, Italian.
+	delete(sw.PublicCode.Description, "de-AT")
+	assert.Equal(t, "it", descriptionLang(&sw))
+}
+
+func TestExportSkipped(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+	viper.Set("SITE_URL", "https://developers.italia.it/")
+
+	data, err := ioutil.ReadFile("testdata/software.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []interface{}
+	if err := json.Unmarshal(data, &docs); err != nil {
+		t.Fatal(err)
+	}
+	docs = append(docs, map[string]interface{}{"id": "invalid", "publiccode": "invalid"})
+
+	server, client := elastictest.NewClient(t, docs)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "dcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The invalid software is left out of the export, which is still valid.
+	count, err := Exporter{}.Export(dir, client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Nil(t, Exporter{}.Validate(dir, count))
+}
@@ -0,0 +1,99 @@
+package dcat
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// schema.org types, see https://schema.org/SoftwareSourceCode.
+type dataFeed struct {
+	Context      string               `json:"@context"`
+	Type         string               `json:"@type"`
+	ID           string               `json:"@id"`
+	Name         string               `json:"name"`
+	Description  string               `json:"description"`
+	URL          string               `json:"url"`
+	DateModified string               `json:"dateModified,omitempty"`
+	Publisher    *organization        `json:"publisher,omitempty"`
+	Elements     []softwareSourceCode `json:"dataFeedElement"`
+}
+
+type softwareSourceCode struct {
+	Type           string        `json:"@type"`
+	ID             string        `json:"@id"`
+	Identifier     string        `json:"identifier"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description,omitempty"`
+	InLanguage     string        `json:"inLanguage"`
+	URL            string        `json:"url,omitempty"`
+	CodeRepository string        `json:"codeRepository"`
+	Version        string        `json:"softwareVersion,omitempty"`
+	DatePublished  string        `json:"datePublished,omitempty"`
+	DateModified   string        `json:"dateModified,omitempty"`
+	Keywords       []string      `json:"keywords,omitempty"`
+	License        []string      `json:"license,omitempty"`
+	Publisher      *organization `json:"publisher,omitempty"`
+}
+
+type organization struct {
+	Type       string `json:"@type"`
+	Name       string `json:"name"`
+	Identifier string `json:"identifier,omitempty"`
+}
+
+func newOrganization(agent *Agent) *organization {
+	if agent == nil {
+		return nil
+	}
+
+	// Administrations are identified by their iPA code.
+	t := "Organization"
+	if agent.Identifier != "" {
+		t = "GovernmentOrganization"
+	}
+
+	return &organization{Type: t, Name: agent.Name, Identifier: agent.Identifier}
+}
+
+// WriteJSONLD writes the catalog as a schema.org DataFeed of SoftwareSourceCode in JSON-LD format.
+func WriteJSONLD(w io.Writer, cat *Catalog) error {
+	feed := dataFeed{
+		Context:      "https://schema.org",
+		Type:         "DataFeed",
+		ID:           cat.URI,
+		Name:         cat.Title,
+		Description:  cat.Description,
+		URL:          cat.Homepage,
+		DateModified: cat.Modified,
+		Publisher:    newOrganization(cat.Publisher),
+		Elements:     []softwareSourceCode{},
+	}
+
+	for _, d := range cat.Datasets {
+		sw := softwareSourceCode{
+			Type:           "SoftwareSourceCode",
+			ID:             d.URI,
+			Identifier:     d.ID,
+			Name:           d.Title,
+			Description:    d.Description,
+			InLanguage:     d.Lang,
+			URL:            d.LandingPage,
+			CodeRepository: d.Repository,
+			Version:        d.Version,
+			DatePublished:  d.Issued,
+			DateModified:   d.Modified,
+			Keywords:       d.Keywords,
+			Publisher:      newOrganization(d.Publisher),
+		}
+		for _, l := range d.Licenses {
+			sw.License = append(sw.License, l.URI)
+		}
+
+		feed.Elements = append(feed.Elements, sw)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(feed)
+}
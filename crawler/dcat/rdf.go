@@ -0,0 +1,225 @@
+package dcat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+const (
+	dcatapitNS = "http://dati.gov.it/onto/dcatapit#"
+
+	// Every software is in the "Science and technology" data theme.
+	themeURI     = "http://publications.europa.eu/resource/authority/data-theme/TECH"
+	frequencyURI = "http://publications.europa.eu/resource/authority/frequency/IRREG"
+	languageURI  = "http://publications.europa.eu/resource/authority/language/ITA"
+)
+
+var funcs = template.FuncMap{
+	"xml": func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s)) // nolint: errcheck
+		return b.String()
+	},
+	"literal": turtleLiteral,
+	"iri":     turtleIRI,
+	"const": func(name string) string {
+		return map[string]string{
+			"theme":     themeURI,
+			"frequency": frequencyURI,
+			"language":  languageURI,
+		}[name]
+	},
+}
+
+var rdfTemplate = template.Must(template.New("rdf").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF
+  xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+  xmlns:dcat="http://www.w3.org/ns/dcat#"
+  xmlns:dcatapit="http://dati.gov.it/onto/dcatapit#"
+  xmlns:dct="http://purl.org/dc/terms/"
+  xmlns:foaf="http://xmlns.com/foaf/0.1/"
+  xmlns:owl="http://www.w3.org/2002/07/owl#">
+{{- define "agent"}}
+      <dcatapit:Agent>
+        <rdf:type rdf:resource="http://xmlns.com/foaf/0.1/Agent"/>
+        <foaf:name>{{xml .Name}}</foaf:name>
+        {{- if .Identifier}}
+        <dct:identifier>{{xml .Identifier}}</dct:identifier>
+        {{- end}}
+      </dcatapit:Agent>
+{{- end}}
+  <dcatapit:Catalog rdf:about="{{xml .URI}}">
+    <rdf:type rdf:resource="http://www.w3.org/ns/dcat#Catalog"/>
+    <dct:title xml:lang="it">{{xml .Title}}</dct:title>
+    <dct:description xml:lang="it">{{xml .Description}}</dct:description>
+    <foaf:homepage rdf:resource="{{xml .Homepage}}"/>
+    <dct:language rdf:resource="{{const "language"}}"/>
+    {{- if .Modified}}
+    <dct:modified rdf:datatype="http://www.w3.org/2001/XMLSchema#date">{{.Modified}}</dct:modified>
+    {{- end}}
+    {{- with .Publisher}}
+    <dct:publisher>{{template "agent" .}}
+    </dct:publisher>
+    {{- end}}
+    {{- range .Datasets}}
+    <dcat:dataset rdf:resource="{{xml .URI}}"/>
+    {{- end}}
+  </dcatapit:Catalog>
+{{- range .Datasets}}
+  <dcatapit:Dataset rdf:about="{{xml .URI}}">
+    <rdf:type rdf:resource="http://www.w3.org/ns/dcat#Dataset"/>
+    <dct:identifier>{{xml .ID}}</dct:identifier>
+    <dct:title xml:lang="{{xml .Lang}}">{{xml .Title}}</dct:title>
+    <dct:description xml:lang="{{xml .Lang}}">{{xml .Description}}</dct:description>
+    <dcat:theme rdf:resource="{{const "theme"}}"/>
+    <dct:accrualPeriodicity rdf:resource="{{const "frequency"}}"/>
+    {{- if .LandingPage}}
+    <dcat:landingPage rdf:resource="{{xml .LandingPage}}"/>
+    {{- end}}
+    {{- if .Modified}}
+    <dct:modified rdf:datatype="http://www.w3.org/2001/XMLSchema#date">{{.Modified}}</dct:modified>
+    {{- end}}
+    {{- if .Issued}}
+    <dct:issued rdf:datatype="http://www.w3.org/2001/XMLSchema#date">{{.Issued}}</dct:issued>
+    {{- end}}
+    {{- if .Version}}
+    <owl:versionInfo>{{xml .Version}}</owl:versionInfo>
+    {{- end}}
+    {{- range .Keywords}}
+    <dcat:keyword>{{xml .}}</dcat:keyword>
+    {{- end}}
+    {{- with .Publisher}}
+    <dct:publisher>{{template "agent" .}}
+    </dct:publisher>
+    <dct:rightsHolder>{{template "agent" .}}
+    </dct:rightsHolder>
+    {{- end}}
+    <dcat:distribution>
+      <dcatapit:Distribution rdf:about="{{xml .URI}}#repository">
+        <rdf:type rdf:resource="http://www.w3.org/ns/dcat#Distribution"/>
+        <dct:title xml:lang="it">Repository del codice sorgente</dct:title>
+        <dcat:accessURL rdf:resource="{{xml .Repository}}"/>
+        {{- range .Licenses}}
+        <dct:license>
+          <dcatapit:LicenseDocument rdf:about="{{xml .URI}}">
+            <rdf:type rdf:resource="http://purl.org/dc/terms/LicenseDocument"/>
+            <foaf:name>{{xml .ID}}</foaf:name>
+          </dcatapit:LicenseDocument>
+        </dct:license>
+        {{- end}}
+      </dcatapit:Distribution>
+    </dcat:distribution>
+  </dcatapit:Dataset>
+{{- end}}
+</rdf:RDF>
+`))
+
+var turtleTemplate = template.Must(template.New("turtle").Funcs(funcs).Parse(`@prefix dcat: <http://www.w3.org/ns/dcat#> .
+@prefix dcatapit: <http://dati.gov.it/onto/dcatapit#> .
+@prefix dct: <http://purl.org/dc/terms/> .
+@prefix foaf: <http://xmlns.com/foaf/0.1/> .
+@prefix owl: <http://www.w3.org/2002/07/owl#> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+{{- define "agent"}} [
+    a dcatapit:Agent, foaf:Agent ;
+    foaf:name {{literal .Name}}
+    {{- if .Identifier}} ;
+    dct:identifier {{literal .Identifier}}
+    {{- end}}
+  ]
+{{- end}}
+
+{{iri .URI}}
+  a dcatapit:Catalog, dcat:Catalog ;
+  dct:title {{literal .Title}}@it ;
+  dct:description {{literal .Description}}@it ;
+  foaf:homepage {{iri .Homepage}} ;
+  dct:language {{iri (const "language")}}
+  {{- if .Modified}} ;
+  dct:modified "{{.Modified}}"^^xsd:date
+  {{- end}}
+  {{- with .Publisher}} ;
+  dct:publisher{{template "agent" .}}
+  {{- end}}
+  {{- range .Datasets}} ;
+  dcat:dataset {{iri .URI}}
+  {{- end}} .
+{{range .Datasets}}
+{{iri .URI}}
+  a dcatapit:Dataset, dcat:Dataset ;
+  dct:identifier {{literal .ID}} ;
+  dct:title {{literal .Title}}@{{.Lang}} ;
+  dct:description {{literal .Description}}@{{.Lang}} ;
+  dcat:theme {{iri (const "theme")}} ;
+  dct:accrualPeriodicity {{iri (const "frequency")}}
+  {{- if .LandingPage}} ;
+  dcat:landingPage {{iri .LandingPage}}
+  {{- end}}
+  {{- if .Modified}} ;
+  dct:modified "{{.Modified}}"^^xsd:date
+  {{- end}}
+  {{- if .Issued}} ;
+  dct:issued "{{.Issued}}"^^xsd:date
+  {{- end}}
+  {{- if .Version}} ;
+  owl:versionInfo {{literal .Version}}
+  {{- end}}
+  {{- range .Keywords}} ;
+  dcat:keyword {{literal .}}
+  {{- end}}
+  {{- with .Publisher}} ;
+  dct:publisher{{template "agent" .}} ;
+  dct:rightsHolder{{template "agent" .}}
+  {{- end}} ;
+  dcat:distribution {{iri (printf "%s#repository" .URI)}} .
+
+{{iri (printf "%s#repository" .URI)}}
+  a dcatapit:Distribution, dcat:Distribution ;
+  dct:title "Repository del codice sorgente"@it ;
+  dcat:accessURL {{iri .Repository}}
+  {{- range .Licenses}} ;
+  dct:license {{iri .URI}}
+  {{- end}} .
+{{range .Licenses}}
+{{iri .URI}}
+  a dcatapit:LicenseDocument, dct:LicenseDocument ;
+  foaf:name {{literal .ID}} .
+{{end}}
+{{- end}}`))
+
+// WriteRDF writes the catalog in DCAT-AP_IT RDF/XML format.
+func WriteRDF(w io.Writer, cat *Catalog) error {
+	return rdfTemplate.Execute(w, cat)
+}
+
+// WriteTurtle writes the catalog in DCAT-AP_IT Turtle format.
+func WriteTurtle(w io.Writer, cat *Catalog) error {
+	return turtleTemplate.Execute(w, cat)
+}
+
+// turtleLiteral returns s as a quoted Turtle string.
+func turtleLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// turtleIRI returns s as a Turtle IRI, escaping the characters that are not
+// allowed in IRIs.
+func turtleIRI(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		if r <= 0x20 || strings.ContainsRune("<>\"{}|^`\\", r) {
+			fmt.Fprintf(&b, "%%%02X", r)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('>')
+
+	return b.String()
+}
@@ -3,6 +3,7 @@ package elastic
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"time"
 
@@ -33,6 +34,7 @@ func ClientFactory(URL, user, password string) (*elastic.Client, error) {
 
 // PubliccodeMapping is the Elasticsearch mapping for the publiccode index.
 // AdministrationsMapping is the Elasticsearch mapping for the administrations index.
+// HistoryMapping is the Elasticsearch mapping for the index of the publiccode.yml versions.
 const (
 	PubliccodeMapping = `{
 "settings": {
@@ -142,6 +144,96 @@ const (
         "type": "date",
         "index": false
       },
+      "firstSeen": {
+        "type": "date"
+      },
+      "lastChanged": {
+        "type": "date"
+      },
+      "contentHash": {
+        "type": "keyword",
+        "index": false
+      },
+      "upstream": {
+        "type": "keyword",
+        "index": true
+      },
+      "repository": {
+        "properties": {
+          "stars": { "type": "integer" },
+          "forks": { "type": "integer" },
+          "openIssues": { "type": "integer" },
+          "language": { "type": "keyword" },
+          "topics": { "type": "keyword" },
+          "createdAt": { "type": "date" },
+          "pushedAt": { "type": "date" },
+          "defaultBranch": { "type": "keyword", "index": false },
+          "size": { "type": "integer" },
+          "archived": { "type": "boolean" },
+          "fork": { "type": "boolean" }
+        }
+      },
+      "detectedLicenses": {
+        "type": "keyword",
+        "index": true
+      },
+      "licenseMismatch": {
+        "type": "boolean"
+      },
+      "dependencies": {
+        "properties": {
+          "name": { "type": "keyword" },
+          "version": { "type": "keyword", "index": false },
+          "ecosystem": { "type": "keyword" }
+        }
+      },
+      "vulnerabilities": {
+        "properties": {
+          "critical": { "type": "integer" },
+          "high": { "type": "integer" },
+          "medium": { "type": "integer" },
+          "low": { "type": "integer" },
+          "unknown": { "type": "integer" },
+          "total": { "type": "integer" }
+        }
+      },
+      "maturity": {
+        "properties": {
+          "readmeIT": { "type": "boolean" },
+          "readmeEN": { "type": "boolean" },
+          "contributing": { "type": "boolean" },
+          "codeOfConduct": { "type": "boolean" },
+          "securityPolicy": { "type": "boolean" },
+          "ci": { "type": "boolean" },
+          "ciProviders": { "type": "keyword" },
+          "tests": { "type": "boolean" },
+          "changelog": { "type": "boolean" },
+          "semverTags": { "type": "boolean" },
+          "score": { "type": "integer" }
+        }
+      },
+      "maintenanceStatus": {
+        "properties": {
+          "status": { "type": "keyword" },
+          "until": { "type": "date", "format": "strict_date" }
+        }
+      },
+      "brokenLinks": {
+        "properties": {
+          "field": { "type": "keyword" },
+          "url": { "type": "keyword" },
+          "status": { "type": "integer" },
+          "error": { "type": "text" }
+        }
+      },
+      "codiceIPASuggestions": {
+        "properties": {
+          "codiceIPA": { "type": "keyword" },
+          "name": { "type": "text" },
+          "score": { "type": "float" },
+          "reasons": { "type": "keyword" }
+        }
+      },
       "publiccodeYmlVersion": {
         "type": "keyword",
         "index": false
@@ -243,7 +335,7 @@ const (
               }
             }
           },
-          "maintainance": {
+          "maintenance": {
             "properties": {
               "type": {
                 "type": "keyword"
@@ -551,6 +643,33 @@ const (
       }
     }
   }`
+	HistoryMapping = `{
+  "mappings": {
+    "version": {
+      "properties": {
+        "softwareId": {
+          "type": "keyword"
+        },
+        "url": {
+          "type": "keyword"
+        },
+        "commit": {
+          "type": "keyword"
+        },
+        "crawltime": {
+          "type": "date"
+        },
+        "contentHash": {
+          "type": "keyword"
+        },
+        "publiccodeYml": {
+          "type": "text",
+          "index": false
+        }
+      }
+    }
+  }
+}`
 )
 
 // CreateIndexMapping adds (if not exists) the mapping for the crawler data in ES.
@@ -631,3 +750,50 @@ func NewBoolQuery(queryType string) *elastic.BoolQuery {
 
 	return query
 }
+
+// scrollSize is the number of documents fetched by Scroll in each request.
+const scrollSize = 1000
+
+// Scroll calls fn for every document in index matching query. Documents are
+// fetched in batches with the scroll API, so there is no limit on the number
+// of documents (as opposed to the 10k results of a search).
+// Scrolling stops at the first error returned by fn.
+func Scroll(index string, query elastic.Query, fetchSource *elastic.FetchSourceContext, elasticClient *elastic.Client, fn func(hit *elastic.SearchHit) error) error {
+	_, err := ScrollTotal(index, query, fetchSource, elasticClient, fn)
+	return err
+}
+
+// ScrollTotal is like Scroll, and also returns the number of documents
+// matching query. The scroll is a snapshot of the index taken when it starts,
+// so it's the number of documents fn is called for even if the index is
+// being written to, unlike a separate count.
+func ScrollTotal(index string, query elastic.Query, fetchSource *elastic.FetchSourceContext, elasticClient *elastic.Client, fn func(hit *elastic.SearchHit) error) (int64, error) {
+	ctx := context.Background()
+
+	scroll := elasticClient.Scroll(index).
+		Query(query).
+		Size(scrollSize).
+		Sort("_doc", true) // _doc is the most efficient sort order for scrolling.
+	if fetchSource != nil {
+		scroll = scroll.FetchSourceContext(fetchSource)
+	}
+	defer scroll.Clear(ctx) // nolint: errcheck
+
+	var total int64
+	for {
+		searchResult, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+		total = searchResult.Hits.TotalHits
+
+		for _, hit := range searchResult.Hits.Hits {
+			if err := fn(hit); err != nil {
+				return total, err
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package elastictest provides a fake Elasticsearch server for testing the
+// code that reads from the indexes.
+package elastictest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/olivere/elastic"
+)
+
+// scrollSize is the number of documents returned by every scroll page after the first.
+const scrollSize = 1000
+
+// handler is a minimal stand-in for the Elasticsearch search, scroll and
+// count APIs serving a fixed list of documents.
+type handler struct {
+	docs []interface{}
+}
+
+type hit struct {
+	Index  string      `json:"_index"`
+	Type   string      `json:"_type"`
+	ID     string      `json:"_id"`
+	Source interface{} `json:"_source"`
+}
+
+// NewClient starts a fake Elasticsearch serving docs and returns a client
+// connected to it. The server must be closed by the caller.
+func NewClient(t testing.TB, docs []interface{}) (*httptest.Server, *elastic.Client) {
+	server := httptest.NewServer(&handler{docs: docs})
+
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return server, client
+}
+
+func (h *handler) page(w http.ResponseWriter, scroll bool, from, size int) {
+	if from > len(h.docs) {
+		from = len(h.docs)
+	}
+	to := from + size
+	if to > len(h.docs) {
+		to = len(h.docs)
+	}
+
+	hits := []hit{}
+	for i := from; i < to; i++ {
+		hits = append(hits, hit{"index", "software", strconv.Itoa(i), h.docs[i]})
+	}
+
+	resp := map[string]interface{}{
+		"hits": map[string]interface{}{"total": len(h.docs), "hits": hits},
+	}
+	if scroll {
+		resp["_scroll_id"] = strconv.Itoa(to)
+	}
+	json.NewEncoder(w).Encode(resp) // nolint: errcheck
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body struct {
+		Size     *int   `json:"size"`
+		ScrollID string `json:"scroll_id"`
+	}
+	if r.Method != http.MethodDelete {
+		json.NewDecoder(r.Body).Decode(&body) // nolint: errcheck
+	}
+
+	switch {
+	case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+		w.Write([]byte(`{"succeeded": true}`)) // nolint: errcheck
+
+	case r.URL.Path == "/_search/scroll":
+		from, _ := strconv.Atoi(body.ScrollID)
+		h.page(w, true, from, scrollSize)
+
+	case strings.HasSuffix(r.URL.Path, "/_count"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": len(h.docs)}) // nolint: errcheck
+
+	case r.URL.Query().Get("scroll") != "":
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		h.page(w, true, 0, size)
+
+	default:
+		size := 10
+		if body.Size != nil {
+			size = *body.Size
+		}
+		h.page(w, false, 0, size)
+	}
+}
@@ -0,0 +1,341 @@
+// Package feeds generates the Atom feeds of the changes in the catalog.
+//
+// The feeds are written to a directory at export time:
+//
+//	new.atom                    software added to the catalog
+//	updated.atom                software whose publiccode.yml changed
+//	releases.atom               new releases
+//	publishers/<iPA code>.atom  software of a publisher, by last change
+//	categories/<category>.atom  software in a category, by last change
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// MaxEntries is the maximum number of entries in a feed.
+const MaxEntries = 50
+
+const author = "Developers Italia"
+
+// software contains the fields of the software objects stored in
+// Elasticsearch that are used in feeds.
+type software struct {
+	ID          string `json:"id"`
+	Slug        string `json:"slug"`
+	CrawlTime   string `json:"crawltime"`
+	FirstSeen   string `json:"firstSeen"`
+	LastChanged string `json:"lastChanged"`
+	IPALabel    string `json:"it-riuso-codiceIPA-label"`
+	PublicCode  struct {
+		Name            string   `json:"name"`
+		SoftwareVersion string   `json:"softwareVersion"`
+		ReleaseDate     string   `json:"releaseDate"`
+		Categories      []string `json:"categories"`
+		Description     map[string]struct {
+			LocalisedName    string `json:"localisedName"`
+			ShortDescription string `json:"shortDescription"`
+		} `json:"description"`
+		Legal struct {
+			MainCopyrightOwner string `json:"mainCopyrightOwner"`
+		} `json:"legal"`
+		It struct {
+			Riuso struct {
+				CodiceIPA string `json:"codiceIPA"`
+			} `json:"riuso"`
+		} `json:"it"`
+	} `json:"publiccode"`
+}
+
+// Atom 1.0 (RFC 4287) elements.
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Author  atomPerson   `xml:"author"`
+	Links   []atomLink   `xml:"link"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// entry is a software with the times used to sort the feeds.
+type entry struct {
+	atomEntry
+	firstSeen   time.Time
+	lastChanged time.Time
+	released    time.Time
+	version     string
+	codiceIPA   string
+}
+
+// Generate writes all the feeds to dir.
+func Generate(dir string, elasticClient *es.Client) error {
+	siteURL := strings.TrimRight(viper.GetString("SITE_URL"), "/")
+	if siteURL == "" {
+		return errors.New("SITE_URL is not set")
+	}
+
+	var entries []*entry
+	query := elastic.NewBoolQuery("software")
+	fields := es.NewFetchSourceContext(true).Include(
+		"id", "slug", "crawltime", "firstSeen", "lastChanged", "it-riuso-codiceIPA-label",
+		"publiccode.name", "publiccode.softwareVersion", "publiccode.releaseDate", "publiccode.categories",
+		"publiccode.description.*", "publiccode.legal.mainCopyrightOwner", "publiccode.it.riuso.codiceIPA",
+	)
+	err := elastic.Scroll(viper.GetString("ELASTIC_PUBLICCODE_INDEX"), query, fields, elasticClient, func(hit *es.SearchHit) error {
+		var sw software
+		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
+			log.Error(err)
+			return nil
+		}
+		entries = append(entries, newEntry(&sw, siteURL))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return write(dir, siteURL, entries)
+}
+
+// write writes the feeds of entries to dir.
+func write(dir, siteURL string, entries []*entry) error {
+	for _, d := range []string{dir, path.Join(dir, "publishers"), path.Join(dir, "categories")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return err
+		}
+	}
+
+	g := generator{dir: dir, siteURL: siteURL}
+
+	byFirstSeen := sorted(entries, func(e *entry) time.Time { return e.firstSeen })
+	g.write("new.atom", "Nuovo software nel catalogo", byFirstSeen, func(e *entry) atomEntry {
+		a := e.atomEntry
+		a.Updated = formatTime(e.firstSeen)
+		return a
+	})
+
+	byLastChanged := sorted(entries, func(e *entry) time.Time { return e.lastChanged })
+	g.write("updated.atom", "Software aggiornato nel catalogo", byLastChanged, nil)
+
+	var released []*entry
+	for _, e := range entries {
+		if !e.released.IsZero() {
+			released = append(released, e)
+		}
+	}
+	g.write("releases.atom", "Nuovi rilasci del software nel catalogo", sorted(released, func(e *entry) time.Time { return e.released }), func(e *entry) atomEntry {
+		a := e.atomEntry
+		// Every release is a different entry.
+		a.ID += "#release-" + formatTime(e.released)
+		a.Updated = formatTime(e.released)
+		if e.version != "" {
+			a.Title += " " + e.version
+		}
+		return a
+	})
+
+	publishers := map[string][]*entry{}
+	categories := map[string][]*entry{}
+	for _, e := range byLastChanged {
+		if e.codiceIPA != "" {
+			publishers[e.codiceIPA] = append(publishers[e.codiceIPA], e)
+		}
+		for _, c := range e.Categories {
+			categories[c.Term] = append(categories[c.Term], e)
+		}
+	}
+	for codiceIPA, list := range publishers {
+		if !validName(codiceIPA) {
+			log.Errorf("Skipping feed of publisher with invalid iPA code %q", codiceIPA)
+			continue
+		}
+		name := codiceIPA
+		if list[0].Author != nil {
+			name = list[0].Author.Name
+		}
+		g.write(path.Join("publishers", codiceIPA+".atom"), "Software di "+name, list, nil)
+	}
+	for category, list := range categories {
+		if !validName(category) {
+			log.Errorf("Skipping feed of invalid category %q", category)
+			continue
+		}
+		g.write(path.Join("categories", category+".atom"), "Software nella categoria "+category, list, nil)
+	}
+
+	return g.err
+}
+
+// generator writes feeds, stopping at the first error.
+type generator struct {
+	dir     string
+	siteURL string
+	err     error
+}
+
+// write writes a feed with the first MaxEntries entries, converting them with
+// convert (or using them as they are, if convert is nil).
+func (g *generator) write(name, title string, entries []*entry, convert func(*entry) atomEntry) {
+	if g.err != nil {
+		return
+	}
+
+	feedURL := g.siteURL + "/feeds/" + name
+	feed := atomFeed{
+		ID:     feedURL,
+		Title:  title,
+		Author: atomPerson{author},
+		Links: []atomLink{
+			{Href: feedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: g.siteURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	if len(entries) > MaxEntries {
+		entries = entries[:MaxEntries]
+	}
+	for _, e := range entries {
+		a := e.atomEntry
+		if convert != nil {
+			a = convert(e)
+		}
+		if a.Updated > feed.Updated {
+			feed.Updated = a.Updated
+		}
+		feed.Entries = append(feed.Entries, &a)
+	}
+	// updated is mandatory, even in empty feeds.
+	if feed.Updated == "" {
+		feed.Updated = formatTime(time.Unix(0, 0))
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		g.err = err
+		return
+	}
+
+	g.err = ioutil.WriteFile(path.Join(g.dir, name), append([]byte(xml.Header), data...), 0644)
+}
+
+// validName returns true if s can be used as a file name.
+func validName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "/\\") && !strings.HasPrefix(s, ".")
+}
+
+func newEntry(sw *software, siteURL string) *entry {
+	pc := &sw.PublicCode
+	pageURL := siteURL + "/it/software/" + sw.Slug
+
+	e := entry{
+		atomEntry: atomEntry{
+			ID:    pageURL,
+			Title: pc.Name,
+			Links: []atomLink{{Href: pageURL, Rel: "alternate", Type: "text/html"}},
+		},
+		version:   pc.SoftwareVersion,
+		codiceIPA: strings.ToLower(pc.It.Riuso.CodiceIPA),
+	}
+
+	e.lastChanged = parseTime(sw.LastChanged, sw.CrawlTime)
+	e.firstSeen = parseTime(sw.FirstSeen, sw.LastChanged, sw.CrawlTime)
+	e.released = parseTime(pc.ReleaseDate)
+	e.Updated = formatTime(e.lastChanged)
+	e.Published = formatTime(e.firstSeen)
+
+	for _, lang := range []string{"it", "en"} {
+		if desc, ok := pc.Description[lang]; ok {
+			if desc.LocalisedName != "" {
+				e.Title = desc.LocalisedName
+			}
+			e.Summary = strings.TrimSpace(desc.ShortDescription)
+			break
+		}
+	}
+
+	switch {
+	case sw.IPALabel != "":
+		e.Author = &atomPerson{sw.IPALabel}
+	case e.codiceIPA != "":
+		e.Author = &atomPerson{e.codiceIPA}
+	case pc.Legal.MainCopyrightOwner != "":
+		e.Author = &atomPerson{pc.Legal.MainCopyrightOwner}
+	}
+
+	for _, c := range pc.Categories {
+		e.Categories = append(e.Categories, atomCategory{c})
+	}
+
+	return &e
+}
+
+// sorted returns a copy of entries sorted by key, most recent first.
+func sorted(entries []*entry, key func(*entry) time.Time) []*entry {
+	list := append([]*entry(nil), entries...)
+	sort.SliceStable(list, func(i, j int) bool {
+		ki, kj := key(list[i]), key(list[j])
+		if !ki.Equal(kj) {
+			return ki.After(kj)
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	return list
+}
+
+// parseTime returns the first valid RFC3339 timestamp or date in values.
+func parseTime(values ...string) time.Time {
+	for _, v := range values {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
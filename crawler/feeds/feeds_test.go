@@ -0,0 +1,103 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic/elastictest"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeSoftware(i int, codiceIPA string) interface{} {
+	return map[string]interface{}{
+		"id":          fmt.Sprintf("id-%d", i),
+		"slug":        fmt.Sprintf("slug-%d", i),
+		"crawltime":   "2020-10-20T10:00:00Z",
+		"firstSeen":   fmt.Sprintf("2020-01-%02dT10:00:00Z", i+1),
+		"lastChanged": fmt.Sprintf("2020-10-%02dT10:00:00Z", 20-i),
+		"publiccode": map[string]interface{}{
+			"name":            fmt.Sprintf("Software %d", i),
+			"softwareVersion": fmt.Sprintf("1.%d", i),
+			"releaseDate":     fmt.Sprintf("2020-%02d-01", i+1),
+			"categories":      []string{"it-development"},
+			"description": map[string]interface{}{
+				"it": map[string]interface{}{"shortDescription": fmt.Sprintf("Descrizione %d", i)},
+			},
+			"it": map[string]interface{}{"riuso": map[string]interface{}{"codiceIPA": codiceIPA}},
+		},
+	}
+}
+
+func readFeed(t *testing.T, filename string) atomFeed {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed atomFeed
+	assert.Nil(t, xml.Unmarshal(data, &feed))
+	return feed
+}
+
+func ids(feed atomFeed) []string {
+	var list []string
+	for _, e := range feed.Entries {
+		list = append(list, e.ID)
+	}
+	return list
+}
+
+func TestGenerate(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+	viper.Set("SITE_URL", "https://example.org")
+
+	dir, err := ioutil.TempDir("", "feeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server, client := elastictest.NewClient(t, []interface{}{
+		fakeSoftware(0, "C_A001"),
+		fakeSoftware(1, ""),
+		fakeSoftware(2, "c_a001"),
+	})
+	defer server.Close()
+
+	assert.Nil(t, Generate(dir, client))
+
+	// Newest first.
+	feed := readFeed(t, path.Join(dir, "new.atom"))
+	assert.Equal(t, "https://example.org/feeds/new.atom", feed.ID)
+	assert.Equal(t, "2020-01-03T10:00:00Z", feed.Updated)
+	assert.Equal(t, []string{
+		"https://example.org/it/software/slug-2",
+		"https://example.org/it/software/slug-1",
+		"https://example.org/it/software/slug-0",
+	}, ids(feed))
+	assert.Equal(t, "Descrizione 2", feed.Entries[0].Summary)
+
+	feed = readFeed(t, path.Join(dir, "updated.atom"))
+	assert.Equal(t, "2020-10-20T10:00:00Z", feed.Updated)
+	assert.Equal(t, "https://example.org/it/software/slug-0", feed.Entries[0].ID)
+
+	feed = readFeed(t, path.Join(dir, "releases.atom"))
+	assert.Equal(t, "https://example.org/it/software/slug-2#release-2020-03-01T00:00:00Z", feed.Entries[0].ID)
+	assert.Equal(t, "Software 2 1.2", feed.Entries[0].Title)
+
+	// iPA codes are case insensitive.
+	feed = readFeed(t, path.Join(dir, "publishers", "c_a001.atom"))
+	assert.Equal(t, []string{
+		"https://example.org/it/software/slug-0",
+		"https://example.org/it/software/slug-2",
+	}, ids(feed))
+
+	feed = readFeed(t, path.Join(dir, "categories", "it-development.atom"))
+	assert.Len(t, feed.Entries, 3)
+}
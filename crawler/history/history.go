@@ -0,0 +1,249 @@
+// Package history keeps track of the versions of the publiccode.yml files.
+//
+// Every distinct version of a publiccode.yml is stored in Elasticsearch
+// (ELASTIC_HISTORY_INDEX) with the commit it was found at, so that the
+// changes made by the publishers can be listed and compared field by field.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+)
+
+// Version is a version of a publiccode.yml.
+type Version struct {
+	SoftwareID  string `json:"softwareId"`
+	URL         string `json:"url"`
+	Commit      string `json:"commit"`
+	CrawlTime   string `json:"crawltime"`
+	ContentHash string `json:"contentHash"`
+	PublicCode  string `json:"publiccodeYml"`
+}
+
+// Change is a field that changed between two versions.
+// Old is nil if the field was added, New is nil if it was removed.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// Entry is a version with its changes from the previous one.
+type Entry struct {
+	Commit      string   `json:"commit"`
+	CrawlTime   string   `json:"crawltime"`
+	ContentHash string   `json:"contentHash"`
+	Changes     []Change `json:"changes"`
+	Notes       []string `json:"notes,omitempty"`
+}
+
+// Save stores a new version in index.
+func Save(v Version, index string, elasticClient *es.Client) error {
+	_, err := elasticClient.Index().
+		Index(index).
+		Type("version").
+		BodyJson(v).
+		// The history is written to OUTPUT_DIR right after, so the new
+		// version must be searchable.
+		Refresh("true").
+		Do(context.Background())
+
+	return err
+}
+
+// List returns the versions of the software with the given repository URL
+// stored in index, oldest first.
+func List(url string, index string, elasticClient *es.Client) ([]Version, error) {
+	// Match both https://example.org/repo and https://example.org/repo.git
+	url = strings.TrimSuffix(strings.TrimRight(url, "/"), ".git")
+	return list(es.NewTermsQuery("url", url, url+".git", url+"/"), index, elasticClient)
+}
+
+// ListSoftware returns the versions of the software with the given id
+// stored in index, oldest first.
+func ListSoftware(id string, index string, elasticClient *es.Client) ([]Version, error) {
+	return list(es.NewTermQuery("softwareId", id), index, elasticClient)
+}
+
+func list(query es.Query, index string, elasticClient *es.Client) ([]Version, error) {
+	var versions []Version
+	err := elastic.Scroll(index, query, es.NewFetchSourceContext(true), elasticClient, func(hit *es.SearchHit) error {
+		var v Version
+		if err := json.Unmarshal(*hit.Source, &v); err != nil {
+			log.Error(err)
+			return nil
+		}
+		versions = append(versions, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].CrawlTime < versions[j].CrawlTime
+	})
+
+	return versions, nil
+}
+
+// Entries returns the entries of versions (sorted oldest first), newest first.
+// The notes of every version report the maintenance contracts expired before
+// the following version, or before now for the latest one.
+func Entries(versions []Version, now time.Time) []Entry {
+	entries := make([]Entry, len(versions))
+
+	var previous []byte
+	for i, v := range versions {
+		current := []byte(v.PublicCode)
+
+		// The first version has no changes, rather than all its fields added.
+		changes := []Change{}
+		if i > 0 {
+			var err error
+			changes, err = Diff(previous, current)
+			if err != nil {
+				log.Errorf("Cannot compare version %s of %s: %v", v.ContentHash, v.URL, err)
+			}
+		}
+
+		end := now
+		if i+1 < len(versions) {
+			if t, err := time.Parse(time.RFC3339, versions[i+1].CrawlTime); err == nil {
+				end = t
+			}
+		}
+
+		entries[len(versions)-1-i] = Entry{
+			Commit:      v.Commit,
+			CrawlTime:   v.CrawlTime,
+			ContentHash: v.ContentHash,
+			Changes:     changes,
+			Notes:       Notes(current, end),
+		}
+		previous = current
+	}
+
+	return entries
+}
+
+// Diff returns the fields that changed from the publiccode.yml old to new,
+// sorted by field. Fields are dotted paths (eg. "legal.license"), lists of
+// objects are indexed (eg. "maintenance.contractors[0].until").
+func Diff(old, new []byte) ([]Change, error) {
+	oldFields, err := fields(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := fields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	for field, o := range oldFields {
+		n, ok := newFields[field]
+		if !ok {
+			changes = append(changes, Change{Field: field, Old: o})
+		} else if !reflect.DeepEqual(o, n) {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+	}
+	for field, n := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			changes = append(changes, Change{Field: field, New: n})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
+
+// Notes returns the notes about the publiccode.yml at the time t, which
+// can't be seen in the diffs since the file didn't change.
+func Notes(yml []byte, t time.Time) []string {
+	var pc struct {
+		Maintenance struct {
+			Contractors []struct {
+				Name  string `json:"name"`
+				Until string `json:"until"`
+			} `json:"contractors"`
+		} `json:"maintenance"`
+	}
+	if err := yaml.Unmarshal(yml, &pc); err != nil {
+		return nil
+	}
+
+	var notes []string
+	for _, c := range pc.Maintenance.Contractors {
+		until, err := time.Parse("2006-01-02", c.Until)
+		if err != nil {
+			continue
+		}
+		if until.Before(t) {
+			notes = append(notes, fmt.Sprintf("maintenance contractor %s expired on %s", c.Name, c.Until))
+		}
+	}
+
+	return notes
+}
+
+// fields returns the flattened fields of the publiccode.yml yml.
+func fields(yml []byte) (map[string]interface{}, error) {
+	flat := map[string]interface{}{}
+	if len(yml) == 0 {
+		return flat, nil
+	}
+
+	var data interface{}
+	if err := yaml.Unmarshal(yml, &data); err != nil {
+		return nil, err
+	}
+	flatten("", data, flat)
+
+	return flat, nil
+}
+
+func flatten(prefix string, value interface{}, flat map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			field := key
+			if prefix != "" {
+				field = prefix + "." + key
+			}
+			flatten(field, item, flat)
+		}
+	case []interface{}:
+		// Lists of values (eg. categories) are compared as a whole,
+		// lists of objects item by item.
+		objects := false
+		for _, item := range v {
+			if _, ok := item.(map[string]interface{}); ok {
+				objects = true
+			}
+		}
+		if !objects {
+			flat[prefix] = v
+			return
+		}
+		for i, item := range v {
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), item, flat)
+		}
+	default:
+		flat[prefix] = v
+	}
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const v1 = `
+name: Software
+categories: [it-development]
+legal:
+  license: MIT
+maintenance:
+  type: contract
+  contractors:
+    - name: Contractor
+      until: "2020-06-01"
+`
+
+const v2 = `
+name: Software
+categories: [it-development, data-collection]
+legal:
+  license: AGPL-3.0-or-later
+maintenance:
+  type: contract
+  contractors:
+    - name: Contractor
+      until: "2020-06-01"
+    - name: New contractor
+      until: "2021-06-01"
+`
+
+func TestDiff(t *testing.T) {
+	changes, err := Diff([]byte(v1), []byte(v2))
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{
+		{Field: "categories", Old: []interface{}{"it-development"}, New: []interface{}{"it-development", "data-collection"}},
+		{Field: "legal.license", Old: "MIT", New: "AGPL-3.0-or-later"},
+		{Field: "maintenance.contractors[1].name", New: "New contractor"},
+		{Field: "maintenance.contractors[1].until", New: "2021-06-01"},
+	}, changes)
+
+	changes, err = Diff([]byte(v2), []byte(v2))
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+
+	_, err = Diff([]byte(v1), []byte("name: [invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestEntries(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	versions := []Version{
+		{Commit: "aaa", CrawlTime: "2020-01-01T10:00:00Z", PublicCode: v1},
+		{Commit: "bbb", CrawlTime: "2020-05-01T10:00:00Z", PublicCode: v2},
+	}
+	entries := Entries(versions, time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	// Newest first.
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "bbb", entries[0].Commit)
+	assert.Len(t, entries[0].Changes, 4)
+	assert.Equal(t, []string{
+		"maintenance contractor Contractor expired on 2020-06-01",
+		"maintenance contractor New contractor expired on 2021-06-01",
+	}, entries[0].Notes)
+
+	// The first version has no changes, and the contract was still valid
+	// when the second version was found.
+	assert.Equal(t, "aaa", entries[1].Commit)
+	assert.Empty(t, entries[1].Changes)
+	assert.Empty(t, entries[1].Notes)
+}
@@ -0,0 +1,283 @@
+package ipa
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Minimum name similarity (Sørensen–Dice coefficient on bigrams) for an
+// administration to be suggested on its name alone.
+const minNameSimilarity = 0.75
+
+// Code hosting domains shared by many administrations: they can't be used to
+// tell who publishes a software.
+var sharedHosts = []string{"github.com", "gitlab.com", "bitbucket.org"}
+
+// MatchHints contains what we know about the publisher of a software.
+type MatchHints struct {
+	// Names are the organization/owner names (eg. the code hosting org,
+	// the whitelist name, legal.repoOwner).
+	Names []string
+	// URLs are the URLs declared in publiccode.yml (eg. url and landingURL).
+	URLs []string
+	// CodiceIPA is the (possibly wrong) codiceIPA declared in publiccode.yml.
+	// It's also compared against fiscal codes, since they are often used by mistake.
+	CodiceIPA string
+}
+
+// Match is an administration from IndicePA suggested for a software.
+type Match struct {
+	CodiceIPA string   `json:"codiceIPA"`
+	Name      string   `json:"name"`
+	Score     float64  `json:"score"`
+	Reasons   []string `json:"reasons"`
+}
+
+// administration is an Amministrazione with precomputed matching data.
+type administration struct {
+	Amministrazione
+	host    string
+	bigrams map[string]int
+}
+
+var (
+	administrations     []administration
+	loadAdministrations sync.Once
+)
+
+// Exists returns whether codiceIPA is an administration in IndicePA.
+func Exists(codiceIPA string) bool {
+	loadAdministrations.Do(func() {
+		administrations = readAdministrations()
+	})
+
+	return exists(administrations, codiceIPA)
+}
+
+func exists(amms []administration, codiceIPA string) bool {
+	codiceIPA = strings.TrimSpace(codiceIPA)
+	if codiceIPA == "" {
+		return false
+	}
+	for _, amm := range amms {
+		if strings.EqualFold(amm.CodAmm, codiceIPA) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SuggestCodiceIPA returns up to max administrations from IndicePA that are
+// likely to publish the software described by hints, best match first.
+func SuggestCodiceIPA(hints MatchHints, max int) []Match {
+	loadAdministrations.Do(func() {
+		administrations = readAdministrations()
+	})
+
+	return matchAdministrations(administrations, hints, max)
+}
+
+// readAdministrations reads the local copy of IndicePA.
+func readAdministrations() []administration {
+	dataFile, err := ioutil.ReadFile(localIPAFile())
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	var amms []administration
+	scanner := bufio.NewScanner(strings.NewReader(string(dataFile)))
+	for scanner.Scan() {
+		amm := parseLine(scanner.Text())
+		// Skip the header.
+		if strings.EqualFold(amm.CodAmm, "cod_amm") {
+			continue
+		}
+		amms = append(amms, newAdministration(amm))
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("error reading IndicePA file: %v", err)
+	}
+
+	return amms
+}
+
+func newAdministration(amm Amministrazione) administration {
+	return administration{
+		Amministrazione: amm,
+		host:            hostname(amm.SitoIstituzionale),
+		bigrams:         bigrams(amm.DesAmm),
+	}
+}
+
+// matchAdministrations scores every administration against hints.
+func matchAdministrations(amms []administration, hints MatchHints, max int) []Match {
+	var hosts []string
+	for _, u := range hints.URLs {
+		if h := hostname(u); h != "" && !isSharedHost(h) {
+			hosts = append(hosts, h)
+		}
+	}
+
+	var names []map[string]int
+	for _, n := range hints.Names {
+		if b := bigrams(n); len(b) > 0 {
+			names = append(names, b)
+		}
+	}
+
+	fiscalCode := strings.TrimSpace(hints.CodiceIPA)
+	if !isFiscalCode(fiscalCode) {
+		fiscalCode = ""
+	}
+
+	var matches []Match
+	for _, amm := range amms {
+		var reasons []string
+		var score float64
+
+		if fiscalCode != "" && fiscalCode == strings.TrimSpace(amm.CF) {
+			reasons = append(reasons, "fiscal code")
+			score = 1
+		}
+
+		if amm.host != "" {
+			for _, h := range hosts {
+				if h == amm.host || strings.HasSuffix(h, "."+amm.host) {
+					reasons = append(reasons, "website domain")
+					score = maxFloat(score, 0.9)
+					break
+				}
+			}
+		}
+
+		var similarity float64
+		for _, n := range names {
+			similarity = maxFloat(similarity, dice(n, amm.bigrams))
+		}
+		if similarity >= minNameSimilarity {
+			reasons = append(reasons, "name similarity")
+			score = maxFloat(score, similarity*0.8)
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		// Every additional piece of evidence makes the match more likely.
+		score += 0.1 * float64(len(reasons)-1)
+		if score > 1 {
+			score = 1
+		}
+
+		matches = append(matches, Match{
+			CodiceIPA: strings.ToLower(amm.CodAmm),
+			Name:      amm.DesAmm,
+			Score:     score,
+			Reasons:   reasons,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > max {
+		matches = matches[:max]
+	}
+
+	return matches
+}
+
+// hostname returns the lowercase host of link without the "www." prefix.
+func hostname(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func isSharedHost(host string) bool {
+	for _, h := range sharedHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFiscalCode returns true if s looks like the fiscal code of an organization (11 digits).
+func isFiscalCode(s string) bool {
+	if len(s) != 11 {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// bigrams returns the character bigrams of s, ignoring case and anything
+// that is not a letter or a digit (so "comune-di-roma" and "Comune di Roma"
+// are the same).
+func bigrams(s string) map[string]int {
+	var normalized []rune
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized = append(normalized, r)
+		}
+	}
+
+	b := make(map[string]int)
+	for i := 0; i < len(normalized)-1; i++ {
+		b[string(normalized[i:i+2])]++
+	}
+	return b
+}
+
+// dice returns the Sørensen–Dice coefficient of two bigram sets.
+func dice(a, b map[string]int) float64 {
+	var total, common int
+	for k, n := range a {
+		total += n
+		if m, ok := b[k]; ok {
+			if m < n {
+				common += m
+			} else {
+				common += n
+			}
+		}
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+package ipa
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchAdministrations(t *testing.T) {
+	amms := []administration{
+		newAdministration(Amministrazione{
+			CodAmm:            "c_a547",
+			DesAmm:            "Comune di Bagnacavallo",
+			SitoIstituzionale: "www.comune.bagnacavallo.ra.it",
+			CF:                "00257850397",
+		}),
+		newAdministration(Amministrazione{
+			CodAmm:            "c_h501",
+			DesAmm:            "Roma Capitale",
+			SitoIstituzionale: "https://www.comune.roma.it",
+			CF:                "02438750586",
+		}),
+	}
+
+	// Fiscal code used as codiceIPA.
+	matches := matchAdministrations(amms, MatchHints{CodiceIPA: "00257850397"}, 3)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "c_a547", matches[0].CodiceIPA)
+	assert.Equal(t, []string{"fiscal code"}, matches[0].Reasons)
+
+	// Landing page on the administration website.
+	matches = matchAdministrations(amms, MatchHints{
+		URLs: []string{"https://github.com/comune-roma/app", "https://servizi.comune.roma.it/app"},
+	}, 3)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "c_h501", matches[0].CodiceIPA)
+
+	// Org name similar to the administration name, with the website as additional evidence.
+	matches = matchAdministrations(amms, MatchHints{
+		Names: []string{"comune-di-bagnacavallo"},
+		URLs:  []string{"https://www.comune.bagnacavallo.ra.it"},
+	}, 3)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "c_a547", matches[0].CodiceIPA)
+	assert.Equal(t, []string{"website domain", "name similarity"}, matches[0].Reasons)
+	assert.Equal(t, 1.0, matches[0].Score)
+
+	// Code hosting domains and unrelated names don't match anything.
+	matches = matchAdministrations(amms, MatchHints{
+		Names: []string{"italia"},
+		URLs:  []string{"https://github.com/italia/foo"},
+	}, 3)
+	assert.Empty(t, matches)
+
+	// Known codes, ignoring case and spaces.
+	assert.True(t, exists(amms, "C_A547 "))
+	assert.False(t, exists(amms, "c_x999"))
+	assert.False(t, exists(amms, ""))
+}
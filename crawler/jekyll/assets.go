@@ -0,0 +1,155 @@
+package jekyll
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/icza/dyno"
+	"github.com/italia/developers-italia-backend/crawler/assets"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// NewAssetStore returns the Store of the logos and the screenshots, cached
+// in CRAWLER_DATADIR/assets, with the limits in ASSETS_MAX_SIZE and
+// ASSETS_THUMBNAIL_WIDTH.
+func NewAssetStore() *assets.Store {
+	maxSize := int64(5 * 1024 * 1024)
+	if viper.IsSet("ASSETS_MAX_SIZE") {
+		maxSize = int64(viper.GetSizeInBytes("ASSETS_MAX_SIZE"))
+	}
+	thumbnailWidth := 400
+	if viper.IsSet("ASSETS_THUMBNAIL_WIDTH") {
+		thumbnailWidth = viper.GetInt("ASSETS_THUMBNAIL_WIDTH")
+	}
+
+	return assets.NewStore(
+		path.Join(viper.GetString("CRAWLER_DATADIR"), "assets"),
+		&http.Client{Timeout: 30 * time.Second},
+		maxSize,
+		thumbnailWidth,
+	)
+}
+
+// unsafeSlug matches the characters of a slug not allowed in a directory name.
+var unsafeSlug = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// assetsDir returns the directory of the assets of the software with slug,
+// relative to the output directory.
+func assetsDir(slug string) string {
+	return path.Join("assets", unsafeSlug.ReplaceAllString(slug, "-"))
+}
+
+// localAsset exports the image at rawURL to outputDir/assets/<slug>/name and
+// returns the reference to use in its place, which is rawURL if it can't be
+// downloaded and empty if it's not valid. asset is nil if not exported.
+func localAsset(store *assets.Store, outputDir, slug, rawURL, name string) (ref string, asset *assets.Asset) {
+	a, err := store.Export(rawURL, outputDir, assetsDir(slug), name)
+	if err != nil {
+		logger := log.WithFields(log.Fields{"slug": slug, "url": rawURL, "error": err})
+		if assets.IsInvalid(err) {
+			logger.Warn("invalid image, not exported")
+			return "", nil
+		}
+		logger.Warn("cannot download the image, linking the original")
+		return rawURL, nil
+	}
+
+	return a.Path, &a
+}
+
+// screenshotName returns the name of the local copy of the screenshot at
+// rawURL, which is the same in all the languages.
+func screenshotName(rawURL string) string {
+	sum := sha1.Sum([]byte(rawURL))
+	return "screenshot-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// localizeAssets exports the logos and the screenshots of the software
+// document doc and makes it reference the local copies. The exported
+// images are listed in its "assets", with their thumbnails.
+func localizeAssets(store *assets.Store, outputDir string, doc interface{}) {
+	slug, _ := dyno.GetString(doc, "slug")
+	if slug == "" {
+		return
+	}
+
+	var exported []assets.Asset
+	for _, field := range []struct{ key, name string }{
+		{"logo", "logo"},
+		{"monochromeLogo", "monochrome-logo"},
+	} {
+		rawURL, err := dyno.GetString(doc, "publiccode", field.key)
+		if err != nil || rawURL == "" {
+			continue
+		}
+		ref, asset := localAsset(store, outputDir, slug, rawURL, field.name)
+		if ref == "" {
+			dyno.Delete(doc, field.key, "publiccode") // nolint: errcheck
+			continue
+		}
+		dyno.Set(doc, ref, "publiccode", field.key) // nolint: errcheck
+		if asset != nil {
+			exported = append(exported, *asset)
+		}
+	}
+
+	descriptions, _ := dyno.GetMapS(doc, "publiccode", "description")
+	var langs []string
+	for lang := range descriptions {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	seen := map[string]bool{}
+	for _, lang := range langs {
+		screenshots, err := dyno.GetSlice(descriptions[lang], "screenshots")
+		if err != nil {
+			continue
+		}
+
+		local := []interface{}{}
+		for _, s := range screenshots {
+			rawURL, _ := s.(string)
+			if rawURL == "" {
+				continue
+			}
+			ref, asset := localAsset(store, outputDir, slug, rawURL, screenshotName(rawURL))
+			if ref == "" {
+				continue
+			}
+			local = append(local, ref)
+			if asset != nil && !seen[rawURL] {
+				seen[rawURL] = true
+				exported = append(exported, *asset)
+			}
+		}
+		dyno.Set(descriptions[lang], local, "screenshots") // nolint: errcheck
+	}
+
+	if len(exported) > 0 {
+		dyno.Set(doc, exported, "assets") // nolint: errcheck
+	}
+}
+
+// localizeScreenshots makes the variant sw reference the local copies of
+// its screenshots, which are exported with it.
+func localizeScreenshots(store *assets.Store, outputDir string, sw *software) {
+	if sw.Slug == "" {
+		return
+	}
+	for lang, desc := range sw.PublicCode.Description {
+		var local []string
+		for _, rawURL := range desc.Screenshots {
+			if ref, _ := localAsset(store, outputDir, sw.Slug, rawURL, screenshotName(rawURL)); ref != "" {
+				local = append(local, ref)
+			}
+		}
+		desc.Screenshots = local
+		sw.PublicCode.Description[lang] = desc
+	}
+}
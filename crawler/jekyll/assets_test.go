@@ -0,0 +1,73 @@
+package jekyll
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizeAssets(t *testing.T) {
+	dir := setupExport(t)
+	defer os.RemoveAll(dir)
+	viper.Set("ASSETS_THUMBNAIL_WIDTH", 100)
+	defer viper.Set("ASSETS_THUMBNAIL_WIDTH", nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logo.svg":
+			w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)) // nolint: errcheck
+		case "/shot.gif":
+			w.Write([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")) // nolint: errcheck
+		case "/page.html":
+			w.Write([]byte("<html></html>")) // nolint: errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"slug": "c_h501-app/../x",
+		"publiccode": {
+			"logo": "`+ts.URL+`/logo.svg",
+			"monochromeLogo": "`+ts.URL+`/page.html",
+			"description": {
+				"en": {"screenshots": ["`+ts.URL+`/shot.gif", "`+ts.URL+`/missing.png"]},
+				"it": {"screenshots": ["`+ts.URL+`/shot.gif"]}
+			}
+		}
+	}`), &doc))
+
+	outputDir := path.Join(dir, "output")
+	shot := screenshotName(ts.URL + "/shot.gif")
+	localizeAssets(NewAssetStore(), outputDir, doc)
+
+	out, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"slug": "c_h501-app/../x",
+		"publiccode": {
+			"logo": "assets/c_h501-app-x/logo.svg",
+			"description": {
+				"en": {"screenshots": ["assets/c_h501-app-x/`+shot+`.gif", "`+ts.URL+`/missing.png"]},
+				"it": {"screenshots": ["assets/c_h501-app-x/`+shot+`.gif"]}
+			}
+		},
+		"assets": [
+			{"source": "`+ts.URL+`/logo.svg", "path": "assets/c_h501-app-x/logo.svg", "contentType": "image/svg+xml"},
+			{"source": "`+ts.URL+`/shot.gif", "path": "assets/c_h501-app-x/`+shot+`.gif", "contentType": "image/gif"}
+		]
+	}`, string(out))
+
+	for _, name := range []string{"logo.svg", shot + ".gif"} {
+		_, err := os.Stat(path.Join(outputDir, "assets/c_h501-app-x", name))
+		assert.NoError(t, err)
+	}
+}
@@ -1,65 +1,92 @@
 package jekyll
 
 import (
-	"os"
+	"fmt"
+	"io/ioutil"
 	"path"
 
+	"github.com/ghodss/yaml"
+	"github.com/italia/developers-italia-backend/crawler/feeds"
 	"github.com/olivere/elastic"
-	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
-// GenerateJekyllYML generate all the yml files that will be used by Jekyll to generate the static site.
-func GenerateJekyllYML(elasticClient *elastic.Client) error {
-	// Make sure the output directory exists or spit an error
-	outputDir := viper.GetString("OUTPUT_DIR")
-	if stat, err := os.Stat(outputDir); err != nil || !stat.IsDir() {
-		log.Fatalf("The configured output directory (%v) does not exist: %v", outputDir, err)
-	}
+// Exporter exports the YAML data files used by Jekyll to generate the static site.
+type Exporter struct{}
+
+// Export generates all the yml files that will be used by Jekyll to generate the static site.
+// It stops at the first error, since a partial export must not be published.
+// It returns the number of software exported to softwares.yml.
+func (Exporter) Export(outputDir string, elasticClient *elastic.Client) (int64, error) {
+	// The logos and the screenshots are served from outputDir/assets.
+	store := NewAssetStore()
 
 	// Create and populate amministrazioni.yml
 	amministrazioniFilePath := path.Join(outputDir, "amministrazioni.yml")
 	err := AmministrazioniYML(amministrazioniFilePath, elasticClient)
 	if err != nil {
-		log.Error(err)
+		return 0, fmt.Errorf("error exporting jekyll file of administrations: %v", err)
 	}
 
 	// Create and populate software-riuso.yml
 	softwareRiusoFilePath := path.Join(outputDir, "software-riuso.yml")
 	numberOfSoftwareRiuso := 4
-	err = FirstSoftwareRiuso(softwareRiusoFilePath, numberOfSoftwareRiuso, elasticClient)
+	err = FirstSoftwareRiuso(softwareRiusoFilePath, numberOfSoftwareRiuso, elasticClient, store)
 	if err != nil {
-		log.Error(err)
+		return 0, fmt.Errorf("error exporting jekyll file of reuse software: %v", err)
 	}
 
 	// Create and populate software-open-source.yml
 	softwareOSFilePath := path.Join(outputDir, "software-open-source.yml")
 	numberOfSoftwareOS := 4
-	err = FirstSoftwareOpenSource(softwareOSFilePath, numberOfSoftwareOS, elasticClient)
+	err = FirstSoftwareOpenSource(softwareOSFilePath, numberOfSoftwareOS, elasticClient, store)
 	if err != nil {
-		log.Error(err)
+		return 0, fmt.Errorf("error exporting jekyll file of open source software: %v", err)
 	}
 
 	// Create and populate softwares.yml
 	softwaresFilePath := path.Join(outputDir, "softwares.yml")
 	numberOfSimilarSoftware := 4
 	numberOfPopularCategories := 5
-	err = AllSoftwareYML(softwaresFilePath, numberOfSimilarSoftware, numberOfPopularCategories, elasticClient)
+	count, err := AllSoftwareYML(softwaresFilePath, numberOfSimilarSoftware, numberOfPopularCategories, elasticClient, store)
 	if err != nil {
-		log.Errorf("Error exporting jekyll file of all the software : %v", err)
+		return 0, fmt.Errorf("error exporting jekyll file of all the software: %v", err)
 	}
 
 	// Export the list of distinct categories mentioned in the catalog
 	err = CategoriesYML(path.Join(outputDir, "software_categories.yml"), elasticClient)
 	if err != nil {
-		log.Errorf("Error exporting jekyll file of software categories: %v", err)
+		return 0, fmt.Errorf("error exporting jekyll file of software categories: %v", err)
 	}
 
 	// Export the list of distinct scopes mentioned in the catalog
 	err = ScopesYML(path.Join(outputDir, "software_scopes.yml"), elasticClient)
 	if err != nil {
-		log.Errorf("Error exporting jekyll file of software scopes: %v", err)
+		return 0, fmt.Errorf("error exporting jekyll file of software scopes: %v", err)
+	}
+
+	// Export the feeds of new and updated software
+	err = feeds.Generate(path.Join(outputDir, "feeds"), elasticClient)
+	if err != nil {
+		return 0, fmt.Errorf("error exporting feeds: %v", err)
+	}
+
+	return count, nil
+}
+
+// Validate checks that softwares.yml in outputDir contains all the software.
+func (Exporter) Validate(outputDir string, count int64) error {
+	data, err := ioutil.ReadFile(path.Join(outputDir, "softwares.yml"))
+	if err != nil {
+		return err
+	}
+
+	var softwares []interface{}
+	if err := yaml.Unmarshal(data, &softwares); err != nil {
+		return err
+	}
+	if int64(len(softwares)) != count {
+		return fmt.Errorf("softwares.yml contains %d software, %d expected", len(softwares), count)
 	}
 
-	return err
+	return nil
 }
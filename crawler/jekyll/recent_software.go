@@ -3,9 +3,11 @@ package jekyll
 import (
 	"context"
 	"encoding/json"
-	"os"
+	"io/ioutil"
+	"path"
 
 	"github.com/ghodss/yaml"
+	"github.com/italia/developers-italia-backend/crawler/assets"
 	"github.com/italia/developers-italia-backend/crawler/elastic"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -29,48 +31,26 @@ type shortSoftware struct {
 }
 
 // FirstSoftwareRiuso generates a YAML file with simplified info about software, ordered by releaseDate.
-func FirstSoftwareRiuso(filename string, results int, elasticClient *es.Client) error {
+func FirstSoftwareRiuso(filename string, results int, elasticClient *es.Client, store *assets.Store) error {
 	query := elastic.NewBoolQuery("software")
 	query = query.Must(es.NewExistsQuery("publiccode.it.riuso.codiceIPA"))
 
-	return exportSoftwareList(query, filename, results, elasticClient)
+	return exportSoftwareList(query, filename, results, elasticClient, store)
 }
 
 // FirstSoftwareOpenSource generates a YAML file with simplified info about software, ordered by releaseDate.
-func FirstSoftwareOpenSource(filename string, results int, elasticClient *es.Client) error {
+func FirstSoftwareOpenSource(filename string, results int, elasticClient *es.Client, store *assets.Store) error {
 	query := elastic.NewBoolQuery("software")
 	query = query.MustNot(es.NewExistsQuery("publiccode.it.riuso.codiceIPA"))
 
-	return exportSoftwareList(query, filename, results, elasticClient)
+	return exportSoftwareList(query, filename, results, elasticClient, store)
 }
 
 // exportSoftwareList generates a yml file with simplified info about software, ordered by releaseDate.
-func exportSoftwareList(query *es.BoolQuery, filename string, results int, elasticClient *es.Client) error {
+// If store is not nil, the logos are exported next to it and referenced locally.
+func exportSoftwareList(query *es.BoolQuery, filename string, results int, elasticClient *es.Client, store *assets.Store) error {
 	log.Infof("Generating %s", filename)
 
-	// Create file if not exists.
-	if _, err := os.Stat(filename); os.IsExist(err) {
-		err := os.Remove(filename)
-		if err != nil {
-			return err
-		}
-	}
-
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	err = file.Close()
-	if err != nil {
-		return err
-	}
-	// Open file.
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
-		return err
-	}
-	defer f.Close() // nolint: errcheck
-
 	// Extract all the documents.
 	searchResult, err := elasticClient.Search().
 		Index(viper.GetString("ELASTIC_PUBLICCODE_INDEX")). // search in index "publiccode"
@@ -80,7 +60,7 @@ func exportSoftwareList(query *es.BoolQuery, filename string, results int, elast
 		From(0).Size(results).                              // get first 10k elements. It can be changed.
 		Do(context.Background())                            // execute
 	if err != nil {
-		log.Error(err)
+		return err
 	}
 
 	var items []shortSoftware
@@ -89,6 +69,9 @@ func exportSoftwareList(query *es.BoolQuery, filename string, results int, elast
 		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
 			log.Error(err)
 		}
+		if store != nil && sw.PublicCode.Logo != "" {
+			localizeLogo(store, path.Dir(filename), *hit.Source, &sw)
+		}
 		items = append(items, sw)
 	}
 
@@ -102,10 +85,19 @@ func exportSoftwareList(query *es.BoolQuery, filename string, results int, elast
 	if err != nil {
 		return err
 	}
-	// Append data to file.
-	if _, err = f.WriteString(string(d)); err != nil {
-		return err
+
+	return ioutil.WriteFile(filename, d, 0644)
+}
+
+// localizeLogo makes sw, whose document is source, reference the local copy
+// of its logo.
+func localizeLogo(store *assets.Store, outputDir string, source []byte, sw *shortSoftware) {
+	var ref struct {
+		Slug string `json:"slug"`
+	}
+	if err := json.Unmarshal(source, &ref); err != nil || ref.Slug == "" {
+		return
 	}
 
-	return err
+	sw.PublicCode.Logo, _ = localAsset(store, outputDir, ref.Slug, sw.PublicCode.Logo, "logo")
 }
@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"path"
 	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/spf13/viper"
 
 	"github.com/ghodss/yaml"
 	"github.com/icza/dyno"
+	"github.com/italia/developers-italia-backend/crawler/assets"
 	"github.com/italia/developers-italia-backend/crawler/elastic"
 	es "github.com/olivere/elastic"
 	log "github.com/sirupsen/logrus"
@@ -21,8 +24,10 @@ import (
 // in Elasticsearch that are needed for computing additional information
 // and for exporting variants and related software.
 type software struct {
-	ID         string `json:"id"`
-	Slug       string `json:"slug"`
+	ID   string `json:"id"`
+	Slug string `json:"slug"`
+	// Upstream is the repository forked or mirrored by the software, if any.
+	Upstream   string `json:"upstream,omitempty"`
 	PublicCode struct {
 		URL         string   `json:"url"`
 		Name        string   `json:"name"`
@@ -48,113 +53,197 @@ type software struct {
 	variants []software
 }
 
-// AllSoftwareYML generate the softwares.yml file
-func AllSoftwareYML(filename string, numberOfSimilarSoftware, numberOfPopularCategories int, elasticClient *es.Client) error {
-	log.Infof("Generating %s", filename)
-	// Create file if not exists.
-	if _, err := os.Stat(filename); os.IsExist(err) {
-		err := os.Remove(filename)
-		if err != nil {
-			return err
-		}
+// catalog contains the data about all the software that is needed to
+// compute variants and popular categories. It's loaded once per export.
+type catalog struct {
+	// Software by publiccode.url.
+	byURL map[string][]software
+	// Software by the URLs in their publiccode.isBasedOn.
+	byBasedOn map[string][]software
+	// Software by the repository they are a fork or mirror of.
+	byUpstream map[string][]software
+	// Categories by number of software, most popular first.
+	categories []string
+}
+
+// loadCatalog scrolls the whole index and builds the catalog.
+func loadCatalog(elasticClient *es.Client) (*catalog, error) {
+	cat := catalog{
+		byURL:      map[string][]software{},
+		byBasedOn:  map[string][]software{},
+		byUpstream: map[string][]software{},
 	}
+	categoryCount := map[string]int{}
 
-	file, err := os.Create(filename)
+	query := elastic.NewBoolQuery("software")
+	fields := es.NewFetchSourceContext(true).Include("id", "slug", "upstream", "publiccode.*")
+	err := elastic.Scroll(viper.GetString("ELASTIC_PUBLICCODE_INDEX"), query, fields, elasticClient, func(hit *es.SearchHit) error {
+		var sw software
+		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
+			log.Error(err)
+			return nil
+		}
+
+		// The URLs are normalized, as forks and isBasedOn may refer to the
+		// same repository in different ways.
+		url := NormalizeURL(sw.PublicCode.URL)
+		cat.byURL[url] = append(cat.byURL[url], sw)
+		for _, url := range sw.PublicCode.IsBasedOn {
+			url = NormalizeURL(url)
+			cat.byBasedOn[url] = append(cat.byBasedOn[url], sw)
+		}
+		if sw.Upstream != "" {
+			upstream := NormalizeURL(sw.Upstream)
+			cat.byUpstream[upstream] = append(cat.byUpstream[upstream], sw)
+		}
+		for _, v := range sw.PublicCode.Categories {
+			categoryCount[v]++
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	for k := range categoryCount {
+		cat.categories = append(cat.categories, k)
 	}
-	err = file.Close()
+	sort.Slice(cat.categories, func(i, j int) bool {
+		ci, cj := cat.categories[i], cat.categories[j]
+		if categoryCount[ci] != categoryCount[cj] {
+			return categoryCount[ci] > categoryCount[cj]
+		}
+		return ci < cj
+	})
+
+	return &cat, nil
+}
+
+// AllSoftwareYML generate the softwares.yml file. If store is not nil, the
+// logos and the screenshots are exported next to it and referenced locally.
+// It returns the number of software in Elasticsearch when the export started,
+// less the ones skipped because they are not valid.
+func AllSoftwareYML(filename string, numberOfSimilarSoftware, numberOfPopularCategories int, elasticClient *es.Client, store *assets.Store) (int64, error) {
+	log.Infof("Generating %s", filename)
+
+	// Load what we need to know about all the software only once, instead of
+	// scanning the whole index again for every software.
+	cat, err := loadCatalog(elasticClient)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	// Open file.
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600)
+
+	f, err := os.Create(filename)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer f.Close() // nolint: errcheck
 
 	// Extract all the softwares.
+	var skipped int64
 	query := elastic.NewBoolQuery("software")
-	searchResult, err := elasticClient.Search().
-		Index(viper.GetString("ELASTIC_PUBLICCODE_INDEX")). // search in index "publiccode"
-		Query(query).                                       // specify the query
-		Pretty(true).                                       // pretty print request and response JSON
-		From(0).Size(10000).                                // get first 10k elements. The limit can be changed in ES.
-		Do(context.Background())                            // execute
-	if err != nil {
-		log.Error(err)
-	}
-
-	for _, hit := range searchResult.Hits.Hits {
+	total, err := elastic.ScrollTotal(viper.GetString("ELASTIC_PUBLICCODE_INDEX"), query, nil, elasticClient, func(hit *es.SearchHit) error {
 		// hit.Source contains the raw JSON
 		// We parse it into the first item of a slice, so that we can generate
 		// YAML that looks like a single item and we can append it to the output
 		// file as we go, without keeping all items in memory.
 		full := make([]interface{}, 1)
 		if err := json.Unmarshal(*hit.Source, &full[0]); err != nil {
-			log.Error(err)
+			log.Errorf("Skipping software %s: %v", hit.Id, err)
+			skipped++
+			return nil
 		}
 
 		// Let's parse the record again to get the fields we need for computing
 		// additional information.
 		var sw software
 		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
-			log.Error(err)
+			log.Errorf("Skipping software %s: %v", hit.Id, err)
+			skipped++
+			return nil
+		}
+		sw.variants = cat.findVariants(&sw)
+		if store != nil {
+			localizeAssets(store, path.Dir(filename), full[0])
+			for i := range sw.variants {
+				localizeScreenshots(store, path.Dir(filename), &sw.variants[i])
+			}
+		}
+		related, err := sw.findRelated(numberOfSimilarSoftware, elasticClient)
+		if err != nil {
+			return err
 		}
 
 		// Populate the output object with additional information
-		dyno.Set(full[0], sw.findVariants(elasticClient), "oldVariant")
+		dyno.Set(full[0], sw.variants, "oldVariant")
 		dyno.Set(full[0], sw.variantsFeatures(), "oldFeatures")
-		dyno.Set(full[0], sw.findRelated(numberOfSimilarSoftware, elasticClient), "relatedSoftwares")
-		dyno.Set(full[0], sw.getPopularCategories(numberOfPopularCategories, elasticClient), "popularCategories")
+		dyno.Set(full[0], related, "relatedSoftwares")
+		dyno.Set(full[0], cat.getPopularCategories(&sw, numberOfPopularCategories), "popularCategories")
 
 		// Convert it to YAML
 		yaml, err := yaml.Marshal(&full)
 		if err != nil {
-			log.Error(err)
+			log.Errorf("Skipping software %s: %v", sw.ID, err)
+			skipped++
+			return nil
 		}
 
 		// Append data to file.
-		if _, err = f.WriteString(string(yaml)); err != nil {
-			return err
-		}
+		_, err = f.WriteString(string(yaml))
+		return err
+	})
+	if err != nil {
+		return total, err
 	}
 
-	return err
+	return total - skipped, f.Close()
 }
 
-// findVariants returns a list of variants of the given software.
-func (sw *software) findVariants(elasticClient *es.Client) []software {
-	query := elastic.NewBoolQuery("software")
-	searchResult, err := elasticClient.Search().
-		Index(viper.GetString("ELASTIC_PUBLICCODE_INDEX")). // search in index "publiccode"
-		Query(query).                                       // specify the query
-		Pretty(true).                                       // pretty print request and response JSON
-		From(0).Size(10000).                                // get first 10k elements. The limit can be changed in ES.
-		Do(context.Background())                            // execute
-	if err != nil {
-		log.Error(err)
-	}
-
+// findVariants returns a list of variants of the given software: the software
+// it's based on or forked from, and the software based on it or forking it.
+func (cat *catalog) findVariants(sw *software) []software {
 	var sws []software
-	for _, item := range searchResult.Each(reflect.TypeOf(*sw)) {
-		i := item.(software)
-
-		// TODO: this filtering logic should be moved to the ES query
-
-		// skip identity
-		if i.PublicCode.URL == sw.PublicCode.URL {
-			continue
-		}
-
-		if funk.Contains(sw.PublicCode.IsBasedOn, i.PublicCode.URL) || funk.Contains(i.PublicCode.IsBasedOn, sw.PublicCode.URL) {
+	seen := map[string]bool{sw.ID: true}
+	url := NormalizeURL(sw.PublicCode.URL)
+
+	add := func(candidates []software) {
+		for _, i := range candidates {
+			// skip identity and duplicates
+			if seen[i.ID] || NormalizeURL(i.PublicCode.URL) == url {
+				continue
+			}
+			seen[i.ID] = true
 			sws = append(sws, i)
 		}
 	}
+
+	for _, basedOn := range sw.PublicCode.IsBasedOn {
+		add(cat.byURL[NormalizeURL(basedOn)])
+	}
+	if sw.Upstream != "" {
+		add(cat.byURL[NormalizeURL(sw.Upstream)])
+	}
+	add(cat.byBasedOn[url])
+	add(cat.byUpstream[url])
+
 	return sws
 }
 
+// NormalizeURL returns the repository URL without scheme, ".git" suffix and
+// trailing slashes, in lowercase, so that the different forms of the same
+// URL can be compared.
+func NormalizeURL(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+	u = strings.TrimRight(u, "/")
+	u = strings.TrimSuffix(u, ".git")
+
+	return strings.TrimRight(u, "/")
+}
+
 // variantsFeatures returns features of variants that are not included in this one
 func (sw *software) variantsFeatures() map[string][]string {
 	diff := map[string][]string{} // "it" => [ feature, feature ... ]
@@ -174,7 +263,7 @@ func (sw *software) variantsFeatures() map[string][]string {
 }
 
 // findRelated returns a list of similar software based on categories.
-func (sw *software) findRelated(numberOfSimilarSoftware int, elasticClient *es.Client) []software {
+func (sw *software) findRelated(numberOfSimilarSoftware int, elasticClient *es.Client) ([]software, error) {
 	query := elastic.NewBoolQuery("software")
 	for _, tag := range sw.PublicCode.Categories {
 		query = query.Should(es.NewTermQuery("publiccode.categories", tag))
@@ -189,7 +278,7 @@ func (sw *software) findRelated(numberOfSimilarSoftware int, elasticClient *es.C
 		Pretty(true).                                       // pretty print request and response JSON
 		Do(context.Background())                            // execute
 	if err != nil {
-		log.Error(err)
+		return nil, err
 	}
 
 	var sws []software
@@ -197,57 +286,18 @@ func (sw *software) findRelated(numberOfSimilarSoftware int, elasticClient *es.C
 		i := item.(software)
 		sws = append(sws, i)
 	}
-	return sws
+	return sws, nil
 }
 
-func (sw *software) getPopularCategories(number int, elasticClient *es.Client) []string {
+// getPopularCategories returns the most popular categories in the catalog,
+// or the categories of the given software if it has less than number.
+func (cat *catalog) getPopularCategories(sw *software, number int) []string {
 	if len(sw.PublicCode.Categories) < number {
 		return sw.PublicCode.Categories
 	}
 
-	// Extract all the documents. It should filter only the ones with isBaseOn=url.
-	query := elastic.NewBoolQuery("software")
-	searchResult, err := elasticClient.Search().
-		Index(viper.GetString("ELASTIC_PUBLICCODE_INDEX")). // search in index "publiccode"
-		Query(query).                                       // specify the query
-		Pretty(true).                                       // pretty print request and response JSON
-		From(0).Size(10000).                                // get first 10k elements. The limit can be changed in ES.
-		Do(context.Background())                            // execute
-	if err != nil {
-		log.Error(err)
-	}
-
-	results := map[string]int{}
-
-	// Range over the publiccodes in ES.
-	for _, item := range searchResult.Each(reflect.TypeOf(*sw)) {
-		i := item.(software)
-		for _, v := range i.PublicCode.Categories {
-			results[v]++
-		}
-	}
-
-	// Order the map into a slice.
-	type kv struct {
-		Key   string
-		Value int
+	if len(cat.categories) < number {
+		return cat.categories
 	}
-	var ss []kv
-	for k, v := range results {
-		ss = append(ss, kv{k, v})
-	}
-	sort.Slice(ss, func(i, j int) bool {
-		return ss[i].Value > ss[j].Value
-	})
-
-	// Populate the popularCategories slice with most popular categories.
-	var popularCategories []string
-	for n, kv := range ss {
-		if n < number {
-			break
-		}
-		popularCategories = append(popularCategories, kv.Key)
-	}
-
-	return popularCategories
+	return cat.categories[:number]
 }
@@ -0,0 +1,188 @@
+package jekyll
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/italia/developers-italia-backend/crawler/elastic/elastictest"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSoftware returns a software document. Software are grouped in families
+// of 10 variants, all based on the first one of the family.
+func fakeSoftware(i int) interface{} {
+	url := fmt.Sprintf("https://github.com/example/repo-%d", i)
+	publiccode := map[string]interface{}{
+		"name":       fmt.Sprintf("Software %d", i),
+		"url":        url,
+		"categories": []string{"it-development", fmt.Sprintf("category-%d", i%7)},
+		"it": map[string]interface{}{
+			"riuso": map[string]interface{}{"codiceIPA": fmt.Sprintf("c_%05d", i)},
+		},
+		"description": map[string]interface{}{
+			"it": map[string]interface{}{"features": []string{fmt.Sprintf("feature-%d", i)}},
+		},
+	}
+	if i%10 != 0 {
+		publiccode["isBasedOn"] = []string{fmt.Sprintf("https://github.com/example/repo-%d", i-i%10)}
+	}
+
+	return map[string]interface{}{
+		"id":         fmt.Sprintf("id-%d", i),
+		"slug":       fmt.Sprintf("slug-%d", i),
+		"publiccode": publiccode,
+	}
+}
+
+func fakeSoftwareList(n int) []interface{} {
+	docs := make([]interface{}, n)
+	for i := range docs {
+		docs[i] = fakeSoftware(i)
+	}
+	return docs
+}
+
+func setupExport(t testing.TB) string {
+	log.SetOutput(ioutil.Discard)
+
+	dir, err := ioutil.TempDir("", "jekyll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+	viper.Set("CRAWLER_DATADIR", dir)
+
+	return dir
+}
+
+// Exports must not stop at the 10k results of a single search.
+func TestAmministrazioniYMLMoreThan10k(t *testing.T) {
+	dir := setupExport(t)
+	defer os.RemoveAll(dir)
+
+	server, client := elastictest.NewClient(t, fakeSoftwareList(10500))
+	defer server.Close()
+
+	filename := path.Join(dir, "amministrazioni.yml")
+	err := AmministrazioniYML(filename, client)
+	assert.Nil(t, err)
+
+	data, err := ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	var administrations []interface{}
+	assert.Nil(t, yaml.Unmarshal(data, &administrations))
+	assert.Len(t, administrations, 10500)
+}
+
+func TestAllSoftwareYML(t *testing.T) {
+	dir := setupExport(t)
+	defer os.RemoveAll(dir)
+
+	server, client := elastictest.NewClient(t, fakeSoftwareList(20))
+	defer server.Close()
+
+	filename := path.Join(dir, "softwares.yml")
+	count, err := AllSoftwareYML(filename, 4, 1, client, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), count)
+
+	data, err := ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	var softwares []struct {
+		ID                string     `json:"id"`
+		OldVariant        []software `json:"oldVariant"`
+		PopularCategories []string   `json:"popularCategories"`
+		OldFeatures       struct {
+			It []string `json:"it"`
+		} `json:"oldFeatures"`
+	}
+	assert.Nil(t, yaml.Unmarshal(data, &softwares))
+	assert.Len(t, softwares, 20)
+
+	// The base software has all the others in its family as variants...
+	assert.Equal(t, "id-0", softwares[0].ID)
+	assert.Len(t, softwares[0].OldVariant, 9)
+	assert.Len(t, softwares[0].OldFeatures.It, 9)
+	// ...while the others only have the base software.
+	assert.Len(t, softwares[1].OldVariant, 1)
+	assert.Equal(t, "id-0", softwares[1].OldVariant[0].ID)
+
+	assert.Equal(t, []string{"it-development"}, softwares[0].PopularCategories)
+}
+
+func TestAllSoftwareYMLSkipped(t *testing.T) {
+	dir := setupExport(t)
+	defer os.RemoveAll(dir)
+
+	docs := append(fakeSoftwareList(3), map[string]interface{}{"id": 42})
+	server, client := elastictest.NewClient(t, docs)
+	defer server.Close()
+
+	// The invalid software is left out of the export, which is still valid.
+	count, err := AllSoftwareYML(path.Join(dir, "softwares.yml"), 4, 1, client, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Nil(t, Exporter{}.Validate(dir, count))
+}
+
+func BenchmarkAllSoftwareYML(b *testing.B) {
+	dir := setupExport(b)
+	defer os.RemoveAll(dir)
+
+	server, client := elastictest.NewClient(b, fakeSoftwareList(20000))
+	defer server.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := AllSoftwareYML(path.Join(dir, "softwares.yml"), 4, 5, client, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	for _, u := range []string{
+		"https://github.com/italia/repo1",
+		"http://github.com/italia/repo1.git",
+		" https://GitHub.com/italia/Repo1/ ",
+		"https://github.com/italia/repo1.git/",
+	} {
+		assert.Equal(t, "github.com/italia/repo1", NormalizeURL(u), u)
+	}
+}
+
+func TestFindVariantsForks(t *testing.T) {
+	var original, fork, basedOn software
+	original.ID, original.PublicCode.URL = "original", "https://github.com/example/original"
+	fork.ID, fork.PublicCode.URL = "fork", "https://github.com/pa/original.git"
+	fork.Upstream = "https://github.com/Example/original/"
+	basedOn.ID, basedOn.PublicCode.URL = "based", "https://gitlab.com/pa/other"
+	basedOn.PublicCode.IsBasedOn = []string{"https://github.com/example/original.git"}
+
+	cat := catalog{
+		byURL: map[string][]software{
+			"github.com/example/original": {original},
+			"github.com/pa/original":      {fork},
+			"gitlab.com/pa/other":         {basedOn},
+		},
+		byBasedOn:  map[string][]software{"github.com/example/original": {basedOn}},
+		byUpstream: map[string][]software{"github.com/example/original": {fork}},
+	}
+
+	variants := func(sw software) (ids []string) {
+		for _, v := range cat.findVariants(&sw) {
+			ids = append(ids, v.ID)
+		}
+		return
+	}
+	assert.Equal(t, []string{"based", "fork"}, variants(original))
+	assert.Equal(t, []string{"original"}, variants(fork))
+	assert.Equal(t, []string{"original"}, variants(basedOn))
+}
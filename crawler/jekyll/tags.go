@@ -2,7 +2,8 @@ package jekyll
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"io/ioutil"
 
 	"github.com/ghodss/yaml"
 	"github.com/italia/developers-italia-backend/crawler/elastic"
@@ -31,15 +32,15 @@ func exportDistinctValuesToYAML(key, destFile string, elasticClient *es.Client)
 		Index(viper.GetString("ELASTIC_PUBLICCODE_INDEX")). // search in index "publiccode"
 		Query(query).                                       // specify the query
 		Aggregation(key, agg).
-		From(0).Size(10000).     // get first 10k elements. The limit can be changed in ES.
+		Size(0).                 // we only need the aggregation, not the documents.
 		Do(context.Background()) // execute
 	if err != nil {
-		log.Error(err)
+		return err
 	}
 
 	aggRes, ok := searchResult.Aggregations.Terms(key)
 	if !ok {
-		log.Errorf("did not find %v in Elasticsearch response", key)
+		return fmt.Errorf("did not find %v in Elasticsearch response", key)
 	}
 
 	var values []string
@@ -51,39 +52,11 @@ func exportDistinctValuesToYAML(key, destFile string, elasticClient *es.Client)
 }
 
 func writeYAMLList(list *[]string, destFile string) error {
-	// Create file if not exists.
-	if _, err := os.Stat(destFile); os.IsExist(err) {
-		err := os.Remove(destFile)
-		if err != nil {
-			return err
-		}
-	}
-
-	file, err := os.Create(destFile)
-	if err != nil {
-		return err
-	}
-	err = file.Close()
-	if err != nil {
-		return err
-	}
-
-	// Open file.
-	f, err := os.OpenFile(destFile, os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
-		return err
-	}
-	defer f.Close() // nolint: errcheck
-
 	// Marshal yml.
 	d, err := yaml.Marshal(list)
 	if err != nil {
 		return err
 	}
-	// Append data to file.
-	if _, err = f.WriteString(string(d)); err != nil {
-		return err
-	}
 
-	return err
+	return ioutil.WriteFile(destFile, d, 0644)
 }
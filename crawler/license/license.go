@@ -0,0 +1,270 @@
+// Package license detects the licenses of the files in a repository.
+//
+// The licenses are read from the license files in the root of the repository
+// (LICENSE, COPYING, ...), from the LICENSES directory of the REUSE
+// specification and from the SPDX-License-Identifier headers in the files,
+// and are normalized to SPDX identifiers so that they can be compared with
+// legal.license in the publiccode.yml.
+package license
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree"
+)
+
+// Report is the result of the comparison between the licenses declared in
+// the publiccode.yml and the ones found in the repository.
+type Report struct {
+	// Declared is the SPDX expression in legal.license.
+	Declared string `json:"declared"`
+	// Detected are the SPDX identifiers found in the repository.
+	Detected []string `json:"detected"`
+	// Mismatch is true if none of the licenses found in the repository is
+	// in the declared expression.
+	Mismatch bool `json:"mismatch"`
+}
+
+// MaxFiles is the maximum number of files scanned for SPDX headers.
+const MaxFiles = 10000
+
+// maxFileSize is the size of the biggest file scanned for SPDX headers,
+// headerLines is how many lines at the start of a file are scanned.
+const (
+	maxFileSize = 1 << 20
+	headerLines = 30
+)
+
+// licenseFiles are the prefixes of the names of the license files, in
+// uppercase.
+var licenseFiles = []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"}
+
+var spdxHeader = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+:() -]+)`)
+
+var spdxID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+:-]*$`)
+
+// HeaderScanner is a worktree.Visitor collecting the licenses in the
+// SPDX-License-Identifier headers of the files.
+type HeaderScanner struct {
+	ids     []string
+	scanned int
+}
+
+// Check returns the licenses found in the repository in dir and whether
+// they match declared, the SPDX expression in legal.license.
+func Check(dir, declared string) (Report, error) {
+	var headers HeaderScanner
+	if err := worktree.Walk(dir, &headers); err != nil {
+		return Report{}, err
+	}
+
+	return headers.Check(dir, declared)
+}
+
+// Detect returns the sorted SPDX identifiers of the licenses found in the
+// repository in dir.
+func Detect(dir string) ([]string, error) {
+	var headers HeaderScanner
+	if err := worktree.Walk(dir, &headers); err != nil {
+		return nil, err
+	}
+
+	return headers.Detect(dir)
+}
+
+// Check is like the Check function, with the headers found by s in the
+// working tree in dir.
+func (s *HeaderScanner) Check(dir, declared string) (Report, error) {
+	detected, err := s.Detect(dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return Report{
+		Declared: declared,
+		Detected: detected,
+		Mismatch: mismatch(declared, detected),
+	}, nil
+}
+
+// Detect is like the Detect function, with the headers found by s in the
+// working tree in dir.
+func (s *HeaderScanner) Detect(dir string) ([]string, error) {
+	found := map[string]bool{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || !isLicenseFile(f.Name()) {
+			continue
+		}
+		text, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if id := Identify(string(text)); id != "" {
+			found[id] = true
+		}
+	}
+
+	// REUSE keeps the licenses in LICENSES/<SPDX identifier>.txt.
+	reuse, err := ioutil.ReadDir(filepath.Join(dir, "LICENSES"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range reuse {
+		id := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+		if !f.IsDir() && spdxID.MatchString(id) {
+			found[Normalize(id)] = true
+		}
+	}
+
+	for _, id := range s.ids {
+		found[id] = true
+	}
+
+	detected := make([]string, 0, len(found))
+	for id := range found {
+		detected = append(detected, id)
+	}
+	sort.Strings(detected)
+
+	return detected, nil
+}
+
+// Visit implements worktree.Visitor, scanning the headers of up to
+// MaxFiles files.
+func (s *HeaderScanner) Visit(path string, info os.FileInfo) error {
+	if !info.Mode().IsRegular() || info.Size() > maxFileSize {
+		return nil
+	}
+	if s.scanned >= MaxFiles {
+		return worktree.Done
+	}
+	s.scanned++
+
+	expression, err := fileHeader(path)
+	if err != nil {
+		return err
+	}
+	s.ids = append(s.ids, IDs(expression)...)
+
+	return nil
+}
+
+// fileHeader returns the expression in the SPDX-License-Identifier header
+// of the file, or an empty string if there is none.
+func fileHeader(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < headerLines && scanner.Scan(); i++ {
+		if m := spdxHeader.FindStringSubmatch(scanner.Text()); m != nil {
+			return m[1], nil
+		}
+	}
+	// Lines too long for the scanner are not text we are looking for.
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// IDs returns the normalized identifiers of the licenses in the SPDX
+// expression, without the operators and the exceptions.
+func IDs(expression string) []string {
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expression))
+
+	var ids []string
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR":
+			continue
+		case "WITH":
+			// Skip the exception too.
+			i++
+			continue
+		}
+		if spdxID.MatchString(fields[i]) {
+			ids = append(ids, Normalize(fields[i]))
+		}
+	}
+
+	return ids
+}
+
+// Normalize returns the SPDX identifier id with the right case, and with
+// "-or-later" instead of the "+" suffix. Unknown identifiers are returned
+// as they are.
+func Normalize(id string) string {
+	id = strings.TrimSpace(id)
+
+	orLater := strings.HasSuffix(id, "+")
+	id = strings.TrimSuffix(id, "+")
+
+	if known, ok := knownIDs[strings.ToLower(id)]; ok {
+		id = known
+	}
+	if orLater {
+		if known, ok := knownIDs[strings.ToLower(id+"-or-later")]; ok {
+			return known
+		}
+		return id + "+"
+	}
+
+	return id
+}
+
+// base returns the identifier of the license without the "-only" and
+// "-or-later" suffixes, which tell the versions the license can be used
+// with, not which license it is.
+func base(id string) string {
+	id = strings.TrimSuffix(id, "-only")
+	id = strings.TrimSuffix(id, "-or-later")
+
+	return strings.TrimSuffix(id, "+")
+}
+
+// mismatch returns true if licenses were found and none of them is in the
+// declared expression.
+func mismatch(declared string, detected []string) bool {
+	if len(detected) == 0 {
+		return false
+	}
+
+	declaredIDs := map[string]bool{}
+	for _, id := range IDs(declared) {
+		declaredIDs[strings.ToLower(base(id))] = true
+	}
+	for _, id := range detected {
+		if declaredIDs[strings.ToLower(base(id))] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLicenseFile(name string) bool {
+	name = strings.ToUpper(name)
+	for _, prefix := range licenseFiles {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
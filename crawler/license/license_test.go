@@ -0,0 +1,88 @@
+package license
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree/worktreetest"
+	"github.com/stretchr/testify/assert"
+)
+
+const mitText = `MIT License
+
+Copyright (c) 2019 Comune di Roma
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction.`
+
+const agplText = `                    GNU AFFERO GENERAL PUBLIC LICENSE
+                       Version 3, 19 November 2007
+
+ Copyright (C) 2007 Free Software Foundation, Inc. <https://fsf.org/>`
+
+func TestIdentify(t *testing.T) {
+	assert.Equal(t, "MIT", Identify(mitText))
+	assert.Equal(t, "AGPL-3.0", Identify(agplText))
+	assert.Equal(t, "GPL-3.0", Identify("GNU GENERAL PUBLIC LICENSE\n   Version 3, 29 June 2007\n"+
+		"... the GNU Affero General Public License ..."))
+	assert.Equal(t, "EUPL-1.2", Identify("EUROPEAN UNION PUBLIC LICENCE v. 1.2\nEUPL © the European Union 2007, 2016"))
+	assert.Equal(t, "", Identify("All rights reserved."))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "Apache-2.0", Normalize("apache-2.0"))
+	assert.Equal(t, "GPL-3.0-or-later", Normalize("GPL-3.0+"))
+	assert.Equal(t, "AGPL-3.0-only", Normalize("agpl-3.0-only"))
+	assert.Equal(t, "LicenseRef-custom", Normalize("LicenseRef-custom"))
+}
+
+func TestIDs(t *testing.T) {
+	assert.Equal(t, []string{"MIT", "Apache-2.0"}, IDs("(mit OR Apache-2.0)"))
+	assert.Equal(t, []string{"GPL-2.0-or-later"}, IDs("GPL-2.0-or-later WITH Classpath-exception-2.0"))
+	assert.Empty(t, IDs(""))
+}
+
+func TestDetect(t *testing.T) {
+	dir := worktreetest.Write(t, map[string]string{
+		"LICENSE.md":                   mitText,
+		"LICENSES/EUPL-1.2.txt":        "...",
+		"src/main.go":                  "// SPDX-License-Identifier: AGPL-3.0-or-later\npackage main\n",
+		"src/style.css":                "/* SPDX-License-Identifier: MIT */\n",
+		"vendor/lib/lib.go":            "// SPDX-License-Identifier: BSD-3-Clause\n",
+		"node_modules/lib/index.js":    "// SPDX-License-Identifier: ISC\n",
+		"README.md":                    "# Project\n",
+		"docs/SPDX-License-Identifier": "",
+	})
+	defer os.RemoveAll(dir)
+
+	detected, err := Detect(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AGPL-3.0-or-later", "EUPL-1.2", "MIT"}, detected)
+}
+
+func TestCheck(t *testing.T) {
+	dir := worktreetest.Write(t, map[string]string{"COPYING": agplText})
+	defer os.RemoveAll(dir)
+
+	report, err := Check(dir, "AGPL-3.0-or-later")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AGPL-3.0"}, report.Detected)
+	assert.False(t, report.Mismatch)
+
+	report, err = Check(dir, "MIT OR EUPL-1.2")
+	assert.NoError(t, err)
+	assert.True(t, report.Mismatch)
+
+	// Nothing found, nothing to compare.
+	empty := worktreetest.Write(t, nil)
+	defer os.RemoveAll(empty)
+	report, err = Check(empty, "MIT")
+	assert.NoError(t, err)
+	assert.Empty(t, report.Detected)
+	assert.False(t, report.Mismatch)
+
+	_, err = Check(filepath.Join(empty, "missing"), "MIT")
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package license
+
+import (
+	"strings"
+)
+
+// ids are the SPDX identifiers of the most common licenses of the software
+// in the catalog, including the deprecated ones still in use.
+var ids = []string{
+	"0BSD",
+	"AGPL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later",
+	"Apache-1.1", "Apache-2.0",
+	"Artistic-2.0",
+	"BSD-2-Clause", "BSD-3-Clause", "BSD-4-Clause",
+	"BSL-1.0",
+	"CC-BY-4.0", "CC-BY-SA-4.0", "CC0-1.0",
+	"CECILL-2.1",
+	"EPL-1.0", "EPL-2.0",
+	"EUPL-1.1", "EUPL-1.2",
+	"GPL-2.0", "GPL-2.0-only", "GPL-2.0-or-later",
+	"GPL-3.0", "GPL-3.0-only", "GPL-3.0-or-later",
+	"ISC",
+	"LGPL-2.0", "LGPL-2.0-only", "LGPL-2.0-or-later",
+	"LGPL-2.1", "LGPL-2.1-only", "LGPL-2.1-or-later",
+	"LGPL-3.0", "LGPL-3.0-only", "LGPL-3.0-or-later",
+	"MIT",
+	"MPL-1.1", "MPL-2.0",
+	"OFL-1.1",
+	"Unlicense",
+	"WTFPL",
+	"Zlib",
+}
+
+// knownIDs are the ids by their lowercase version.
+var knownIDs = func() map[string]string {
+	m := make(map[string]string, len(ids))
+	for _, id := range ids {
+		m[strings.ToLower(id)] = id
+	}
+
+	return m
+}()
+
+// fingerprint are the phrases, lowercase and with single spaces, all found
+// in the text of the license id. The first matching fingerprint wins, so
+// the licenses quoting other ones come first. The GNU licenses mention each
+// other, so they are matched by their title.
+type fingerprint struct {
+	id      string
+	phrases []string
+}
+
+var fingerprints = []fingerprint{
+	{"AGPL-3.0", []string{"gnu affero general public license version 3, 19 november 2007"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license version 3, 29 june 2007"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license version 2.1, february 1999"}},
+	{"LGPL-2.0", []string{"gnu library general public license version 2, june 1991"}},
+	{"GPL-3.0", []string{"gnu general public license version 3, 29 june 2007"}},
+	{"GPL-2.0", []string{"gnu general public license version 2, june 1991"}},
+	{"EUPL-1.2", []string{"european union public licence", "v. 1.2"}},
+	{"EUPL-1.2", []string{"european union public licence", "v.1.2"}},
+	{"EUPL-1.1", []string{"european union public licence", "v. 1.1"}},
+	{"EUPL-1.1", []string{"european union public licence", "v.1.1"}},
+	{"MPL-2.0", []string{"mozilla public license version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license, v. 2.0"}},
+	{"EPL-2.0", []string{"eclipse public license - v 2.0"}},
+	{"EPL-1.0", []string{"eclipse public license - v 1.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted"}},
+	{"MIT", []string{"permission is hereby granted, free of charge, to any person obtaining a copy"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+}
+
+// Identify returns the SPDX identifier of the license with the given text,
+// or an empty string if it's not known.
+// The GNU licenses are identified by their version only, since whether
+// later versions can be used is not in the text of the license.
+func Identify(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	for _, f := range fingerprints {
+		if containsAll(text, f.phrases) {
+			return f.id
+		}
+	}
+
+	return ""
+}
+
+func containsAll(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+
+	return true
+}
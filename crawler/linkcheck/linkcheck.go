@@ -0,0 +1,357 @@
+// Package linkcheck checks the links and the assets referenced by a
+// publiccode.yml: landing page, roadmap, documentation, logos, screenshots
+// and videos.
+//
+// The results are cached across crawls, so the same URL is not requested
+// again until the cache entry expires, and the requests to the same host
+// are limited. The failures that can be temporary expire sooner.
+package linkcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	publiccode "github.com/italia/publiccode-parser-go"
+)
+
+// Link is a URL referenced by a publiccode.yml.
+type Link struct {
+	// Field is the key of the URL in the publiccode.yml, as in
+	// "description.it.screenshots[0]".
+	Field string
+	URL   string
+}
+
+// Result is the outcome of the check of a link.
+type Result struct {
+	Field string `json:"field"`
+	URL   string `json:"url"`
+	// Status is the HTTP status code, 0 if there is no response.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Broken returns true if the link can't be reached.
+func (r Result) Broken() bool {
+	return r.Error != "" || r.Status >= 400
+}
+
+// Broken returns the broken links in results.
+func Broken(results []Result) []Result {
+	var broken []Result
+	for _, r := range results {
+		if r.Broken() {
+			broken = append(broken, r)
+		}
+	}
+
+	return broken
+}
+
+// Links returns the URLs referenced by pc. The relative paths of the
+// assets are resolved against baseURL, the raw URL of the directory with
+// the publiccode.yml.
+func Links(pc publiccode.PublicCode, baseURL string) []Link {
+	var links []Link
+	add := func(field, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		if u, err := url.Parse(value); err == nil && !u.IsAbs() && baseURL != "" {
+			value = strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(value, "/")
+		}
+		links = append(links, Link{Field: field, URL: value})
+	}
+
+	add("landingURL", pc.LandingURLString)
+	add("roadmap", pc.RoadmapString)
+	add("logo", pc.Logo)
+	add("monochromeLogo", pc.MonochromeLogo)
+
+	var langs []string
+	for lang := range pc.Description {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		desc := pc.Description[lang]
+		prefix := "description." + lang + "."
+		add(prefix+"documentation", desc.DocumentationString)
+		add(prefix+"apiDocumentation", desc.APIDocumentationString)
+		for i, s := range desc.Screenshots {
+			add(fmt.Sprintf("%sscreenshots[%d]", prefix, i), s)
+		}
+		for i, v := range desc.VideosStrings {
+			add(fmt.Sprintf("%svideos[%d]", prefix, i), v)
+		}
+	}
+
+	for i, c := range pc.Maintenance.Contractors {
+		add(fmt.Sprintf("maintenance.contractors[%d].website", i), c.WebsiteString)
+	}
+
+	return links
+}
+
+// entry is a cached result.
+type entry struct {
+	Status  int       `json:"status,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+// temporary returns true if the result can change soon: the request failed,
+// timed out or got a server error.
+func (e entry) temporary() bool {
+	return e.Error != "" || e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// call is a request in flight, whose result is shared by the concurrent
+// checks of the same URL.
+type call struct {
+	done chan struct{}
+	e    entry
+}
+
+// DefaultFailureTTL is for how long the temporary failures are cached by
+// default.
+const DefaultFailureTTL = time.Hour
+
+// Checker checks links, caching the results. It's safe for concurrent use.
+type Checker struct {
+	// Client makes the requests. It should have a timeout.
+	Client *http.Client
+	// TTL is for how long a result is cached.
+	TTL time.Duration
+	// FailureTTL is for how long a temporary failure is cached.
+	FailureTTL time.Duration
+	// PerHost is the maximum number of concurrent requests to a host.
+	PerHost int
+	// UserAgent is sent with the requests, if set.
+	UserAgent string
+
+	mu       sync.Mutex
+	cache    map[string]entry
+	inflight map[string]*call
+	hosts    map[string]chan struct{}
+	now      func() time.Time
+}
+
+// NewChecker returns a Checker with an empty cache.
+func NewChecker(timeout, ttl time.Duration, perHost int) *Checker {
+	if perHost < 1 {
+		perHost = 1
+	}
+
+	return &Checker{
+		Client:     &http.Client{Timeout: timeout},
+		TTL:        ttl,
+		FailureTTL: DefaultFailureTTL,
+		PerHost:    perHost,
+		cache:      map[string]entry{},
+		inflight:   map[string]*call{},
+		hosts:      map[string]chan struct{}{},
+		now:        time.Now,
+	}
+}
+
+// Check checks links and returns their results, in the same order. Every
+// URL is requested at most once, unless its result is cached.
+func (c *Checker) Check(links []Link) []Result {
+	var urls []string
+	seen := map[string]bool{}
+	for _, link := range links {
+		if !seen[link.URL] {
+			seen[link.URL] = true
+			urls = append(urls, link.URL)
+		}
+	}
+
+	entries := map[string]entry{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			e := c.check(u)
+			mu.Lock()
+			entries[u] = e
+			mu.Unlock()
+		}(u)
+	}
+	wg.Wait()
+
+	results := make([]Result, 0, len(links))
+	for _, link := range links {
+		e := entries[link.URL]
+		results = append(results, Result{Field: link.Field, URL: link.URL, Status: e.Status, Error: e.Error})
+	}
+
+	return results
+}
+
+// check returns the cached result of rawURL, or requests it. The checks of
+// a URL already being requested wait for its result.
+func (c *Checker) check(rawURL string) entry {
+	c.mu.Lock()
+	if e, ok := c.cache[rawURL]; ok && c.now().Sub(e.Checked) < c.ttl(e) {
+		c.mu.Unlock()
+		return e
+	}
+	if inflight, ok := c.inflight[rawURL]; ok {
+		c.mu.Unlock()
+		<-inflight.done
+		return inflight.e
+	}
+	request := &call{done: make(chan struct{})}
+	c.inflight[rawURL] = request
+	c.mu.Unlock()
+
+	request.e = c.request(rawURL)
+	request.e.Checked = c.now()
+	c.mu.Lock()
+	c.cache[rawURL] = request.e
+	delete(c.inflight, rawURL)
+	c.mu.Unlock()
+	close(request.done)
+
+	return request.e
+}
+
+// ttl returns for how long e is cached.
+func (c *Checker) ttl(e entry) time.Duration {
+	if e.temporary() && c.FailureTTL < c.TTL {
+		return c.FailureTTL
+	}
+
+	return c.TTL
+}
+
+// request requests rawURL with HEAD and, if it fails, with GET, since
+// some servers don't support HEAD.
+func (c *Checker) request(rawURL string) entry {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return entry{Error: err.Error()}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return entry{Error: fmt.Sprintf("unsupported URL scheme %q", u.Scheme)}
+	}
+
+	sem := c.host(u.Host)
+	sem <- struct{}{}
+	defer func() { <-sem }()
+
+	e := c.do(http.MethodHead, rawURL)
+	if e.Error != "" || e.Status >= 400 {
+		e = c.do(http.MethodGet, rawURL)
+	}
+
+	return e
+}
+
+func (c *Checker) do(method, rawURL string) entry {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return entry{Error: err.Error()}
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return entry{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	// Read a bit of the body, so the connection can be reused.
+	_, _ = io.CopyN(ioutil.Discard, resp.Body, 64*1024)
+
+	return entry{Status: resp.StatusCode}
+}
+
+// host returns the semaphore limiting the requests to host.
+func (c *Checker) host(host string) chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sem, ok := c.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, c.PerHost)
+		c.hosts[host] = sem
+	}
+
+	return sem
+}
+
+// Load reads the cache from the file at path, written by Save. A missing
+// file is an empty cache.
+func (c *Checker) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cache := map[string]entry{}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("invalid link cache %s: %v", path, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for u, e := range cache {
+		c.cache[u] = e
+	}
+
+	return nil
+}
+
+// Save writes the cache to the file at path, leaving out the expired
+// results.
+func (c *Checker) Save(path string) error {
+	c.mu.Lock()
+	cache := map[string]entry{}
+	for u, e := range c.cache {
+		if c.now().Sub(e.Checked) < c.ttl(e) {
+			cache[u] = e
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the cache is never truncated.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
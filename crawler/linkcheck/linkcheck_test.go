@@ -0,0 +1,193 @@
+package linkcheck
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	publiccode "github.com/italia/publiccode-parser-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinks(t *testing.T) {
+	var pc publiccode.PublicCode
+	pc.LandingURLString = "https://example.org"
+	pc.Logo = "img/logo.svg"
+	pc.Description = map[string]publiccode.Desc{
+		"it": {
+			DocumentationString: "https://docs.example.org",
+			Screenshots:         []string{"https://raw.example.org/repo/main/a.png", "/b.png"},
+		},
+		"en": {
+			VideosStrings: []string{"https://video.example.org/1"},
+		},
+	}
+	pc.Maintenance.Contractors = []publiccode.Contractor{{Name: "ACME", WebsiteString: "https://acme.example.org"}}
+
+	assert.Equal(t, []Link{
+		{"landingURL", "https://example.org"},
+		{"logo", "https://raw.example.org/repo/main/img/logo.svg"},
+		{"description.en.videos[0]", "https://video.example.org/1"},
+		{"description.it.documentation", "https://docs.example.org"},
+		{"description.it.screenshots[0]", "https://raw.example.org/repo/main/a.png"},
+		{"description.it.screenshots[1]", "https://raw.example.org/repo/main/b.png"},
+		{"maintenance.contractors[0].website", "https://acme.example.org"},
+	}, Links(pc, "https://raw.example.org/repo/main/"))
+}
+
+func TestCheck(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/ok":
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewChecker(5*time.Second, time.Hour, 2)
+	c.FailureTTL = time.Minute
+	results := c.Check([]Link{
+		{"landingURL", ts.URL + "/ok"},
+		{"roadmap", ts.URL + "/get-only"},
+		{"logo", ts.URL + "/redirect"},
+		{"description.it.documentation", ts.URL + "/missing"},
+		{"description.en.documentation", ts.URL + "/missing"},
+		{"description.it.apiDocumentation", ts.URL + "/error"},
+		{"monochromeLogo", "ftp://example.org/logo.png"},
+	})
+	assert.Equal(t, []Result{
+		{"landingURL", ts.URL + "/ok", 200, ""},
+		{"roadmap", ts.URL + "/get-only", 200, ""},
+		{"logo", ts.URL + "/redirect", 200, ""},
+		{"description.it.documentation", ts.URL + "/missing", 404, ""},
+		{"description.en.documentation", ts.URL + "/missing", 404, ""},
+		{"description.it.apiDocumentation", ts.URL + "/error", 500, ""},
+		{"monochromeLogo", "ftp://example.org/logo.png", 0, `unsupported URL scheme "ftp"`},
+	}, results)
+	assert.Len(t, Broken(results), 4)
+	// HEAD and GET for the broken links, requested once.
+	assert.Equal(t, int32(1+2+2+2+2), atomic.LoadInt32(&requests))
+
+	// The results are cached.
+	c.Check([]Link{{"landingURL", ts.URL + "/ok"}, {"roadmap", ts.URL + "/missing"}})
+	assert.Equal(t, int32(9), atomic.LoadInt32(&requests))
+
+	// The server errors expire sooner.
+	c.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	c.Check([]Link{{"roadmap", ts.URL + "/missing"}, {"description.it.apiDocumentation", ts.URL + "/error"}})
+	assert.Equal(t, int32(11), atomic.LoadInt32(&requests))
+
+	// The others when the TTL expires.
+	c.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	c.Check([]Link{{"landingURL", ts.URL + "/ok"}})
+	assert.Equal(t, int32(12), atomic.LoadInt32(&requests))
+}
+
+func TestCheckInflight(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}))
+	defer ts.Close()
+
+	c := NewChecker(5*time.Second, time.Hour, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := c.Check([]Link{{"landingURL", ts.URL + "/slow"}})
+			assert.Empty(t, Broken(results))
+		}()
+	}
+	// Wait for the first request, then for the other checks to share it.
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	results := NewChecker(time.Second, time.Hour, 1).Check([]Link{{"landingURL", ts.URL}})
+	assert.Len(t, results, 1)
+	assert.True(t, results[0].Broken())
+	assert.Equal(t, 0, results[0].Status)
+	assert.NotEmpty(t, results[0].Error)
+}
+
+func TestCheckPerHost(t *testing.T) {
+	var mu sync.Mutex
+	current, max := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		current--
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	var links []Link
+	for _, p := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		links = append(links, Link{"screenshot", ts.URL + p})
+	}
+	results := NewChecker(5*time.Second, time.Hour, 2).Check(links)
+	assert.Empty(t, Broken(results))
+	assert.Equal(t, 2, max)
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linkcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "links.json")
+
+	c := NewChecker(time.Second, time.Hour, 1)
+	// A missing cache is empty.
+	assert.NoError(t, c.Load(path))
+
+	c.cache["https://example.org/ok"] = entry{Status: 200, Checked: time.Now()}
+	c.cache["https://example.org/old"] = entry{Status: 404, Checked: time.Now().Add(-2 * time.Hour)}
+	assert.NoError(t, c.Save(path))
+
+	loaded := NewChecker(time.Second, time.Hour, 1)
+	assert.NoError(t, loaded.Load(path))
+	assert.Len(t, loaded.cache, 1)
+	assert.Equal(t, 200, loaded.cache["https://example.org/ok"].Status)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	assert.Error(t, loaded.Load(path))
+}
@@ -6,13 +6,10 @@ import (
 	"github.com/italia/developers-italia-backend/crawler/cmd"
 	"github.com/italia/developers-italia-backend/crawler/crawler"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 func main() {
-	log.SetLevel(log.DebugLevel)
-
 	// Read configurations.
 	viper.SetConfigName("config")
 	viper.AddConfigPath(".")
@@ -0,0 +1,179 @@
+// Package maintenance computes the maintenance status of the software from
+// the maintenance section of the publiccode.yml, and lists the maintenance
+// contracts that are expiring or expired by publisher, so that they can be
+// notified.
+package maintenance
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// The maintenance statuses.
+const (
+	// Active is the status of the software maintained internally or with a
+	// contract not expiring soon.
+	Active = "active"
+	// Expiring is the status of the software whose last contract expires
+	// within the configured days.
+	Expiring = "expiring"
+	// Expired is the status of the software whose contracts are all expired.
+	Expired = "expired"
+	// Community is the status of the software maintained by a community.
+	Community = "community"
+	// None is the status of the software with no maintenance.
+	None = "none"
+)
+
+// dateLayout is the layout of the dates in the publiccode.yml.
+const dateLayout = "2006-01-02"
+
+// Contractor is a maintenance contractor, as in the publiccode.yml.
+type Contractor struct {
+	Name  string `json:"name"`
+	Until string `json:"until"`
+}
+
+// Status is the maintenance status of a software.
+type Status struct {
+	Status string `json:"status"`
+	// Until is the end of the last maintenance contract, if any.
+	Until string `json:"until,omitempty"`
+}
+
+// Compute returns the maintenance status, at the time now, of a software
+// with maintenanceType (maintenance.type) and contractors. The contracts
+// ending within expiringDays are expiring.
+func Compute(maintenanceType string, contractors []Contractor, now time.Time, expiringDays int) Status {
+	switch strings.ToLower(strings.TrimSpace(maintenanceType)) {
+	case "internal":
+		return Status{Status: Active}
+	case "community":
+		return Status{Status: Community}
+	case "contract":
+	default:
+		return Status{Status: None}
+	}
+
+	var last time.Time
+	for _, c := range contractors {
+		until, err := time.Parse(dateLayout, c.Until)
+		if err == nil && until.After(last) {
+			last = until
+		}
+	}
+	// A contract with no valid end date can't be trusted.
+	if last.IsZero() {
+		return Status{Status: None}
+	}
+
+	return Status{Status: contractStatus(last, now, expiringDays), Until: last.Format(dateLayout)}
+}
+
+// contractStatus returns the status of a contract ending on until, which
+// is the last day of the contract.
+func contractStatus(until, now time.Time, expiringDays int) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case until.Before(today):
+		return Expired
+	case !until.After(today.AddDate(0, 0, expiringDays)):
+		return Expiring
+	default:
+		return Active
+	}
+}
+
+// Contract is a maintenance contract of a software.
+type Contract struct {
+	Software   string `json:"software"`
+	URL        string `json:"url"`
+	Slug       string `json:"slug"`
+	Contractor string `json:"contractor"`
+	Until      string `json:"until"`
+	// Status is Expiring or Expired.
+	Status string `json:"status"`
+}
+
+// Publisher is a publisher with its expiring and expired maintenance
+// contracts.
+type Publisher struct {
+	CodiceIPA string `json:"codiceIPA"`
+	Name      string `json:"name"`
+	// PEC is the certified email address of the publisher in IndicePA.
+	PEC       string     `json:"pec,omitempty"`
+	Contracts []Contract `json:"contracts"`
+}
+
+// Software is the part of a software document needed to list its contracts.
+type Software struct {
+	Slug       string `json:"slug"`
+	IPALabel   string `json:"it-riuso-codiceIPA-label"`
+	PublicCode struct {
+		Name        string `json:"name"`
+		URL         string `json:"url"`
+		Maintenance struct {
+			Type        string       `json:"type"`
+			Contractors []Contractor `json:"contractors"`
+		} `json:"maintenance"`
+		It struct {
+			Riuso struct {
+				CodiceIPA string `json:"codiceIPA"`
+			} `json:"riuso"`
+		} `json:"it"`
+	} `json:"publiccode"`
+}
+
+// Publishers returns the publishers of software whose last maintenance
+// contracts are expiring or expired at the time now, sorted by codiceIPA.
+// The software with no codiceIPA are left out, since there is no one to
+// notify.
+func Publishers(software []Software, now time.Time, expiringDays int) []Publisher {
+	byIPA := map[string]*Publisher{}
+	for _, sw := range software {
+		codiceIPA := strings.ToLower(strings.TrimSpace(sw.PublicCode.It.Riuso.CodiceIPA))
+		if codiceIPA == "" {
+			continue
+		}
+		m := sw.PublicCode.Maintenance
+		status := Compute(m.Type, m.Contractors, now, expiringDays)
+		if status.Status != Expiring && status.Status != Expired {
+			continue
+		}
+
+		p, ok := byIPA[codiceIPA]
+		if !ok {
+			p = &Publisher{CodiceIPA: codiceIPA, Name: sw.IPALabel}
+			byIPA[codiceIPA] = p
+		}
+		// The older contracts were replaced by the last ones.
+		for _, c := range m.Contractors {
+			if c.Until != status.Until {
+				continue
+			}
+			p.Contracts = append(p.Contracts, Contract{
+				Software:   sw.PublicCode.Name,
+				URL:        sw.PublicCode.URL,
+				Slug:       sw.Slug,
+				Contractor: c.Name,
+				Until:      c.Until,
+				Status:     status.Status,
+			})
+		}
+	}
+
+	publishers := make([]Publisher, 0, len(byIPA))
+	for _, p := range byIPA {
+		// The contracts expiring first come first.
+		sort.SliceStable(p.Contracts, func(i, j int) bool {
+			return p.Contracts[i].Until < p.Contracts[j].Until
+		})
+		publishers = append(publishers, *p)
+	}
+	sort.Slice(publishers, func(i, j int) bool {
+		return publishers[i].CodiceIPA < publishers[j].CodiceIPA
+	})
+
+	return publishers
+}
@@ -0,0 +1,80 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2020, 10, 20, 15, 0, 0, 0, time.UTC)
+
+func TestCompute(t *testing.T) {
+	contract := func(until ...string) []Contractor {
+		var contractors []Contractor
+		for _, u := range until {
+			contractors = append(contractors, Contractor{Name: "ACME", Until: u})
+		}
+		return contractors
+	}
+
+	tests := []struct {
+		maintenanceType string
+		contractors     []Contractor
+		expected        Status
+	}{
+		{"internal", nil, Status{Status: Active}},
+		{"Community", nil, Status{Status: Community}},
+		{"none", nil, Status{Status: None}},
+		{"", nil, Status{Status: None}},
+		{"contract", contract("2021-06-01"), Status{Active, "2021-06-01"}},
+		{"contract", contract("2020-12-20"), Status{Active, "2020-12-20"}},
+		{"contract", contract("2020-12-19"), Status{Expiring, "2020-12-19"}},
+		// The last day of the contract is still covered.
+		{"contract", contract("2020-10-20"), Status{Expiring, "2020-10-20"}},
+		{"contract", contract("2020-10-19"), Status{Expired, "2020-10-19"}},
+		// The last contract counts.
+		{"contract", contract("2021-06-01", "2019-01-01"), Status{Active, "2021-06-01"}},
+		{"contract", contract("2019-01-01", "2020-01-01"), Status{Expired, "2020-01-01"}},
+		{"contract", contract("soon"), Status{Status: None}},
+		{"contract", nil, Status{Status: None}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Compute(test.maintenanceType, test.contractors, now, 60), "%s %v", test.maintenanceType, test.contractors)
+	}
+}
+
+func TestPublishers(t *testing.T) {
+	var software []Software
+	err := json.Unmarshal([]byte(`[
+		{"slug": "a", "it-riuso-codiceIPA-label": "Comune A", "publiccode": {"name": "A", "url": "https://example.org/a",
+			"maintenance": {"type": "contract", "contractors": [{"name": "Old", "until": "2019-01-01"}, {"name": "New", "until": "2020-11-01"}]},
+			"it": {"riuso": {"codiceIPA": "C_A"}}}},
+		{"slug": "b", "it-riuso-codiceIPA-label": "Comune A", "publiccode": {"name": "B", "url": "https://example.org/b",
+			"maintenance": {"type": "contract", "contractors": [{"name": "ACME", "until": "2020-01-01"}]},
+			"it": {"riuso": {"codiceIPA": "c_a"}}}},
+		{"slug": "c", "publiccode": {"name": "C", "url": "https://example.org/c",
+			"maintenance": {"type": "contract", "contractors": [{"name": "ACME", "until": "2025-01-01"}]},
+			"it": {"riuso": {"codiceIPA": "c_b"}}}},
+		{"slug": "d", "publiccode": {"name": "D", "url": "https://example.org/d",
+			"maintenance": {"type": "internal"},
+			"it": {"riuso": {"codiceIPA": "c_b"}}}},
+		{"slug": "e", "publiccode": {"name": "E", "url": "https://example.org/e",
+			"maintenance": {"type": "contract", "contractors": [{"name": "ACME", "until": "2020-01-01"}]}}},
+		{"slug": "f", "it-riuso-codiceIPA-label": "Comune C", "publiccode": {"name": "F", "url": "https://example.org/f",
+			"maintenance": {"type": "contract", "contractors": [{"name": "ACME", "until": "2020-10-01"}]},
+			"it": {"riuso": {"codiceIPA": "c_c"}}}}
+	]`), &software)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Publisher{
+		{CodiceIPA: "c_a", Name: "Comune A", Contracts: []Contract{
+			{Software: "B", URL: "https://example.org/b", Slug: "b", Contractor: "ACME", Until: "2020-01-01", Status: Expired},
+			{Software: "A", URL: "https://example.org/a", Slug: "a", Contractor: "New", Until: "2020-11-01", Status: Expiring},
+		}},
+		{CodiceIPA: "c_c", Name: "Comune C", Contracts: []Contract{
+			{Software: "F", URL: "https://example.org/f", Slug: "f", Contractor: "ACME", Until: "2020-10-01", Status: Expired},
+		}},
+	}, Publishers(software, now, 60))
+}
@@ -0,0 +1,206 @@
+// Package maturity checks the practices of a mature project in the working
+// tree of a repository: documentation, community files, continuous
+// integration, tests, changelog and versioning.
+//
+// It complements the vitality index, which measures the activity only.
+// The checks are weighted into a single score, from 0 to 100.
+package maturity
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree"
+)
+
+// Report is the result of the checks on a repository.
+type Report struct {
+	// ReadmeIT and ReadmeEN are true if there is a README in Italian and
+	// in English.
+	ReadmeIT       bool `json:"readmeIT"`
+	ReadmeEN       bool `json:"readmeEN"`
+	Contributing   bool `json:"contributing"`
+	CodeOfConduct  bool `json:"codeOfConduct"`
+	SecurityPolicy bool `json:"securityPolicy"`
+	CI             bool `json:"ci"`
+	// CIProviders are the continuous integration services configured.
+	CIProviders []string `json:"ciProviders,omitempty"`
+	Tests       bool     `json:"tests"`
+	Changelog   bool     `json:"changelog"`
+	// SemverTags is true if the releases are tagged with semantic versions.
+	SemverTags bool `json:"semverTags"`
+	Score      int  `json:"score"`
+}
+
+// Weights are the weights of the checks in the score, by check name.
+type Weights map[string]float64
+
+// Checks are the names of the checks, as in the weights.
+var Checks = []string{
+	"readme_it",
+	"readme_en",
+	"contributing",
+	"code_of_conduct",
+	"security_policy",
+	"ci",
+	"tests",
+	"changelog",
+	"semver_tags",
+}
+
+// DefaultWeights returns the weights used if they are not configured.
+func DefaultWeights() Weights {
+	return Weights{
+		"readme_it":       2,
+		"readme_en":       1,
+		"contributing":    1,
+		"code_of_conduct": 1,
+		"security_policy": 1,
+		"ci":              2,
+		"tests":           2,
+		"changelog":       1,
+		"semver_tags":     1,
+	}
+}
+
+// ParseWeights returns the default weights overridden by the ones in
+// config, as read from config.toml. A check with weight 0 doesn't count.
+func ParseWeights(config map[string]interface{}) (Weights, error) {
+	weights := DefaultWeights()
+	for name, value := range config {
+		name = strings.ToLower(name)
+		if _, ok := weights[name]; !ok {
+			return nil, fmt.Errorf("unknown maturity check %q (valid: %s)", name, strings.Join(Checks, ", "))
+		}
+
+		var weight float64
+		switch v := value.(type) {
+		case int:
+			weight = float64(v)
+		case int64:
+			weight = float64(v)
+		case float64:
+			weight = v
+		default:
+			return nil, fmt.Errorf("invalid weight %v for maturity check %s", value, name)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("negative weight %v for maturity check %s", weight, name)
+		}
+		weights[name] = weight
+	}
+
+	return weights, nil
+}
+
+// Checker is a worktree.Visitor collecting what the checks need to know
+// about the files of the working tree in a directory.
+type Checker struct {
+	dir   string
+	tests testFinder
+}
+
+// NewChecker returns a Checker of the working tree in dir.
+func NewChecker(dir string) *Checker {
+	return &Checker{dir: dir}
+}
+
+// Check runs the checks on the working tree in dir, whose tags are tags,
+// and computes the score with weights.
+func Check(dir string, tags []string, weights Weights) (Report, error) {
+	c := NewChecker(dir)
+	if err := worktree.Walk(dir, c); err != nil {
+		return Report{}, err
+	}
+
+	return c.Check(tags, weights)
+}
+
+// Visit implements worktree.Visitor.
+func (c *Checker) Visit(path string, info os.FileInfo) error {
+	return c.tests.Visit(path, info)
+}
+
+// Check is like the Check function, with the files visited by c.
+func (c *Checker) Check(tags []string, weights Weights) (Report, error) {
+	files, err := scan(c.dir)
+	if err != nil {
+		return Report{}, err
+	}
+	files.tests = c.tests.found
+
+	r := Report{
+		Contributing:   files.has("CONTRIBUTING", "CONTRIBUTE"),
+		CodeOfConduct:  files.has("CODE_OF_CONDUCT", "CODE-OF-CONDUCT"),
+		SecurityPolicy: files.has("SECURITY"),
+		CIProviders:    files.ciProviders,
+		Tests:          files.tests,
+		Changelog:      files.has("CHANGELOG", "CHANGES", "HISTORY", "NEWS", "RELEASE-NOTES", "RELEASE_NOTES"),
+		SemverTags:     hasSemverTags(tags),
+	}
+	r.CI = len(r.CIProviders) > 0
+	r.ReadmeIT, r.ReadmeEN = files.readmeLanguages()
+	r.Score = r.score(weights)
+
+	return r, nil
+}
+
+// Missing returns the names of the failed checks.
+func (r Report) Missing() []string {
+	var missing []string
+	passed := r.passed()
+	for _, name := range Checks {
+		if !passed[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+func (r Report) passed() map[string]bool {
+	return map[string]bool{
+		"readme_it":       r.ReadmeIT,
+		"readme_en":       r.ReadmeEN,
+		"contributing":    r.Contributing,
+		"code_of_conduct": r.CodeOfConduct,
+		"security_policy": r.SecurityPolicy,
+		"ci":              r.CI,
+		"tests":           r.Tests,
+		"changelog":       r.Changelog,
+		"semver_tags":     r.SemverTags,
+	}
+}
+
+// score returns the weighted percentage of the passed checks.
+func (r Report) score(weights Weights) int {
+	var total, passed float64
+	for name, ok := range r.passed() {
+		total += weights[name]
+		if ok {
+			passed += weights[name]
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	return int(math.Round(100 * passed / total))
+}
+
+// semver matches the semantic versions, with the optional "v" prefix.
+var semver = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// hasSemverTags returns true if any of tags is a semantic version.
+func hasSemverTags(tags []string) bool {
+	for _, tag := range tags {
+		if semver.MatchString(strings.TrimSpace(tag)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package maturity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree/worktreetest"
+	"github.com/stretchr/testify/assert"
+)
+
+const readmeIT = `# Progetto
+
+Questo è il software per la gestione delle pratiche del Comune. Il software
+è stato sviluppato per le amministrazioni che vogliono una soluzione per la
+gestione dei documenti, con una interfaccia semplice e non richiede
+l'installazione di altri componenti. Per contribuire, leggere il file
+CONTRIBUTING.md, che spiega come aprire una segnalazione e come proporre delle
+modifiche al codice.`
+
+const readmeEN = `# Project
+
+This is the software for the management of the documents of the municipality.
+It's developed for the administrations that are looking for a solution with a
+simple interface, and it can be installed with no other components. To
+contribute, read CONTRIBUTING.md, which explains how to open an issue and how
+to propose changes to the code that you want to make to the project.`
+
+func TestCheck(t *testing.T) {
+	dir := worktreetest.Write(t, map[string]string{
+		"README.md":                readmeIT + "\n\n" + readmeEN,
+		".github/CONTRIBUTING.md":  "...",
+		".github/SECURITY.md":      "...",
+		".github/workflows/ci.yml": "on: push",
+		".gitlab-ci.yml":           "test:",
+		"CHANGELOG.md":             "...",
+		"src/app/handler_test.go":  "package app",
+		"node_modules/a/a.test.js": "",
+		"docs/code_of_conduct.md":  "...",
+		".woodpecker/notes.txt":    "",
+		"vendor/tests/placeholder": "",
+	})
+	defer os.RemoveAll(dir)
+
+	r, err := Check(dir, []string{"latest", "v1.2.0"}, DefaultWeights())
+	assert.NoError(t, err)
+	assert.Equal(t, Report{
+		ReadmeIT:       true,
+		ReadmeEN:       true,
+		Contributing:   true,
+		CodeOfConduct:  true,
+		SecurityPolicy: true,
+		CI:             true,
+		CIProviders:    []string{"github-actions", "gitlab-ci"},
+		Tests:          true,
+		Changelog:      true,
+		SemverTags:     true,
+		Score:          100,
+	}, r)
+	assert.Empty(t, r.Missing())
+}
+
+func TestCheckMissing(t *testing.T) {
+	dir := worktreetest.Write(t, map[string]string{
+		"README.it.md":             "# Progetto",
+		"README.md":                readmeEN,
+		"node_modules/a/a.test.js": "",
+	})
+	defer os.RemoveAll(dir)
+
+	r, err := Check(dir, []string{"1.0", "release-2020"}, DefaultWeights())
+	assert.NoError(t, err)
+	assert.True(t, r.ReadmeIT)
+	assert.True(t, r.ReadmeEN)
+	assert.False(t, r.Tests)
+	assert.False(t, r.SemverTags)
+	assert.Equal(t, []string{"contributing", "code_of_conduct", "security_policy", "ci", "tests", "changelog", "semver_tags"}, r.Missing())
+	// 3 out of 12.
+	assert.Equal(t, 25, r.Score)
+
+	// Only the READMEs count.
+	weights, err := ParseWeights(map[string]interface{}{
+		"contributing": 0, "code_of_conduct": int64(0), "security_policy": 0.0,
+		"ci": 0, "tests": 0, "changelog": 0, "SEMVER_TAGS": 0,
+	})
+	assert.NoError(t, err)
+	r, err = Check(dir, nil, weights)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, r.Score)
+
+	_, err = Check(filepath.Join(dir, "missing"), nil, weights)
+	assert.Error(t, err)
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultWeights(), weights)
+
+	weights, err = ParseWeights(map[string]interface{}{"tests": int64(5)})
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, weights["tests"])
+	assert.Equal(t, 2.0, weights["ci"])
+
+	_, err = ParseWeights(map[string]interface{}{"stars": 1})
+	assert.Error(t, err)
+	_, err = ParseWeights(map[string]interface{}{"ci": "high"})
+	assert.Error(t, err)
+	_, err = ParseWeights(map[string]interface{}{"ci": -1})
+	assert.Error(t, err)
+}
+
+func TestTextLanguages(t *testing.T) {
+	it, en := textLanguages(readmeIT)
+	assert.True(t, it)
+	assert.False(t, en)
+
+	it, en = textLanguages(readmeEN)
+	assert.False(t, it)
+	assert.True(t, en)
+
+	// A few English words in an Italian README.
+	it, en = textLanguages(readmeIT + " Powered by the open source community.")
+	assert.True(t, it)
+	assert.False(t, en)
+
+	it, en = textLanguages(strings.Repeat("# Title\n", 10))
+	assert.False(t, it)
+	assert.False(t, en)
+}
@@ -0,0 +1,252 @@
+package maturity
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree"
+)
+
+// tree is what the checks need to know about the working tree.
+type tree struct {
+	// docs are the names of the files in the root, .github, .gitlab and
+	// docs directories, in uppercase.
+	docs []string
+	// readmes are the paths of the READMEs in the root.
+	readmes     []string
+	ciProviders []string
+	tests       bool
+}
+
+// docDirs are the directories with the community files.
+var docDirs = []string{".", ".github", ".gitlab", "docs"}
+
+// ciConfigs are the configuration files of the continuous integration
+// services. The directories must have YAML files.
+var ciConfigs = []struct {
+	provider string
+	path     string
+}{
+	{"github-actions", ".github/workflows"},
+	{"gitlab-ci", ".gitlab-ci.yml"},
+	{"travis", ".travis.yml"},
+	{"circleci", ".circleci/config.yml"},
+	{"jenkins", "Jenkinsfile"},
+	{"azure-pipelines", "azure-pipelines.yml"},
+	{"bitbucket-pipelines", "bitbucket-pipelines.yml"},
+	{"drone", ".drone.yml"},
+	{"woodpecker", ".woodpecker.yml"},
+	{"woodpecker", ".woodpecker"},
+	{"appveyor", "appveyor.yml"},
+	{"appveyor", ".appveyor.yml"},
+}
+
+// testDirs and testFiles match the directories and the files with tests.
+var (
+	testDirs  = []string{"test", "tests", "__tests__", "spec", "specs", "e2e"}
+	testFiles = regexp.MustCompile(`(_test\.go|^test_.*\.py|_test\.py|\.(test|spec)\.[jt]sx?|Tests?\.(java|kt|php|cs)|_spec\.rb)$`)
+)
+
+// maxFiles is the maximum number of files looked at searching the tests.
+const maxFiles = 20000
+
+// scan returns what's in the working tree in dir, but the tests.
+func scan(dir string) (tree, error) {
+	var t tree
+
+	for _, d := range docDirs {
+		files, err := ioutil.ReadDir(filepath.Join(dir, d))
+		if err != nil {
+			if os.IsNotExist(err) && d != "." {
+				continue
+			}
+			return tree{}, err
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			name := strings.ToUpper(f.Name())
+			t.docs = append(t.docs, name)
+			if d == "." && strings.HasPrefix(name, "README") {
+				t.readmes = append(t.readmes, filepath.Join(dir, f.Name()))
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, ci := range ciConfigs {
+		if !seen[ci.provider] && hasConfig(filepath.Join(dir, ci.path)) {
+			seen[ci.provider] = true
+			t.ciProviders = append(t.ciProviders, ci.provider)
+		}
+	}
+
+	return t, nil
+}
+
+// has returns true if there is a community file starting with one of prefixes.
+func (t tree) has(prefixes ...string) bool {
+	for _, name := range t.docs {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hasConfig returns true if the file at path exists, or if it's a
+// directory with YAML files.
+func hasConfig(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() {
+		return true
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if !f.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// testFinder is a worktree.Visitor looking for a test directory or test
+// files, in the first maxFiles files.
+type testFinder struct {
+	found   bool
+	visited int
+}
+
+// Visit implements worktree.Visitor.
+func (f *testFinder) Visit(path string, info os.FileInfo) error {
+	f.visited++
+	if f.visited > maxFiles {
+		return worktree.Done
+	}
+
+	name := info.Name()
+	if info.IsDir() {
+		if contains(testDirs, strings.ToLower(name)) {
+			f.found = true
+			return worktree.Done
+		}
+		return nil
+	}
+	if testFiles.MatchString(name) {
+		f.found = true
+		return worktree.Done
+	}
+
+	return nil
+}
+
+// readmeLanguages returns whether there is a README in Italian and one in
+// English, from their names (README.it.md) or their content.
+func (t tree) readmeLanguages() (it, en bool) {
+	for _, path := range t.readmes {
+		switch readmeNameLanguage(filepath.Base(path)) {
+		case "it":
+			it = true
+			continue
+		case "en":
+			en = true
+			continue
+		}
+
+		text, err := readStart(path)
+		if err != nil {
+			continue
+		}
+		textIT, textEN := textLanguages(text)
+		it = it || textIT
+		en = en || textEN
+	}
+
+	return it, en
+}
+
+var readmeName = regexp.MustCompile(`(?i)^readme[._-](it|ita|italian|italiano|en|eng|english)([._-]|$)`)
+
+// readmeNameLanguage returns the language in the name of a README, if any.
+func readmeNameLanguage(name string) string {
+	m := readmeName.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+
+	return strings.ToLower(m[1])[:2]
+}
+
+// readStart returns the first 64 KiB of the file at path.
+func readStart(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, 64*1024))
+
+	return string(data), err
+}
+
+// stopwords are common words of Italian and English, not in the other language.
+var stopwords = map[string][]string{
+	"it": {"il", "lo", "gli", "di", "che", "per", "con", "una", "del", "della", "dei", "delle",
+		"sono", "questo", "questa", "non", "nel", "nella", "alla", "anche", "come", "più", "viene", "essere"},
+	"en": {"the", "and", "of", "to", "is", "for", "this", "with", "that", "are", "you", "be",
+		"on", "from", "can", "by", "how", "use", "your", "which"},
+}
+
+// minStopwords is the number of stopwords a text needs to be in a language.
+const minStopwords = 10
+
+// textLanguages returns whether text is, at least in part, in Italian and
+// in English. Bilingual READMEs are both.
+func textLanguages(text string) (it, en bool) {
+	counts := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		for lang, list := range stopwords {
+			if contains(list, w) {
+				counts[lang]++
+			}
+		}
+	}
+
+	// A language is there if it's not just a few words in the other one.
+	it = counts["it"] >= minStopwords && counts["it"]*4 >= counts["en"]
+	en = counts["en"] >= minStopwords && counts["en"]*4 >= counts["it"]
+
+	return it, en
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
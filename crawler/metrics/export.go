@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/viper"
+)
+
+// Export exports the final values of the metrics of a batch run of command,
+// since the metrics server goes away with the process:
+// they are pushed to the Pushgateway at METRICS_PUSHGATEWAY_URL and written to
+// METRICS_TEXTFILE_DIR/publiccode_crawler_<command>.prom for the node exporter
+// textfile collector, if they are set.
+//
+// If the run didn't complete a crawl, the time of the last successful crawl
+// is left out of the push, so the one pushed by a previous run is kept.
+func Export(command string) error {
+	if url := viper.GetString("METRICS_PUSHGATEWAY_URL"); url != "" {
+		pusher := push.New(url, namespace).Grouping("command", command)
+		var err error
+		if crawled() {
+			err = pusher.Gatherer(prometheus.GathererFunc(gather)).Push()
+		} else {
+			// Add replaces only the metrics pushed, unlike Push.
+			err = pusher.Gatherer(prometheus.GathererFunc(gatherUncrawled)).Add()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if dir := viper.GetString("METRICS_TEXTFILE_DIR"); dir != "" {
+		err := WriteTextfile(filepath.Join(dir, namespace+"_"+command+".prom"), command)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteTextfile writes the metrics to filename in the Prometheus text format,
+// with the command label. The file is replaced atomically, so that the
+// collector never reads a partial file.
+func WriteTextfile(filename, command string) error {
+	families, err := gather()
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // nolint: errcheck
+
+	// The files of all the commands are collected together,
+	// so the label keeps the metrics of different commands apart.
+	label := &dto.LabelPair{Name: proto.String("command"), Value: proto.String(command)}
+	for _, family := range families {
+		for _, m := range family.Metric {
+			m.Label = append(m.Label, label)
+		}
+		if _, err := expfmt.MetricFamilyToText(f, family); err != nil {
+			f.Close() // nolint: errcheck
+			return err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// gather returns the metrics of the crawler namespace, without the Go and
+// process metrics: the node exporter has its own and rejects the textfiles
+// that contain them.
+func gather() ([]*dto.MetricFamily, error) {
+	families, err := Registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var crawler []*dto.MetricFamily
+	for _, family := range families {
+		if strings.HasPrefix(family.GetName(), namespace+"_") {
+			crawler = append(crawler, family)
+		}
+	}
+
+	return crawler, nil
+}
+
+// lastSuccessfulCrawlName is the name of the LastSuccessfulCrawl gauge.
+const lastSuccessfulCrawlName = namespace + "_last_successful_crawl_timestamp_seconds"
+
+// crawled returns true if a crawl completed successfully in this run.
+func crawled() bool {
+	var m dto.Metric
+	if err := LastSuccessfulCrawl.Write(&m); err != nil {
+		return false
+	}
+
+	return m.GetGauge().GetValue() != 0
+}
+
+// gatherUncrawled is like gather, without the time of the last successful
+// crawl, which is unset.
+func gatherUncrawled() ([]*dto.MetricFamily, error) {
+	families, err := gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var uncrawled []*dto.MetricFamily
+	for _, family := range families {
+		if family.GetName() != lastSuccessfulCrawlName {
+			uncrawled = append(uncrawled, family)
+		}
+	}
+
+	return uncrawled, nil
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A stand-in for the Pushgateway.
+	var method, pushPath string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, pushPath = r.Method, r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	viper.Set("METRICS_PUSHGATEWAY_URL", server.URL)
+	viper.Set("METRICS_TEXTFILE_DIR", dir)
+	defer viper.Set("METRICS_PUSHGATEWAY_URL", "")
+	defer viper.Set("METRICS_TEXTFILE_DIR", "")
+
+	LastSuccessfulCrawl.Set(1600000000)
+	assert.Nil(t, Export("crawl"))
+
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/publiccode_crawler/command/crawl", pushPath)
+	assert.NotEmpty(t, body)
+
+	// The textfile can be parsed back and has the command label.
+	f, err := os.Open(filepath.Join(dir, "publiccode_crawler_crawl.prom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(f)
+	assert.Nil(t, err)
+
+	family := families["publiccode_crawler_last_successful_crawl_timestamp_seconds"]
+	if assert.NotNil(t, family) {
+		m := family.GetMetric()[0]
+		assert.Equal(t, 1600000000.0, m.GetGauge().GetValue())
+		assert.Equal(t, "command", m.GetLabel()[0].GetName())
+		assert.Equal(t, "crawl", m.GetLabel()[0].GetValue())
+	}
+
+	// Only the metrics of the crawler, the node exporter rejects the
+	// go_ and process_ metrics it exports itself.
+	for name := range families {
+		assert.True(t, strings.HasPrefix(name, "publiccode_crawler_"), name)
+	}
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.False(t, strings.HasSuffix(files[0].Name(), ".tmp"))
+}
+
+func TestExportFailed(t *testing.T) {
+	var method string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	viper.Set("METRICS_PUSHGATEWAY_URL", server.URL)
+	defer viper.Set("METRICS_PUSHGATEWAY_URL", "")
+
+	// Without a successful crawl, the time of the previous one is kept in
+	// the Pushgateway.
+	LastSuccessfulCrawl.Set(0)
+	assert.Nil(t, Export("crawl"))
+
+	assert.Equal(t, http.MethodPost, method)
+	assert.NotEmpty(t, body)
+	assert.NotContains(t, string(body), "last_successful_crawl")
+}
+
+func TestExportDisabled(t *testing.T) {
+	assert.Nil(t, Export("crawl"))
+}
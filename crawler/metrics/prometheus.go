@@ -2,72 +2,143 @@ package metrics
 
 import (
 	"net/http"
-	"regexp"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
-// Map of all the registered Counters.
-var registeredCounters = make(map[string]prometheus.Counter)
-
-// Valid regex for prometheus model name.
-// (Prometheus model reference: https://github.com/prometheus/common)
-const validPrometheusName = "[^a-zA-Z_][^a-zA-Z0-9_]*"
-
-// GetCounter return the prometheus counter of given name.
-func GetCounter(name, namespace string) prometheus.Counter {
-	// Validate and fix name (replace invalid chars with underscore "_").
-	name = validateAndFix(name)
-	if registeredCounters[name] == nil {
-		log.Errorf("Error in metrics GetCounter: %s does not exist", name)
-		// If registeredCounters[name] does not exists a new counter is created and returned.
-		RegisterPrometheusCounter(name, "Autogenerated counter "+name, namespace)
-		log.Warningf("Autogenerated: %s that does not exist", name)
-	}
+const namespace = "publiccode_crawler"
 
-	return registeredCounters[name]
-}
+// Outcomes of the processing of a repository.
+const (
+	OutcomeNotFound    = "not_found"
+	OutcomeInvalid     = "invalid"
+	OutcomeIPAMismatch = "ipa_mismatch"
+	OutcomeValid       = "valid"
+	OutcomeIndexed     = "indexed"
+	OutcomeError       = "error"
+)
+
+// Registry is the registry of the crawler metrics.
+var Registry = prometheus.NewRegistry()
 
-// RegisterPrometheusCounter register a new Counter of given name with help text.
-func RegisterPrometheusCounter(name, helpText, namespace string) {
-	// Validate and fix name (replace invalid chars with underscore "_").
-	name = validateAndFix(name)
+var (
+	// Repositories counts the processed repositories by outcome,
+	// code hosting domain and publisher (iPA code).
+	Repositories = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repositories_total",
+		Help:      "Number of repositories processed, by outcome.",
+	}, []string{"outcome", "domain", "publisher"})
 
-	// Add counter in the map.
-	registeredCounters[name] = prometheus.NewCounter(prometheus.CounterOpts{
-		Name:      name,
-		Namespace: "publiccode_crawler_" + namespace,
-		Help:      helpText,
+	// HTTPRequestDuration is the duration of the HTTP requests to the code
+	// hosting APIs, by host and status code ("error" if the request failed).
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests, by host and status code.",
+	}, []string{"host", "status"})
+
+	// CloneDuration is the duration of the git clone or fetch of the repositories.
+	CloneDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clone_duration_seconds",
+		Help:      "Duration of the git clone or fetch of a repository.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
 	})
-	// Register counter in Prometheus service.
-	err := prometheus.Register(registeredCounters[name])
-	if err != nil {
-		log.Warningf("Error in metrics RegisterPrometheusCounter: %v", err)
-	}
-}
 
-// StartPrometheusMetricsServer starts a metric server handling
-// "/metrics" on "localhost:8081" exposing the registered metrics.
-func StartPrometheusMetricsServer() {
-	http.Handle("/metrics", promhttp.Handler())
+	// CloneSize is the size of the git objects of the cloned repositories.
+	CloneSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clone_size_bytes",
+		Help:      "Size of the git objects of a cloned repository.",
+		Buckets:   prometheus.ExponentialBuckets(1<<20, 4, 8),
+	})
 
-	err := http.ListenAndServe(":8081", nil)
-	if err != nil {
-		log.Warningf("monitoring endpoint non available: %v: ", err)
-	}
+	// VitalityDuration is the duration of the vitality index computation.
+	VitalityDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "vitality_duration_seconds",
+		Help:      "Duration of the computation of the vitality index of a repository.",
+	})
+
+	// ElasticsearchDuration is the duration of the Elasticsearch requests, by operation.
+	ElasticsearchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "elasticsearch_request_duration_seconds",
+		Help:      "Duration of the Elasticsearch requests, by operation.",
+	}, []string{"operation"})
+
+	// FilesSaved counts the publiccode.yml versions saved to CRAWLER_DATADIR.
+	FilesSaved = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "files_saved_total",
+		Help:      "Number of publiccode.yml files saved.",
+	})
+
+	// LastSuccessfulCrawl is the time of the end of the last successful crawl.
+	LastSuccessfulCrawl = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_crawl_timestamp_seconds",
+		Help:      "Unix time of the end of the last successful crawl.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		Repositories,
+		HTTPRequestDuration,
+		CloneDuration,
+		CloneSize,
+		VitalityDuration,
+		ElasticsearchDuration,
+		FilesSaved,
+		LastSuccessfulCrawl,
+	)
 }
 
-// Validate and fix name (replace invalid chars with underscore "_").
-func validateAndFix(name string) string {
-	reg, err := regexp.Compile(validPrometheusName)
-	if err != nil {
-		log.Warningf("Error in metrics regex RegisterPrometheusCounter: %v", err)
+// ObserveHTTPRequest records an HTTP request to host started at start,
+// with the status code (negative if the request failed).
+func ObserveHTTPRequest(host string, code int, start time.Time) {
+	status := "error"
+	if code > 0 {
+		status = strconv.Itoa(code)
 	}
+	HTTPRequestDuration.WithLabelValues(host, status).Observe(time.Since(start).Seconds())
+}
 
-	// Replace invalid characters with a permitted one (undeerscore "_").
-	name = reg.ReplaceAllString(name, "_")
+// ObserveElasticsearch records an Elasticsearch operation started at start.
+func ObserveElasticsearch(operation string, start time.Time) {
+	ElasticsearchDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
 
-	return name
+var serverOnce sync.Once
+
+// StartPrometheusMetricsServer starts a metric server handling
+// "/metrics" on METRICS_ADDR (":8081" by default) exposing the registered metrics.
+// The server is started once, subsequent calls do nothing.
+func StartPrometheusMetricsServer() {
+	serverOnce.Do(func() {
+		addr := ":8081"
+		if viper.IsSet("METRICS_ADDR") {
+			addr = viper.GetString("METRICS_ADDR")
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+		go func() {
+			err := http.ListenAndServe(addr, mux)
+			if err != nil {
+				log.Warningf("monitoring endpoint non available: %v: ", err)
+			}
+		}()
+	})
 }
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveHTTPRequest(t *testing.T) {
+	ObserveHTTPRequest("example.org", 200, time.Now())
+	ObserveHTTPRequest("example.org", -1, time.Now())
+	ObserveHTTPRequest("example.org", -1, time.Now())
+
+	assert.Equal(t, 2, testutil.CollectAndCount(HTTPRequestDuration))
+
+	families, err := Registry.Gather()
+	assert.Nil(t, err)
+	for _, family := range families {
+		if family.GetName() != "publiccode_crawler_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			status := ""
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "status" {
+					status = label.GetValue()
+				}
+			}
+			switch status {
+			case "200":
+				assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+			case "error":
+				assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+			default:
+				t.Errorf("unexpected status %q", status)
+			}
+		}
+		return
+	}
+	t.Error("http_request_duration_seconds not registered")
+}
+
+func TestRepositories(t *testing.T) {
+	Repositories.WithLabelValues(OutcomeIndexed, "github.com", "c_a001").Inc()
+	Repositories.WithLabelValues(OutcomeInvalid, "github.com", "c_a001").Inc()
+	Repositories.WithLabelValues(OutcomeIndexed, "github.com", "c_a001").Inc()
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(Repositories.WithLabelValues(OutcomeIndexed, "github.com", "c_a001")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(Repositories.WithLabelValues(OutcomeInvalid, "github.com", "c_a001")))
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// Report is the outcome of the last crawl of a repository.
+type Report struct {
+	// CodiceIPA is the administration to notify.
+	CodiceIPA string    `json:"codiceIPA"`
+	Time      time.Time `json:"time"`
+	Issues    []Issue   `json:"issues,omitempty"`
+}
+
+// Reports are the reports of the crawls, by repository URL, kept across
+// runs so that a digest covers all the repositories of an administration.
+type Reports struct {
+	Repositories map[string]Report `json:"repositories"`
+}
+
+// LoadReports reads the reports from the file at path. A missing file is
+// an empty set of reports.
+func LoadReports(path string) (*Reports, error) {
+	r := &Reports{Repositories: map[string]Report{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("invalid reports %s: %v", path, err)
+	}
+	if r.Repositories == nil {
+		r.Repositories = map[string]Report{}
+	}
+
+	return r, nil
+}
+
+// Save writes the reports to the file at path.
+func (r *Reports) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Prune drops the reports older than maxAge at the time now, of
+// repositories no longer crawled.
+func (r *Reports) Prune(now time.Time, maxAge time.Duration) {
+	for url, report := range r.Repositories {
+		if now.Sub(report.Time) > maxAge {
+			delete(r.Repositories, url)
+		}
+	}
+}
+
+// Issues returns the issues of the reports by codiceIPA, lowercase.
+func (r *Reports) Issues() map[string][]Issue {
+	issues := map[string][]Issue{}
+	for _, report := range r.Repositories {
+		if report.CodiceIPA == "" || len(report.Issues) == 0 {
+			continue
+		}
+		codiceIPA := strings.ToLower(report.CodiceIPA)
+		issues[codiceIPA] = append(issues[codiceIPA], report.Issues...)
+	}
+
+	return issues
+}
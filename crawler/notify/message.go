@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// subject is the subject of the digests, in Italian and English.
+const subject = "Developers Italia: segnalazioni sul software pubblicato / issues with the published software"
+
+// messages are the templates of the digests, by language, in the order
+// they appear in the body.
+var messages = []*template.Template{
+	template.Must(template.New("it").Funcs(funcs).Parse(`Gentile {{ name . }},
+
+durante l'analisi del software pubblicato su Developers Italia
+(https://developers.italia.it) abbiamo riscontrato i seguenti problemi:
+{{ range $url, $issues := byURL .Issues }}
+{{ $url }}
+{{- range $issues }}
+  - {{ if eq .Kind "invalid_publiccode" }}il file publiccode.yml non è valido: {{ .Detail }}
+    {{- else if eq .Kind "codiceipa_mismatch" }}il codiceIPA nel file publiccode.yml non corrisponde all'amministrazione: {{ .Detail }}
+      {{- with .Suggestions }} (forse: {{ join . ", " }}){{ end }}
+    {{- else if eq .Kind "maintenance_expired" }}il contratto di manutenzione è scaduto il {{ .Detail }}
+    {{- else if eq .Kind "broken_link" }}link non raggiungibile: {{ .Detail }}
+    {{- else }}{{ .Detail }}{{ end }}
+{{- end }}
+{{ end }}
+Vi invitiamo ad aggiornare i file publiccode.yml dei repository indicati.
+La documentazione è disponibile su
+https://docs.italia.it/italia/developers-italia/publiccodeyml/
+
+Questo messaggio è generato automaticamente, non rispondere.
+`)),
+	template.Must(template.New("en").Funcs(funcs).Parse(`Dear {{ name . }},
+
+while analyzing the software published on Developers Italia
+(https://developers.italia.it) we found the following issues:
+{{ range $url, $issues := byURL .Issues }}
+{{ $url }}
+{{- range $issues }}
+  - {{ if eq .Kind "invalid_publiccode" }}the publiccode.yml file is not valid: {{ .Detail }}
+    {{- else if eq .Kind "codiceipa_mismatch" }}the codiceIPA in the publiccode.yml file doesn't match the administration: {{ .Detail }}
+      {{- with .Suggestions }} (maybe: {{ join . ", " }}){{ end }}
+    {{- else if eq .Kind "maintenance_expired" }}the maintenance contract expired on {{ .Detail }}
+    {{- else if eq .Kind "broken_link" }}broken link: {{ .Detail }}
+    {{- else }}{{ .Detail }}{{ end }}
+{{- end }}
+{{ end }}
+Please update the publiccode.yml files of these repositories. The
+documentation is available at
+https://docs.italia.it/italia/developers-italia/publiccodeyml-en/
+
+This message is automatically generated, please do not reply.
+`)),
+}
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	"name": func(d Digest) string {
+		if d.Name != "" {
+			return d.Name
+		}
+		return d.CodiceIPA
+	},
+	// byURL groups the issues by repository. Templates range over maps
+	// in key order.
+	"byURL": func(issues []Issue) map[string][]Issue {
+		m := map[string][]Issue{}
+		for _, issue := range issues {
+			m[issue.URL] = append(m[issue.URL], issue)
+		}
+		return m
+	},
+}
+
+// Render returns the subject and the body of the digest, in Italian and
+// English.
+func Render(d Digest) (string, []byte, error) {
+	var body bytes.Buffer
+	for i, t := range messages {
+		if i > 0 {
+			body.WriteString("\n----------------------------------------\n\n")
+		}
+		if err := t.Execute(&body, d); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return subject, body.Bytes(), nil
+}
@@ -0,0 +1,261 @@
+// Package notify sends to the administrations, by email to their PEC
+// addresses, a periodic digest of the issues found in the software they
+// publish: invalid publiccode.yml files, codiceIPA mismatches, expired
+// maintenance contracts and broken links.
+//
+// Every recipient gets at most a digest in a given interval, and an issue
+// already notified is not notified again until some time has passed. The
+// notifications sent are recorded in a log, kept across runs.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The kinds of issues.
+const (
+	InvalidPubliccode  = "invalid_publiccode"
+	CodiceIPAMismatch  = "codiceipa_mismatch"
+	MaintenanceExpired = "maintenance_expired"
+	BrokenLink         = "broken_link"
+)
+
+// Issue is an issue of a software.
+type Issue struct {
+	Kind string `json:"kind"`
+	// URL is the URL of the repository.
+	URL string `json:"url"`
+	// Detail is the error, the broken link or the end of the contract.
+	Detail string `json:"detail,omitempty"`
+	// Suggestions are the codiceIPA likely right for a codiceIPA mismatch.
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// key identifies the issue in the log. An invalid publiccode.yml is the same
+// issue even if its errors change.
+func (i Issue) key() string {
+	if i.Kind == InvalidPubliccode {
+		return i.Kind + " " + i.URL
+	}
+
+	return i.Kind + " " + i.URL + " " + i.Detail
+}
+
+// Same returns true if i and other are the same issue.
+func (i Issue) Same(other Issue) bool {
+	return i.key() == other.key()
+}
+
+// Digest is the notification of the issues to an administration.
+type Digest struct {
+	CodiceIPA string
+	Name      string
+	// PEC is the address of the administration in IndicePA.
+	PEC    string
+	Issues []Issue
+}
+
+// Sender sends an email.
+type Sender interface {
+	Send(to, subject string, body []byte) error
+}
+
+// Recipient is what the log knows about a recipient.
+type Recipient struct {
+	LastSent time.Time `json:"lastSent"`
+	// Issues are the times the issues were notified, by key.
+	Issues map[string]time.Time `json:"issues"`
+}
+
+// Log is the log of the notifications sent, by recipient address.
+type Log struct {
+	Recipients map[string]*Recipient `json:"recipients"`
+}
+
+// LoadLog reads the log from the file at path. A missing file is an empty log.
+func LoadLog(path string) (*Log, error) {
+	l := &Log{Recipients: map[string]*Recipient{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("invalid notification log %s: %v", path, err)
+	}
+	if l.Recipients == nil {
+		l.Recipients = map[string]*Recipient{}
+	}
+
+	return l, nil
+}
+
+// Save writes the log to the file at path.
+func (l *Log) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Notifier sends the digests.
+type Notifier struct {
+	Sender Sender
+	Log    *Log
+	// Interval is the minimum time between two digests to a recipient.
+	Interval time.Duration
+	// Repeat is the time after which an issue is notified again.
+	Repeat time.Duration
+	// Delay is the time between two emails, not to flood the server.
+	Delay time.Duration
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewNotifier returns a Notifier sending with sender and recording the
+// notifications in l.
+func NewNotifier(sender Sender, l *Log, interval, repeat, delay time.Duration) *Notifier {
+	return &Notifier{
+		Sender:   sender,
+		Log:      l,
+		Interval: interval,
+		Repeat:   repeat,
+		Delay:    delay,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// Pending returns the issues of d that must be notified, leaving out the
+// ones notified less than Repeat ago. It's nil if the recipient got a
+// digest less than Interval ago.
+func (n *Notifier) Pending(d Digest) []Issue {
+	now := n.now()
+	r := n.Log.Recipients[strings.ToLower(d.PEC)]
+	if r == nil {
+		return d.Issues
+	}
+	if now.Sub(r.LastSent) < n.Interval {
+		return nil
+	}
+
+	var pending []Issue
+	for _, issue := range d.Issues {
+		if sent, ok := r.Issues[issue.key()]; ok && now.Sub(sent) < n.Repeat {
+			continue
+		}
+		pending = append(pending, issue)
+	}
+
+	return pending
+}
+
+// Send sends the digests with pending issues and records them in the log.
+// The digests with no PEC address are skipped. It returns the number of
+// emails sent, stopping at the first error.
+func (n *Notifier) Send(digests []Digest) (int, error) {
+	sent := 0
+	for _, d := range digests {
+		logger := log.WithFields(log.Fields{"codiceIPA": d.CodiceIPA, "pec": d.PEC})
+		if d.PEC == "" {
+			logger.Warn("no PEC address, not notified")
+			continue
+		}
+
+		issues := n.Pending(d)
+		if len(issues) == 0 {
+			logger.Debug("nothing new to notify")
+			continue
+		}
+		d.Issues = issues
+
+		subject, body, err := Render(d)
+		if err != nil {
+			return sent, err
+		}
+		if sent > 0 && n.Delay > 0 {
+			n.sleep(n.Delay)
+		}
+		if err := n.Sender.Send(d.PEC, subject, body); err != nil {
+			return sent, fmt.Errorf("cannot notify %s: %v", d.PEC, err)
+		}
+		sent++
+		n.record(d)
+		logger.Infof("notified %d issues", len(issues))
+	}
+
+	return sent, nil
+}
+
+// record records the issues of d as notified.
+func (n *Notifier) record(d Digest) {
+	now := n.now()
+	key := strings.ToLower(d.PEC)
+	r := n.Log.Recipients[key]
+	if r == nil {
+		r = &Recipient{Issues: map[string]time.Time{}}
+		n.Log.Recipients[key] = r
+	}
+	r.LastSent = now
+
+	// Forget the issues that would be notified again anyway.
+	for k, sent := range r.Issues {
+		if now.Sub(sent) >= n.Repeat {
+			delete(r.Issues, k)
+		}
+	}
+	for _, issue := range d.Issues {
+		r.Issues[issue.key()] = now
+	}
+}
+
+// Digests groups the issues by codiceIPA, in digests sorted by codiceIPA.
+// The names and the PEC addresses of the administrations are left empty.
+func Digests(issues map[string][]Issue) []Digest {
+	var digests []Digest
+	for codiceIPA, list := range issues {
+		if codiceIPA == "" || len(list) == 0 {
+			continue
+		}
+		list = append([]Issue(nil), list...)
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].URL != list[j].URL {
+				return list[i].URL < list[j].URL
+			}
+			return kindOrder[list[i].Kind] < kindOrder[list[j].Kind]
+		})
+		digests = append(digests, Digest{CodiceIPA: codiceIPA, Issues: list})
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		return digests[i].CodiceIPA < digests[j].CodiceIPA
+	})
+
+	return digests
+}
+
+// kindOrder is the order of the issues of a repository, most severe first.
+var kindOrder = map[string]int{
+	InvalidPubliccode:  0,
+	CodiceIPAMismatch:  1,
+	MaintenanceExpired: 2,
+	BrokenLink:         3,
+}
@@ -0,0 +1,275 @@
+package notify
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// mail is an email received by smtpServer.
+type mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpServer is a local SMTP stand-in, speaking just enough of the protocol
+// for net/smtp.
+type smtpServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []mail
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &smtpServer{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpServer) Close() {
+	s.listener.Close()
+}
+
+func (s *smtpServer) Mails() []mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]mail(nil), s.mails...)
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	var m mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			m = mail{From: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			m.To = append(m.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			m.Data = data.String()
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+var digest = Digest{
+	CodiceIPA: "c_a",
+	Name:      "Comune A",
+	PEC:       "protocollo@pec.comune.a.it",
+	Issues: []Issue{
+		{Kind: InvalidPubliccode, URL: "https://example.org/a", Detail: "name: missing mandatory key"},
+		{Kind: MaintenanceExpired, URL: "https://example.org/b", Detail: "2020-01-01"},
+		{Kind: BrokenLink, URL: "https://example.org/b", Detail: "https://example.org/logo.png (logo)"},
+	},
+}
+
+func TestRender(t *testing.T) {
+	subject, body, err := Render(digest)
+	assert.NoError(t, err)
+	assert.Contains(t, subject, "Developers Italia")
+
+	text := string(body)
+	assert.Contains(t, text, "Gentile Comune A,")
+	assert.Contains(t, text, "Dear Comune A,")
+	assert.Contains(t, text, "https://example.org/a\n  - il file publiccode.yml non è valido: name: missing mandatory key\n")
+	assert.Contains(t, text, "https://example.org/b\n  - il contratto di manutenzione è scaduto il 2020-01-01\n  - link non raggiungibile: https://example.org/logo.png (logo)\n")
+	assert.Contains(t, text, "  - the maintenance contract expired on 2020-01-01\n  - broken link: https://example.org/logo.png (logo)\n")
+	// The Italian message comes first.
+	assert.True(t, strings.Index(text, "Gentile") < strings.Index(text, "Dear"))
+
+	_, body, err = Render(Digest{CodiceIPA: "c_a", Issues: []Issue{
+		{Kind: CodiceIPAMismatch, URL: "https://example.org/c", Detail: "c_x", Suggestions: []string{"c_a", "c_b"}},
+	}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "  - il codiceIPA nel file publiccode.yml non corrisponde all'amministrazione: c_x (forse: c_a, c_b)\n")
+	assert.Contains(t, string(body), "  - the codiceIPA in the publiccode.yml file doesn't match the administration: c_x (maybe: c_a, c_b)\n")
+}
+
+func TestDigests(t *testing.T) {
+	digests := Digests(map[string][]Issue{
+		"c_b": {{Kind: BrokenLink, URL: "https://example.org/b"}, {Kind: InvalidPubliccode, URL: "https://example.org/b"}},
+		"c_a": {{Kind: BrokenLink, URL: "https://example.org/a"}},
+		"c_c": nil,
+		"":    {{Kind: BrokenLink, URL: "https://example.org/c"}},
+	})
+
+	assert.Equal(t, []Digest{
+		{CodiceIPA: "c_a", Issues: []Issue{{Kind: BrokenLink, URL: "https://example.org/a"}}},
+		{CodiceIPA: "c_b", Issues: []Issue{{Kind: InvalidPubliccode, URL: "https://example.org/b"}, {Kind: BrokenLink, URL: "https://example.org/b"}}},
+	}, digests)
+}
+
+func TestNotifierSend(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	server := newSMTPServer(t)
+	defer server.Close()
+
+	now := time.Date(2020, 10, 20, 15, 0, 0, 0, time.UTC)
+	sender := NewSMTPSender(server.Addr(), "noreply@developers.italia.it", "", "")
+	sender.now = func() time.Time { return now }
+	n := NewNotifier(sender, &Log{Recipients: map[string]*Recipient{}}, 7*24*time.Hour, 30*24*time.Hour, time.Second)
+	n.now = func() time.Time { return now }
+	var slept time.Duration
+	n.sleep = func(d time.Duration) { slept += d }
+
+	other := Digest{CodiceIPA: "c_b", PEC: "c_b@pec.it", Issues: []Issue{{Kind: BrokenLink, URL: "https://example.org/c"}}}
+	noPEC := Digest{CodiceIPA: "c_c", Issues: []Issue{{Kind: BrokenLink, URL: "https://example.org/d"}}}
+	sent, err := n.Send([]Digest{digest, noPEC, other})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, time.Second, slept)
+
+	mails := server.Mails()
+	if assert.Len(t, mails, 2) {
+		assert.Equal(t, "noreply@developers.italia.it", mails[0].From)
+		assert.Equal(t, []string{"protocollo@pec.comune.a.it"}, mails[0].To)
+		assert.Contains(t, mails[0].Data, "To: protocollo@pec.comune.a.it\r\n")
+		assert.Contains(t, mails[0].Data, "Content-Type: text/plain; charset=utf-8\r\n")
+		assert.Contains(t, mails[0].Data, "\r\nGentile Comune A,\r\n")
+		assert.Equal(t, []string{"c_b@pec.it"}, mails[1].To)
+	}
+
+	// Throttled: a digest was sent less than a week ago.
+	now = now.Add(24 * time.Hour)
+	sent, err = n.Send([]Digest{digest})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Only the new issues are notified.
+	now = now.Add(7 * 24 * time.Hour)
+	updated := digest
+	updated.Issues = append([]Issue{{Kind: CodiceIPAMismatch, URL: "https://example.org/e", Detail: "c_x"}}, digest.Issues...)
+	// Even if the errors of the invalid publiccode.yml changed.
+	updated.Issues[1].Detail = "name: invalid"
+	assert.Equal(t, updated.Issues[:1], n.Pending(updated))
+	sent, err = n.Send([]Digest{updated})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	mails = server.Mails()
+	if assert.Len(t, mails, 3) {
+		assert.Contains(t, mails[2].Data, "https://example.org/e")
+		assert.NotContains(t, mails[2].Data, "https://example.org/a")
+	}
+
+	// The issues are notified again after a while.
+	now = now.Add(30 * 24 * time.Hour)
+	assert.Equal(t, updated.Issues, n.Pending(updated))
+
+	// The log is kept across runs.
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notifications.json")
+	assert.NoError(t, n.Log.Save(path))
+	loaded, err := LoadLog(path)
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Recipients, 2)
+	assert.Len(t, loaded.Recipients["protocollo@pec.comune.a.it"].Issues, 4)
+}
+
+func TestNotifierSendError(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	server := newSMTPServer(t)
+	addr := server.Addr()
+	server.Close()
+
+	n := NewNotifier(NewSMTPSender(addr, "noreply@developers.italia.it", "", ""), &Log{Recipients: map[string]*Recipient{}}, time.Hour, time.Hour, 0)
+	sent, err := n.Send([]Digest{digest})
+	assert.Error(t, err)
+	assert.Equal(t, 0, sent)
+	// Not recorded, it's sent again next time.
+	assert.Equal(t, digest.Issues, n.Pending(digest))
+}
+
+func TestReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reports.json")
+	reports, err := LoadReports(path)
+	assert.NoError(t, err)
+	assert.Empty(t, reports.Repositories)
+
+	now := time.Date(2020, 10, 20, 15, 0, 0, 0, time.UTC)
+	reports.Repositories["https://example.org/a"] = Report{CodiceIPA: "C_A", Time: now, Issues: digest.Issues[:1]}
+	reports.Repositories["https://example.org/b"] = Report{CodiceIPA: "c_a", Time: now.Add(-40 * 24 * time.Hour), Issues: digest.Issues[1:]}
+	reports.Repositories["https://example.org/c"] = Report{CodiceIPA: "c_b", Time: now}
+	assert.NoError(t, reports.Save(path))
+
+	reports, err = LoadReports(path)
+	assert.NoError(t, err)
+	assert.Len(t, reports.Repositories, 3)
+
+	reports.Prune(now, 30*24*time.Hour)
+	assert.Equal(t, map[string][]Issue{"c_a": digest.Issues[:1]}, reports.Issues())
+}
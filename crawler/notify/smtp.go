@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPSender sends the emails through an SMTP server.
+type SMTPSender struct {
+	// Addr is the address of the server, as host:port.
+	Addr string
+	From string
+	// Auth is nil if the server requires no authentication.
+	Auth smtp.Auth
+
+	now func() time.Time
+}
+
+// NewSMTPSender returns a SMTPSender through the server at addr, sending
+// from the address from. If user is empty no authentication is used.
+func NewSMTPSender(addr, from, user, password string) *SMTPSender {
+	s := &SMTPSender{Addr: addr, From: from, now: time.Now}
+	if user != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.Auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return s
+}
+
+// Send sends the email to the address to. The body is plain UTF-8 text.
+func (s *SMTPSender) Send(to, subject string, body []byte) error {
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{to}, s.message(to, subject, body))
+}
+
+// message returns the email with its headers and the lines of the body
+// terminated by CRLF.
+func (s *SMTPSender) message(to, subject string, body []byte) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", s.now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	msg.Write(bytes.ReplaceAll(body, []byte("\n"), []byte("\r\n")))
+
+	return msg.Bytes()
+}
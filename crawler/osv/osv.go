@@ -0,0 +1,345 @@
+// Package osv matches the dependencies of the software against a local copy
+// of the OSV (Open Source Vulnerabilities) database.
+//
+// The database is read from a directory with the OSV JSON files, or with
+// the all.zip dumps of the ecosystems (https://osv.dev/docs/#section/Data-Dumps),
+// so the crawler never queries an online service.
+package osv
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/italia/developers-italia-backend/crawler/sbom"
+	log "github.com/sirupsen/logrus"
+)
+
+// Severities, from the most severe.
+const (
+	Critical = "CRITICAL"
+	High     = "HIGH"
+	Medium   = "MEDIUM"
+	Low      = "LOW"
+	Unknown  = "UNKNOWN"
+)
+
+// ecosystems are the OSV ecosystems by package-url type, as in sbom.Component.
+var ecosystems = map[string]string{
+	"golang":   "Go",
+	"npm":      "npm",
+	"maven":    "Maven",
+	"pypi":     "PyPI",
+	"composer": "Packagist",
+	"gem":      "RubyGems",
+}
+
+// vulnerability is an entry of the OSV database, with the fields used here.
+type vulnerability struct {
+	ID        string   `json:"id"`
+	Summary   string   `json:"summary"`
+	Aliases   []string `json:"aliases"`
+	Withdrawn string   `json:"withdrawn"`
+	Severity  []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected         []affected       `json:"affected"`
+	DatabaseSpecific databaseSpecific `json:"database_specific"`
+}
+
+type affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string  `json:"type"`
+		Events []event `json:"events"`
+	} `json:"ranges"`
+	Versions          []string         `json:"versions"`
+	DatabaseSpecific  databaseSpecific `json:"database_specific"`
+	EcosystemSpecific databaseSpecific `json:"ecosystem_specific"`
+}
+
+type databaseSpecific struct {
+	Severity string `json:"severity"`
+}
+
+type event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// entry is a package affected by a vulnerability.
+type entry struct {
+	vuln     *vulnerability
+	affected *affected
+}
+
+// Database is the OSV database, indexed by package.
+type Database struct {
+	packages map[string][]entry
+	size     int
+}
+
+// Finding is a vulnerability of a package.
+type Finding struct {
+	Ecosystem string   `json:"ecosystem"`
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Severity  string   `json:"severity"`
+	// Fixed are the versions fixing the vulnerability.
+	Fixed []string `json:"fixed,omitempty"`
+}
+
+// Counts are the numbers of vulnerabilities of a software by severity.
+type Counts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Unknown  int `json:"unknown"`
+	Total    int `json:"total"`
+}
+
+// Load reads the OSV database in dir, in its subdirectories and in the
+// zip files in them. The invalid files are skipped.
+func Load(dir string) (*Database, error) {
+	db := &Database{packages: map[string][]entry{}}
+	invalid := 0
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if db.add(data) != nil {
+				invalid++
+			}
+		case ".zip":
+			n, err := db.addZip(path)
+			if err != nil {
+				return err
+			}
+			invalid += n
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invalid > 0 {
+		log.Warnf("%d invalid OSV entries in %s skipped", invalid, dir)
+	}
+
+	return db, nil
+}
+
+// addZip adds the JSON files in the zip file and returns how many are invalid.
+func (db *Database) addZip(path string) (int, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	invalid := 0
+	for _, f := range r.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return 0, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return 0, err
+		}
+		if db.add(data) != nil {
+			invalid++
+		}
+	}
+
+	return invalid, nil
+}
+
+// add adds the OSV entry in data.
+func (db *Database) add(data []byte) error {
+	var v vulnerability
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Withdrawn != "" {
+		return nil
+	}
+
+	db.size++
+	for i := range v.Affected {
+		a := &v.Affected[i]
+		k := key(a.Package.Ecosystem, a.Package.Name)
+		db.packages[k] = append(db.packages[k], entry{vuln: &v, affected: a})
+	}
+
+	return nil
+}
+
+// Len returns the number of vulnerabilities in the database.
+func (db *Database) Len() int {
+	return db.size
+}
+
+// Check returns the vulnerabilities of the components with a known version.
+func (db *Database) Check(components []sbom.Component) []Finding {
+	var findings []Finding
+	seen := map[string]bool{}
+
+	for _, c := range components {
+		ecosystem, ok := ecosystems[c.Ecosystem]
+		if !ok || !c.Pinned() {
+			continue
+		}
+		for _, f := range db.Query(ecosystem, c.Name, c.Version) {
+			k := f.ID + " " + f.Ecosystem + " " + f.Package + " " + f.Version
+			if !seen[k] {
+				seen[k] = true
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if rank(a.Severity) != rank(b.Severity) {
+			return rank(a.Severity) < rank(b.Severity)
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if c := compareVersions(a.Version, b.Version); c != 0 {
+			return c < 0
+		}
+
+		return a.ID < b.ID
+	})
+
+	return findings
+}
+
+// Query returns the vulnerabilities of the version of the package name in
+// the OSV ecosystem.
+func (db *Database) Query(ecosystem, name, version string) []Finding {
+	var findings []Finding
+	for _, e := range db.packages[key(ecosystem, name)] {
+		fixed, ok := e.affected.affects(version)
+		if !ok {
+			continue
+		}
+		findings = append(findings, Finding{
+			Ecosystem: ecosystem,
+			Package:   name,
+			Version:   version,
+			ID:        e.vuln.ID,
+			Aliases:   e.vuln.Aliases,
+			Summary:   e.vuln.Summary,
+			Severity:  e.vuln.severity(e.affected),
+			Fixed:     fixed,
+		})
+	}
+
+	return findings
+}
+
+// Count returns the number of distinct vulnerabilities in findings by severity.
+func Count(findings []Finding) Counts {
+	var counts Counts
+	seen := map[string]bool{}
+	for _, f := range findings {
+		if seen[f.ID] {
+			continue
+		}
+		seen[f.ID] = true
+
+		counts.Total++
+		switch f.Severity {
+		case Critical:
+			counts.Critical++
+		case High:
+			counts.High++
+		case Medium:
+			counts.Medium++
+		case Low:
+			counts.Low++
+		default:
+			counts.Unknown++
+		}
+	}
+
+	return counts
+}
+
+// affects returns true if version is affected, with the versions fixing it.
+func (a *affected) affects(version string) ([]string, bool) {
+	version = strings.TrimPrefix(version, "v")
+
+	var fixed []string
+	for _, r := range a.Ranges {
+		for _, e := range r.Events {
+			if e.Fixed != "" {
+				fixed = append(fixed, e.Fixed)
+			}
+		}
+	}
+
+	for _, v := range a.Versions {
+		if strings.TrimPrefix(v, "v") == version {
+			return fixed, true
+		}
+	}
+	for _, r := range a.Ranges {
+		// The commits of GIT ranges can't be matched with versions.
+		if r.Type != "GIT" && inRange(version, r.Events) {
+			return fixed, true
+		}
+	}
+
+	return nil, false
+}
+
+// key returns the key of a package in the database. PyPI names are
+// normalized as in PEP 503.
+func key(ecosystem, name string) string {
+	name = strings.ToLower(name)
+	if ecosystem == "PyPI" {
+		name = strings.NewReplacer("_", "-", ".", "-").Replace(name)
+	}
+
+	return ecosystem + " " + name
+}
+
+// rank returns the position of severity, the most severe first.
+func rank(severity string) int {
+	for i, s := range []string{Critical, High, Medium, Low} {
+		if s == severity {
+			return i
+		}
+	}
+
+	return 4
+}
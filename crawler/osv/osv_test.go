@@ -0,0 +1,171 @@
+package osv
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/sbom"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var entries = map[string]string{
+	"npm/GHSA-35jh-r3h4-6jhm.json": `{
+  "id": "GHSA-35jh-r3h4-6jhm",
+  "summary": "Command Injection in lodash",
+  "aliases": ["CVE-2021-23337"],
+  "affected": [{
+    "package": {"ecosystem": "npm", "name": "lodash"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]
+  }],
+  "database_specific": {"severity": "HIGH"}
+}`,
+	"PyPI/PYSEC-2021-1.json": `{
+  "id": "PYSEC-2021-1",
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "Django"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "3.0"}, {"fixed": "3.0.14"}, {"introduced": "3.1"}, {"fixed": "3.1.8"}]}],
+    "versions": ["2.2.1"]
+  }],
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}]
+}`,
+	"Go/GO-2020-1.json": `{
+  "id": "GO-2020-1",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/gin-gonic/gin"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"last_affected": "1.6.2"}]}]
+  }]
+}`,
+	"npm/withdrawn.json": `{
+  "id": "GHSA-withdrawn",
+  "withdrawn": "2021-01-01T00:00:00Z",
+  "affected": [{"package": {"ecosystem": "npm", "name": "lodash"}, "versions": ["4.17.20"]}]
+}`,
+	"npm/invalid.json": `{"id": `,
+}
+
+func writeDatabase(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "osv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range entries {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The Maven dump, as downloaded from osv.dev.
+	f, err := os.Create(filepath.Join(dir, "all.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	zf, _ := w.Create("GHSA-jfh8-c2jp-5v3q.json")
+	_, _ = zf.Write([]byte(`{
+  "id": "GHSA-jfh8-c2jp-5v3q",
+  "affected": [{
+    "package": {"ecosystem": "Maven", "name": "org.apache.logging.log4j:log4j-core"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "2.0-beta9"}, {"fixed": "2.15.0"}]}]
+  }],
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H"}]
+}`))
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+
+	return dir
+}
+
+func TestLoadAndCheck(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir := writeDatabase(t)
+	defer os.RemoveAll(dir)
+
+	db, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, db.Len())
+
+	findings := db.Check([]sbom.Component{
+		{Ecosystem: "npm", Name: "lodash", Version: "4.17.20"},
+		{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"},
+		{Ecosystem: "npm", Name: "lodash", Version: "^4.17.0"},
+		{Ecosystem: "pypi", Name: "django", Version: "3.1.2"},
+		{Ecosystem: "pypi", Name: "django", Version: "3.0.14"},
+		{Ecosystem: "pypi", Name: "django", Version: "2.2.1"},
+		{Ecosystem: "golang", Name: "github.com/gin-gonic/gin", Version: "v1.6.2"},
+		{Ecosystem: "golang", Name: "github.com/gin-gonic/gin", Version: "v1.6.3"},
+		{Ecosystem: "maven", Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1"},
+		{Ecosystem: "maven", Name: "org.apache.logging.log4j:log4j-core", Version: "2.0-alpha1"},
+		{Ecosystem: "gem", Name: "rails", Version: "6.0.0"},
+	})
+
+	var found []string
+	for _, f := range findings {
+		found = append(found, f.ID+" "+f.Package+"@"+f.Version+" "+f.Severity)
+	}
+	assert.Equal(t, []string{
+		"PYSEC-2021-1 django@2.2.1 CRITICAL",
+		"PYSEC-2021-1 django@3.1.2 CRITICAL",
+		"GHSA-jfh8-c2jp-5v3q org.apache.logging.log4j:log4j-core@2.14.1 CRITICAL",
+		"GHSA-35jh-r3h4-6jhm lodash@4.17.20 HIGH",
+		"GO-2020-1 github.com/gin-gonic/gin@v1.6.2 UNKNOWN",
+	}, found)
+	assert.Equal(t, []string{"3.0.14", "3.1.8"}, findings[0].Fixed)
+	assert.Equal(t, []string{"CVE-2021-23337"}, findings[3].Aliases)
+
+	assert.Equal(t, Counts{Critical: 2, High: 1, Unknown: 1, Total: 4}, Count(findings))
+	assert.Equal(t, Counts{}, Count(nil))
+
+	_, err = Load(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0", "1.0", 0},
+		{"1.0.1", "1.0", 1},
+		{"2.0-beta9", "2.0", -1},
+		{"2.0-beta9", "2.0-beta10", -1},
+		{"1.0.post1", "1.0", 1},
+		{"5.2.8.RELEASE", "5.2.8", 0},
+		{"0", "0.0.1", -1},
+		{"1.0.0+build.1", "1.0.0", 0},
+	} {
+		assert.Equal(t, tt.want, compareVersions(tt.a, tt.b), tt.a+" "+tt.b)
+		assert.Equal(t, -tt.want, compareVersions(tt.b, tt.a), tt.b+" "+tt.a)
+	}
+}
+
+func TestCVSS3Score(t *testing.T) {
+	for vector, want := range map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10,
+		"CVSS:3.0/AV:N/AC:L/PR:L/UI:R/S:C/C:L/I:L/A:N": 5.4,
+		"CVSS:3.1/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N": 1.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N": 0,
+	} {
+		score, ok := cvss3Score(vector)
+		assert.True(t, ok, vector)
+		assert.Equal(t, want, score, vector)
+	}
+
+	_, ok := cvss3Score("AV:N/AC:L/Au:N/C:P/I:P/A:P")
+	assert.False(t, ok)
+	_, ok = cvss3Score("CVSS:3.1/AV:N/AC:L")
+	assert.False(t, ok)
+}
@@ -0,0 +1,127 @@
+package osv
+
+import (
+	"math"
+	"strings"
+)
+
+// severity returns the severity of the vulnerability of the affected
+// package: the one given by the database, if any, or the rating of the
+// CVSS v3 score.
+func (v *vulnerability) severity(a *affected) string {
+	for _, s := range []string{a.EcosystemSpecific.Severity, a.DatabaseSpecific.Severity, v.DatabaseSpecific.Severity} {
+		switch strings.ToUpper(s) {
+		case Critical:
+			return Critical
+		case High:
+			return High
+		case Medium, "MODERATE":
+			return Medium
+		case Low:
+			return Low
+		}
+	}
+
+	for _, s := range v.Severity {
+		if s.Type != "CVSS_V3" {
+			continue
+		}
+		if score, ok := cvss3Score(s.Score); ok {
+			return rating(score)
+		}
+	}
+
+	return Unknown
+}
+
+// rating returns the qualitative severity of a CVSS v3 score.
+func rating(score float64) string {
+	switch {
+	case score >= 9:
+		return Critical
+	case score >= 7:
+		return High
+	case score >= 4:
+		return Medium
+	}
+
+	return Low
+}
+
+// cvss3Weights are the weights of the values of the CVSS v3 base metrics.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3Score returns the base score of a CVSS v3 vector, such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", as defined in
+// https://www.first.org/cvss/v3.1/specification-document.
+func cvss3Score(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, false
+	}
+
+	values := map[string]string{}
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) == 2 {
+			values[kv[0]] = kv[1]
+		}
+	}
+
+	scope := values["S"]
+	if scope != "U" && scope != "C" {
+		return 0, false
+	}
+	w := map[string]float64{}
+	for metric, weights := range cvss3Weights {
+		weight, ok := weights[values[metric]]
+		if !ok {
+			return 0, false
+		}
+		w[metric] = weight
+	}
+	// Privileges weigh more when the scope changes.
+	if scope == "C" {
+		switch values["PR"] {
+		case "L":
+			w["PR"] = 0.68
+		case "H":
+			w["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if scope == "C" {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+
+	if impact <= 0 {
+		return 0, true
+	}
+	if scope == "C" {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp returns the smallest number with one decimal not less than x,
+// avoiding the floating point errors as in the CVSS v3.1 specification.
+func roundUp(x float64) float64 {
+	n := int64(math.Round(x * 100000))
+	if n%10000 == 0 {
+		return float64(n) / 100000
+	}
+
+	return float64(n/10000+1) / 10
+}
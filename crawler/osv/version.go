@@ -0,0 +1,180 @@
+package osv
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// inRange returns true if version is in the range made of events, as
+// defined by the OSV schema: the version is affected after an introduced
+// event, until a fixed or a last_affected event.
+func inRange(version string, events []event) bool {
+	sorted := make([]event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareVersions(sorted[i].version(), sorted[j].version()) < 0
+	})
+
+	affected := false
+	for _, e := range sorted {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || compareVersions(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if compareVersions(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if compareVersions(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+
+	return affected
+}
+
+// version returns the version of the event.
+func (e event) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	}
+
+	return e.LastAffected
+}
+
+// postReleases are the suffixes of the versions following a release,
+// all the others (alpha, beta, rc, SNAPSHOT...) precede it.
+var postReleases = []string{"post", "p", "pl", "sp", "patch", "r", "rev"}
+
+// releases are the suffixes of the releases, as in 1.0.0.RELEASE.
+var releases = []string{"final", "ga", "release"}
+
+// compareVersions compares the versions a and b, returning -1, 0 or 1.
+//
+// It's not specific to an ecosystem: the versions are split into numbers
+// and words, the numbers are compared as numbers, a number follows a word
+// and the pre-release words (1.0-rc1) precede the release (1.0). This
+// is right for semantic versioning and for the common versions of the
+// other ecosystems.
+func compareVersions(a, b string) int {
+	// "0" is the start of every range.
+	if a == "0" || b == "0" {
+		switch {
+		case a == b:
+			return 0
+		case a == "0":
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	ta, tb := tokenize(a), tokenize(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		if i >= len(ta) {
+			if t := trailing(tb[i]); t != 0 {
+				return -t
+			}
+			continue
+		}
+		if i >= len(tb) {
+			if t := trailing(ta[i]); t != 0 {
+				return t
+			}
+			continue
+		}
+
+		x, y := ta[i], tb[i]
+		nx, errX := strconv.ParseUint(x, 10, 64)
+		ny, errY := strconv.ParseUint(y, 10, 64)
+		switch {
+		case errX == nil && errY == nil:
+			if nx != ny {
+				if nx < ny {
+					return -1
+				}
+				return 1
+			}
+		case errX == nil:
+			return 1
+		case errY == nil:
+			return -1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+// trailing returns how a version with the extra token compares to the same
+// version without it: after it if it's a number or a post release, before
+// it if it's a pre release, the same if it's a zero (1.0.0 is 1.0) or it
+// marks the release.
+func trailing(token string) int {
+	if n, err := strconv.ParseUint(token, 10, 64); err == nil {
+		if n == 0 {
+			return 0
+		}
+		return 1
+	}
+	for _, release := range releases {
+		if token == release {
+			return 0
+		}
+	}
+	for _, post := range postReleases {
+		if token == post {
+			return 1
+		}
+	}
+
+	return -1
+}
+
+// tokenize splits a version in numbers and lowercase words, without the
+// "v" prefix and the build metadata.
+func tokenize(version string) []string {
+	version = strings.ToLower(strings.TrimPrefix(version, "v"))
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+
+	var tokens []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, string(current))
+			current = current[:0]
+		}
+	}
+	for _, r := range version {
+		switch {
+		case unicode.IsDigit(r):
+			if len(current) > 0 && !unicode.IsDigit(current[0]) {
+				flush()
+			}
+			current = append(current, r)
+		case unicode.IsLetter(r):
+			if len(current) > 0 && unicode.IsDigit(current[0]) {
+				flush()
+			}
+			current = append(current, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
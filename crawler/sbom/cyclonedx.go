@@ -0,0 +1,122 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/version"
+)
+
+// CycloneDXVersion is the version of the CycloneDX specification of the BOMs.
+const CycloneDXVersion = "1.4"
+
+// BOM is a CycloneDX BOM, with the fields used by the crawler.
+type BOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     bomMetadata     `json:"metadata"`
+	Components   []bomComponent  `json:"components"`
+	Dependencies []bomDependency `json:"dependencies"`
+}
+
+type bomMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []bomTool    `json:"tools"`
+	Component bomComponent `json:"component"`
+}
+
+type bomTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type bomComponent struct {
+	Type               string                 `json:"type"`
+	BOMRef             string                 `json:"bom-ref"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	Scope              string                 `json:"scope,omitempty"`
+	PURL               string                 `json:"purl,omitempty"`
+	ExternalReferences []bomExternalReference `json:"externalReferences,omitempty"`
+}
+
+type bomExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type bomDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// NewBOM returns the CycloneDX BOM of the software name in the repository
+// at url, made of components, at time t.
+// The software depends on the direct dependencies only, since the
+// lockfiles don't tell which package brings in the others.
+func NewBOM(name, url string, components []Component, t time.Time) BOM {
+	bom := BOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXVersion,
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: bomMetadata{
+			Timestamp: t.UTC().Format(time.RFC3339),
+			Tools: []bomTool{
+				{Vendor: "Developers Italia", Name: "developers-italia-backend", Version: version.VERSION},
+			},
+			Component: bomComponent{
+				Type:   "application",
+				BOMRef: url,
+				Name:   name,
+				ExternalReferences: []bomExternalReference{
+					{Type: "vcs", URL: url},
+				},
+			},
+		},
+		Components:   []bomComponent{},
+		Dependencies: []bomDependency{},
+	}
+
+	root := bomDependency{Ref: url, DependsOn: []string{}}
+	seen := map[string]bool{}
+	for _, c := range components {
+		purl := c.PURL()
+		if !seen[purl] {
+			seen[purl] = true
+
+			scope := "required"
+			if c.Dev {
+				scope = "optional"
+			}
+			bom.Components = append(bom.Components, bomComponent{
+				Type:    "library",
+				BOMRef:  purl,
+				Name:    c.Name,
+				Version: c.Version,
+				Scope:   scope,
+				PURL:    purl,
+			})
+		}
+		if c.Direct && !contains(root.DependsOn, purl) {
+			root.DependsOn = append(root.DependsOn, purl)
+		}
+	}
+	bom.Dependencies = append(bom.Dependencies, root)
+
+	return bom
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
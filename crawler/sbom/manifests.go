@@ -0,0 +1,484 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// parseGoMod returns the modules required in a go.mod.
+func parseGoMod(data []byte) ([]Component, error) {
+	var components []Component
+
+	inRequire := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		comment := ""
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = strings.TrimSpace(line[:i]), line[i:]
+		}
+
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+		case !inRequire:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		components = append(components, Component{
+			Ecosystem: "golang",
+			Name:      fields[0],
+			Version:   fields[1],
+			Direct:    !strings.Contains(comment, "indirect"),
+		})
+	}
+
+	return components, scanner.Err()
+}
+
+// parsePackageJSON returns the dependencies in a package.json.
+func parsePackageJSON(data []byte) ([]Component, error) {
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	components := direct("npm", manifest.Dependencies, false)
+	return append(components, direct("npm", manifest.DevDependencies, true)...), nil
+}
+
+// npmLockDependency is a package in a version 1 package-lock.json.
+type npmLockDependency struct {
+	Version      string                       `json:"version"`
+	Dev          bool                         `json:"dev"`
+	Dependencies map[string]npmLockDependency `json:"dependencies"`
+}
+
+// parsePackageLock returns the packages in a package-lock.json.
+func parsePackageLock(data []byte) ([]Component, error) {
+	var lock struct {
+		// Version 2 and 3.
+		Packages map[string]struct {
+			Version string `json:"version"`
+			Dev     bool   `json:"dev"`
+			Link    bool   `json:"link"`
+		} `json:"packages"`
+		// Version 1.
+		Dependencies map[string]npmLockDependency `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	if len(lock.Packages) > 0 {
+		for path, p := range lock.Packages {
+			// The root package and the workspaces are not dependencies.
+			i := strings.LastIndex(path, "node_modules/")
+			if i < 0 || p.Link {
+				continue
+			}
+			components = append(components, Component{
+				Ecosystem: "npm",
+				Name:      path[i+len("node_modules/"):],
+				Version:   p.Version,
+				Dev:       p.Dev,
+				nested:    i > 0,
+			})
+		}
+
+		return components, nil
+	}
+
+	var walk func(deps map[string]npmLockDependency, nested bool)
+	walk = func(deps map[string]npmLockDependency, nested bool) {
+		for name, dep := range deps {
+			components = append(components, Component{
+				Ecosystem: "npm",
+				Name:      name,
+				Version:   dep.Version,
+				Dev:       dep.Dev,
+				nested:    nested,
+			})
+			walk(dep.Dependencies, true)
+		}
+	}
+	walk(lock.Dependencies, false)
+
+	return components, nil
+}
+
+// parseYarnLock returns the packages in a yarn.lock, both in the classic
+// and in the Berry format.
+func parseYarnLock(data []byte) ([]Component, error) {
+	var (
+		components []Component
+		name       string
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A new package: "name@range", name@other-range:
+		if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+			spec := strings.Split(strings.TrimSuffix(line, ":"), ",")[0]
+			spec = strings.Trim(strings.TrimSpace(spec), `"`)
+			name = ""
+			if i := strings.LastIndex(spec, "@"); i > 0 && !strings.Contains(spec, "@workspace:") {
+				// Berry adds the protocol to the range: name@npm:range.
+				name = spec[:i]
+			}
+			continue
+		}
+
+		field := strings.TrimSpace(line)
+		if name == "" || !strings.HasPrefix(field, "version") {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(field, "version"))
+		version = strings.Trim(strings.TrimPrefix(version, ":"), ` "`)
+		components = append(components, Component{Ecosystem: "npm", Name: name, Version: version})
+		name = ""
+	}
+
+	return components, scanner.Err()
+}
+
+// pomProperty matches the references to the properties in a pom.xml.
+var pomProperty = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// parsePom returns the dependencies in a pom.xml.
+func parsePom(data []byte) ([]Component, error) {
+	var pom struct {
+		GroupID string `xml:"groupId"`
+		Version string `xml:"version"`
+		Parent  struct {
+			GroupID string `xml:"groupId"`
+			Version string `xml:"version"`
+		} `xml:"parent"`
+		Properties struct {
+			Entries []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"properties"`
+		Dependencies []struct {
+			GroupID    string `xml:"groupId"`
+			ArtifactID string `xml:"artifactId"`
+			Version    string `xml:"version"`
+			Scope      string `xml:"scope"`
+		} `xml:"dependencies>dependency"`
+	}
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil, err
+	}
+
+	properties := map[string]string{
+		"project.groupId":        pom.GroupID,
+		"project.version":        pom.Version,
+		"project.parent.groupId": pom.Parent.GroupID,
+		"project.parent.version": pom.Parent.Version,
+	}
+	if pom.GroupID == "" {
+		properties["project.groupId"] = pom.Parent.GroupID
+	}
+	if pom.Version == "" {
+		properties["project.version"] = pom.Parent.Version
+	}
+	for _, p := range pom.Properties.Entries {
+		properties[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+	resolve := func(s string) string {
+		return pomProperty.ReplaceAllStringFunc(strings.TrimSpace(s), func(ref string) string {
+			if v, ok := properties[ref[2:len(ref)-1]]; ok && v != "" {
+				return v
+			}
+			return ref
+		})
+	}
+
+	var components []Component
+	for _, d := range pom.Dependencies {
+		components = append(components, Component{
+			Ecosystem: "maven",
+			Name:      resolve(d.GroupID) + ":" + resolve(d.ArtifactID),
+			Version:   resolve(d.Version),
+			Direct:    true,
+			Dev:       strings.TrimSpace(d.Scope) == "test",
+		})
+	}
+
+	return components, nil
+}
+
+// parseRequirements returns the packages in a pip requirements.txt.
+func parseRequirements(data []byte) ([]Component, error) {
+	var components []Component
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if c, ok := parseRequirement(scanner.Text()); ok {
+			components = append(components, c)
+		}
+	}
+
+	return components, scanner.Err()
+}
+
+// parseRequirement parses a PEP 508 requirement, as in requirements.txt or
+// pyproject.toml. The version is set only if it's pinned.
+func parseRequirement(line string) (Component, bool) {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	// Environment markers.
+	if i := strings.Index(line, ";"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	// Options, other files and URLs.
+	if line == "" || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+		return Component{}, false
+	}
+
+	name, constraint := line, ""
+	if i := strings.IndexAny(line, "=<>!~ ("); i >= 0 {
+		name, constraint = line[:i], strings.TrimSpace(line[i:])
+	}
+	// Extras.
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+
+	version := ""
+	constraint = strings.Trim(constraint, "() ")
+	if strings.HasPrefix(constraint, "==") && !strings.Contains(constraint, ",") {
+		version = strings.TrimSpace(strings.TrimPrefix(constraint, "=="))
+	}
+
+	return Component{Ecosystem: "pypi", Name: strings.TrimSpace(name), Version: version, Direct: true}, name != ""
+}
+
+// parsePyproject returns the dependencies in a pyproject.toml, either in the
+// standard project table or in the Poetry one.
+func parsePyproject(data []byte) ([]Component, error) {
+	var pyproject struct {
+		Project struct {
+			Dependencies []string `toml:"dependencies"`
+		} `toml:"project"`
+		Tool struct {
+			Poetry struct {
+				Dependencies    map[string]interface{} `toml:"dependencies"`
+				DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+			} `toml:"poetry"`
+		} `toml:"tool"`
+	}
+	if err := toml.Unmarshal(data, &pyproject); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for _, requirement := range pyproject.Project.Dependencies {
+		if c, ok := parseRequirement(requirement); ok {
+			components = append(components, c)
+		}
+	}
+	for _, deps := range []struct {
+		deps map[string]interface{}
+		dev  bool
+	}{{pyproject.Tool.Poetry.Dependencies, false}, {pyproject.Tool.Poetry.DevDependencies, true}} {
+		versions := map[string]string{}
+		for name, v := range deps.deps {
+			if strings.ToLower(name) == "python" {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				versions[name] = v
+			case map[string]interface{}:
+				versions[name], _ = v["version"].(string)
+			default:
+				versions[name] = ""
+			}
+		}
+		components = append(components, direct("pypi", versions, deps.dev)...)
+	}
+
+	return components, nil
+}
+
+// parsePoetryLock returns the packages in a poetry.lock.
+func parsePoetryLock(data []byte) ([]Component, error) {
+	var lock struct {
+		Package []struct {
+			Name     string `toml:"name"`
+			Version  string `toml:"version"`
+			Category string `toml:"category"`
+		} `toml:"package"`
+	}
+	if err := toml.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for _, p := range lock.Package {
+		components = append(components, Component{
+			Ecosystem: "pypi",
+			Name:      p.Name,
+			Version:   p.Version,
+			Dev:       p.Category == "dev",
+		})
+	}
+
+	return components, nil
+}
+
+// parseComposerJSON returns the dependencies in a composer.json.
+func parseComposerJSON(data []byte) ([]Component, error) {
+	var manifest struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for _, c := range append(direct("composer", manifest.Require, false), direct("composer", manifest.RequireDev, true)...) {
+		// PHP itself and its extensions are not packages.
+		if strings.Contains(c.Name, "/") {
+			components = append(components, c)
+		}
+	}
+
+	return components, nil
+}
+
+// parseComposerLock returns the packages in a composer.lock.
+func parseComposerLock(data []byte) ([]Component, error) {
+	type composerPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Packages    []composerPackage `json:"packages"`
+		PackagesDev []composerPackage `json:"packages-dev"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for _, p := range lock.Packages {
+		components = append(components, Component{Ecosystem: "composer", Name: p.Name, Version: p.Version})
+	}
+	for _, p := range lock.PackagesDev {
+		components = append(components, Component{Ecosystem: "composer", Name: p.Name, Version: p.Version, Dev: true})
+	}
+
+	return components, nil
+}
+
+// parseGemfileLock returns the gems in a Gemfile.lock, which has both the
+// installed gems (specs) and the ones in the Gemfile (DEPENDENCIES).
+func parseGemfileLock(data []byte) ([]Component, error) {
+	var (
+		names    []string
+		versions = map[string]string{}
+		declared = map[string]bool{}
+		section  string
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" && !strings.HasPrefix(line, " ") {
+			section = strings.TrimSpace(line)
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], "!")
+
+		switch {
+		case section == "DEPENDENCIES" && indent == 2:
+			declared[name] = true
+		case (section == "GEM" || section == "GIT" || section == "PATH") && indent == 4:
+			if _, ok := versions[name]; !ok {
+				names = append(names, name)
+			}
+			if len(fields) > 1 {
+				versions[name] = strings.Trim(fields[1], "()")
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for _, name := range names {
+		components = append(components, Component{
+			Ecosystem: "gem",
+			Name:      name,
+			Version:   versions[name],
+			Direct:    declared[name],
+		})
+	}
+	missing := map[string]string{}
+	for name := range declared {
+		if _, ok := versions[name]; !ok {
+			missing[name] = ""
+		}
+	}
+
+	return append(components, direct("gem", missing, false)...), nil
+}
+
+// direct returns the direct dependencies in versions, by name.
+func direct(ecosystem string, versions map[string]string, dev bool) []Component {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := make([]Component, 0, len(names))
+	for _, name := range names {
+		components = append(components, Component{
+			Ecosystem: ecosystem,
+			Name:      name,
+			Version:   strings.TrimSpace(versions[name]),
+			Direct:    true,
+			Dev:       dev,
+		})
+	}
+
+	return components
+}
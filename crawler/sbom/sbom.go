@@ -0,0 +1,231 @@
+// Package sbom extracts the dependencies of a repository from its manifests
+// and lockfiles, and writes them as a CycloneDX SBOM (Software Bill of
+// Materials).
+package sbom
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree"
+)
+
+// Component is a package the repository depends on.
+type Component struct {
+	// Ecosystem is the package-url type of the package: golang, npm,
+	// maven, pypi, composer or gem.
+	Ecosystem string `json:"ecosystem"`
+	// Name is the full name of the package, e.g. "@babel/core" or
+	// "org.apache.commons:commons-lang3".
+	Name string `json:"name"`
+	// Version is the locked version, or the version constraint when the
+	// repository has no lockfile.
+	Version string `json:"version,omitempty"`
+	// Direct is true for the dependencies declared by the repository,
+	// false for the ones they bring in.
+	Direct bool `json:"direct"`
+	// Dev is true for the dependencies used only to develop or test.
+	Dev bool `json:"dev,omitempty"`
+	// Manifest is the path of the file the package was found in.
+	Manifest string `json:"manifest"`
+
+	// nested is true for the packages installed inside other packages,
+	// which are never direct dependencies.
+	nested bool
+}
+
+// Dependency is the summary of a direct dependency, as saved in Elasticsearch.
+type Dependency struct {
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// parser returns the components in a manifest.
+type parser func(data []byte) ([]Component, error)
+
+// parsers are the parsers of the supported manifests, by file name.
+var parsers = map[string]parser{
+	"go.mod":            parseGoMod,
+	"package.json":      parsePackageJSON,
+	"package-lock.json": parsePackageLock,
+	"yarn.lock":         parseYarnLock,
+	"pom.xml":           parsePom,
+	"requirements.txt":  parseRequirements,
+	"pyproject.toml":    parsePyproject,
+	"poetry.lock":       parsePoetryLock,
+	"composer.json":     parseComposerJSON,
+	"composer.lock":     parseComposerLock,
+	"Gemfile.lock":      parseGemfileLock,
+}
+
+// Extractor is a worktree.Visitor collecting the components in the
+// manifests of the repository in a directory.
+type Extractor struct {
+	dir        string
+	components []Component
+	invalid    []string
+}
+
+// NewExtractor returns an Extractor of the manifests in the repository in dir.
+func NewExtractor(dir string) *Extractor {
+	return &Extractor{dir: dir}
+}
+
+// Extract returns the components in the manifests of the repository in dir.
+// The components of the valid manifests are returned even if some of them
+// can't be parsed, with an error listing the invalid ones. If the repository
+// can't be read, the components are nil.
+func Extract(dir string) ([]Component, error) {
+	e := NewExtractor(dir)
+	if err := worktree.Walk(dir, e); err != nil {
+		return nil, err
+	}
+
+	return e.Components()
+}
+
+// Visit implements worktree.Visitor, parsing the manifests.
+func (e *Extractor) Visit(path string, info os.FileInfo) error {
+	parse, ok := parsers[info.Name()]
+	if !ok || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	manifest, err := filepath.Rel(e.dir, path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	found, err := parse(data)
+	if err != nil {
+		e.invalid = append(e.invalid, fmt.Sprintf("%s: %v", manifest, err))
+		return nil
+	}
+	for i := range found {
+		found[i].Manifest = filepath.ToSlash(manifest)
+	}
+	e.components = append(e.components, found...)
+
+	return nil
+}
+
+// Components returns the components in the manifests visited, as Extract.
+func (e *Extractor) Components() ([]Component, error) {
+	components := merge(e.components)
+	if len(e.invalid) > 0 {
+		return components, fmt.Errorf("invalid manifests: %s", strings.Join(e.invalid, "; "))
+	}
+
+	return components, nil
+}
+
+// merge merges the dependencies declared in the manifests with the ones
+// in the lockfiles of the same directory, which have the exact versions.
+func merge(components []Component) []Component {
+	key := func(c Component) string {
+		return filepath.Dir(c.Manifest) + " " + c.Ecosystem + " " + strings.ToLower(c.Name)
+	}
+
+	// The dependencies declared in the manifests.
+	declared := map[string]int{}
+	for i, c := range components {
+		if c.Direct {
+			declared[key(c)] = i
+		}
+	}
+
+	merged := make([]Component, 0, len(components))
+	used := map[int]bool{}
+	seen := map[string]bool{}
+	for _, c := range components {
+		if c.Direct {
+			continue
+		}
+		if j, ok := declared[key(c)]; ok && !c.nested && !used[j] {
+			c.Direct, c.Dev = true, components[j].Dev
+			used[j] = true
+		}
+		if k := key(c) + " " + c.Version; !seen[k] {
+			seen[k] = true
+			merged = append(merged, c)
+		}
+	}
+	for i, c := range components {
+		if c.Direct && !used[i] {
+			merged = append(merged, c)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.Manifest != b.Manifest {
+			return a.Manifest < b.Manifest
+		}
+		if a.Direct != b.Direct {
+			return a.Direct
+		}
+
+		return a.Name < b.Name
+	})
+
+	return merged
+}
+
+// Summary returns the direct dependencies, once each.
+func Summary(components []Component) []Dependency {
+	var dependencies []Dependency
+	seen := map[Dependency]bool{}
+	for _, c := range components {
+		d := Dependency{Name: c.Name, Version: c.Version, Ecosystem: c.Ecosystem}
+		if c.Direct && !seen[d] {
+			seen[d] = true
+			dependencies = append(dependencies, d)
+		}
+	}
+
+	return dependencies
+}
+
+// PURL returns the package-url of the component.
+func (c Component) PURL() string {
+	name := c.Name
+	switch c.Ecosystem {
+	case "npm":
+		name = strings.Replace(name, "@", "%40", 1)
+	case "maven":
+		name = strings.Replace(name, ":", "/", 1)
+	case "pypi":
+		name = strings.ToLower(strings.Replace(name, "_", "-", -1))
+	}
+
+	purl := "pkg:" + c.Ecosystem + "/" + name
+	if c.Pinned() {
+		purl += "@" + c.Version
+	}
+
+	return purl
+}
+
+// Pinned returns true if the version of the component is known, and it's
+// not a version constraint.
+func (c Component) Pinned() bool {
+	return c.Version != "" && !strings.ContainsAny(c.Version, "^~<>=*|, :$[](){}")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
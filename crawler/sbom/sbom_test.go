@@ -0,0 +1,237 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree/worktreetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var manifests = map[string]string{
+	"go.mod": `module example.org/app
+
+go 1.13
+
+require github.com/spf13/viper v1.7.0
+
+require (
+	github.com/sirupsen/logrus v1.7.0
+	golang.org/x/sys v0.0.0-20201013132646-2da7054afaeb // indirect
+)
+
+replace example.org/other => ../other
+`,
+	"web/package.json": `{
+  "dependencies": {"@babel/core": "^7.10.0", "left-pad": "1.3.0"},
+  "devDependencies": {"jest": "^26.0.0"}
+}`,
+	"web/package-lock.json": `{
+  "lockfileVersion": 2,
+  "packages": {
+    "": {"name": "web"},
+    "node_modules/@babel/core": {"version": "7.10.4"},
+    "node_modules/jest": {"version": "26.4.2", "dev": true},
+    "node_modules/jest/node_modules/left-pad": {"version": "1.1.0", "dev": true},
+    "node_modules/debug": {"version": "4.1.1"}
+  }
+}`,
+	"web/node_modules/debug/package.json": `{"dependencies": {"ms": "2.1.2"}}`,
+	"api/pom.xml": `<project>
+  <groupId>it.gov.example</groupId>
+  <version>1.0.0</version>
+  <properties><spring.version>5.2.8.RELEASE</spring.version></properties>
+  <dependencies>
+    <dependency>
+      <groupId>org.springframework</groupId>
+      <artifactId>spring-core</artifactId>
+      <version>${spring.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>junit</groupId>
+      <artifactId>junit</artifactId>
+      <version>4.13</version>
+      <scope>test</scope>
+    </dependency>
+  </dependencies>
+</project>`,
+	"scripts/requirements.txt": `# Scripts
+requests[security]==2.24.0 ; python_version >= "3.6"
+Django>=3.0,<3.2
+-r other.txt
+git+https://github.com/example/lib.git
+`,
+	"py/pyproject.toml": `[tool.poetry.dependencies]
+python = "^3.8"
+flask = "^1.1"
+
+[tool.poetry.dev-dependencies]
+pytest = {version = "^6.0"}
+`,
+	"py/poetry.lock": `[[package]]
+name = "flask"
+version = "1.1.2"
+category = "main"
+
+[[package]]
+name = "pytest"
+version = "6.1.1"
+category = "dev"
+
+[[package]]
+name = "click"
+version = "7.1.2"
+category = "main"
+`,
+	"php/composer.json": `{"require": {"php": ">=7.2", "ext-json": "*", "monolog/monolog": "^2.0"}}`,
+	"php/composer.lock": `{"packages": [{"name": "monolog/monolog", "version": "2.1.1"}, {"name": "psr/log", "version": "1.1.3"}]}`,
+	"rb/Gemfile.lock": `GEM
+  remote: https://rubygems.org/
+  specs:
+    rack (2.2.3)
+    sinatra (2.1.0)
+      rack (~> 2.2)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  sinatra (~> 2.1)
+
+BUNDLED WITH
+   2.1.4
+`,
+	"yarn/yarn.lock": `# yarn lockfile v1
+
+"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.10.4":
+  version "7.10.4"
+  resolved "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.10.4.tgz"
+
+lodash@^4.17.19:
+  version "4.17.20"
+`,
+}
+
+func find(components []Component, ecosystem, name string) []Component {
+	var found []Component
+	for _, c := range components {
+		if c.Ecosystem == ecosystem && c.Name == name {
+			found = append(found, c)
+		}
+	}
+
+	return found
+}
+
+func TestExtract(t *testing.T) {
+	dir := worktreetest.Write(t, manifests)
+	defer os.RemoveAll(dir)
+
+	components, err := Extract(dir)
+	assert.NoError(t, err)
+
+	for _, tt := range []struct {
+		ecosystem, name, version string
+		direct, dev              bool
+	}{
+		{"golang", "github.com/spf13/viper", "v1.7.0", true, false},
+		{"golang", "github.com/sirupsen/logrus", "v1.7.0", true, false},
+		{"golang", "golang.org/x/sys", "v0.0.0-20201013132646-2da7054afaeb", false, false},
+		{"npm", "@babel/core", "7.10.4", true, false},
+		{"npm", "jest", "26.4.2", true, true},
+		{"npm", "debug", "4.1.1", false, false},
+		{"maven", "org.springframework:spring-core", "5.2.8.RELEASE", true, false},
+		{"maven", "junit:junit", "4.13", true, true},
+		{"pypi", "requests", "2.24.0", true, false},
+		{"pypi", "Django", "", true, false},
+		{"pypi", "flask", "1.1.2", true, false},
+		{"pypi", "pytest", "6.1.1", true, true},
+		{"pypi", "click", "7.1.2", false, false},
+		{"composer", "monolog/monolog", "2.1.1", true, false},
+		{"composer", "psr/log", "1.1.3", false, false},
+		{"gem", "sinatra", "2.1.0", true, false},
+		{"gem", "rack", "2.2.3", false, false},
+		{"npm", "@babel/code-frame", "7.10.4", false, false},
+		{"npm", "lodash", "4.17.20", false, false},
+	} {
+		found := find(components, tt.ecosystem, tt.name)
+		if assert.Len(t, found, 1, tt.name) {
+			assert.Equal(t, tt.version, found[0].Version, tt.name)
+			assert.Equal(t, tt.direct, found[0].Direct, tt.name)
+			assert.Equal(t, tt.dev, found[0].Dev, tt.name)
+		}
+	}
+
+	// Both the declared left-pad and the one installed inside jest.
+	leftPad := find(components, "npm", "left-pad")
+	assert.Len(t, leftPad, 2)
+
+	// The manifests of the dependencies are skipped.
+	assert.Empty(t, find(components, "npm", "ms"))
+	// PHP itself is not a package.
+	assert.Empty(t, find(components, "composer", "php"))
+	assert.Empty(t, find(components, "pypi", "python"))
+}
+
+func TestExtractInvalid(t *testing.T) {
+	dir := worktreetest.Write(t, map[string]string{
+		"package.json":  "{",
+		"a/go.mod":      "module a\n\nrequire github.com/a/b v1.0.0\n",
+		"b/poetry.lock": "[[package]\n",
+	})
+	defer os.RemoveAll(dir)
+
+	components, err := Extract(dir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "package.json")
+	assert.Contains(t, err.Error(), "poetry.lock")
+	assert.Len(t, components, 1)
+
+	_, err = Extract(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestSummary(t *testing.T) {
+	components := []Component{
+		{Ecosystem: "npm", Name: "a", Version: "1.0.0", Direct: true, Manifest: "package-lock.json"},
+		{Ecosystem: "npm", Name: "b", Version: "1.0.0", Manifest: "package-lock.json"},
+		{Ecosystem: "npm", Name: "a", Version: "1.0.0", Direct: true, Manifest: "web/package-lock.json"},
+	}
+
+	assert.Equal(t, []Dependency{{Name: "a", Version: "1.0.0", Ecosystem: "npm"}}, Summary(components))
+	assert.Empty(t, Summary(nil))
+}
+
+func TestPURL(t *testing.T) {
+	assert.Equal(t, "pkg:npm/%40babel/core@7.10.4", Component{Ecosystem: "npm", Name: "@babel/core", Version: "7.10.4"}.PURL())
+	assert.Equal(t, "pkg:maven/junit/junit@4.13", Component{Ecosystem: "maven", Name: "junit:junit", Version: "4.13"}.PURL())
+	assert.Equal(t, "pkg:pypi/django-rest", Component{Ecosystem: "pypi", Name: "Django_Rest", Version: ">=3.0"}.PURL())
+}
+
+func TestNewBOM(t *testing.T) {
+	components := []Component{
+		{Ecosystem: "npm", Name: "a", Version: "1.0.0", Direct: true},
+		{Ecosystem: "npm", Name: "b", Version: "2.0.0", Dev: true},
+		{Ecosystem: "npm", Name: "a", Version: "1.0.0", Direct: true, Manifest: "web/package-lock.json"},
+	}
+	bom := NewBOM("App", "https://github.com/example/app", components, time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.True(t, strings.HasPrefix(bom.SerialNumber, "urn:uuid:"))
+	assert.Len(t, bom.SerialNumber, len("urn:uuid:")+36)
+	assert.Equal(t, "2020-10-01T00:00:00Z", bom.Metadata.Timestamp)
+	assert.Equal(t, "App", bom.Metadata.Component.Name)
+	assert.Len(t, bom.Components, 2)
+	assert.Equal(t, "optional", bom.Components[1].Scope)
+	assert.Equal(t, []bomDependency{
+		{Ref: "https://github.com/example/app", DependsOn: []string{"pkg:npm/a@1.0.0"}},
+	}, bom.Dependencies)
+
+	out, err := json.Marshal(bom)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"bomFormat":"CycloneDX"`)
+	assert.Contains(t, string(out), `"bom-ref":"pkg:npm/b@2.0.0"`)
+}
@@ -0,0 +1,288 @@
+// Package staging publishes sets of exported files atomically.
+//
+// A set is written to a staging directory under OUTPUT_DIR/.exports/<name>/,
+// checked and then published by pointing the "current" symlink to it. The
+// entries in OUTPUT_DIR are symlinks to "current", so the live files always
+// belong to the same complete set, and the previously published set is kept
+// as "previous" for rollbacks:
+//
+//	OUTPUT_DIR/softwares.yml -> .exports/yaml/current/softwares.yml
+//	OUTPUT_DIR/.exports/yaml/current -> 20201020T101010Z-123
+//	OUTPUT_DIR/.exports/yaml/previous -> 20201019T101010Z-456
+package staging
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	exportsDir = ".exports"
+	current    = "current"
+	previous   = "previous"
+	// migrated is where the directories exported before staging was used
+	// are moved to, to be replaced by the links.
+	migrated = "migrated"
+)
+
+// Set is a set of exported files being staged.
+type Set struct {
+	// Dir is the staging directory the files must be written to.
+	Dir string
+
+	outputDir string
+	name      string
+}
+
+// New creates the staging directory for a new set of files that will be
+// published to outputDir. name identifies the kind of set (eg. the export format).
+func New(outputDir, name string) (*Set, error) {
+	base := filepath.Join(outputDir, exportsDir, name)
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+
+	// Staging directories sort by creation time.
+	dir, err := ioutil.TempDir(base, time.Now().UTC().Format("20060102T150405Z")+"-")
+	if err != nil {
+		return nil, err
+	}
+	// TempDir creates the directory with 0700, but the files must be served.
+	if err := os.Chmod(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Set{Dir: dir, outputDir: outputDir, name: name}, nil
+}
+
+// Check makes sure all the files in the set are not empty and, if they are
+// YAML, JSON or XML files, that they can be parsed.
+func (s *Set) Check() error {
+	return filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(s.Dir, path)
+		if info.Size() == 0 {
+			return fmt.Errorf("%s is empty", rel)
+		}
+
+		var v interface{}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yml", ".yaml":
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := yaml.Unmarshal(data, &v); err != nil {
+				return fmt.Errorf("%s is not valid YAML: %v", rel, err)
+			}
+		case ".json", ".jsonld":
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return fmt.Errorf("%s is not valid JSON: %v", rel, err)
+			}
+		case ".xml", ".rdf", ".atom":
+			if err := checkXML(path); err != nil {
+				return fmt.Errorf("%s is not valid XML: %v", rel, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// checkXML makes sure that the file is well-formed XML.
+func checkXML(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	decoder := xml.NewDecoder(f)
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Discard removes the staging directory.
+func (s *Set) Discard() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// Publish makes the set the current one, keeping the set it replaces as the
+// previous one and removing the older ones.
+func (s *Set) Publish() error {
+	base := filepath.Join(s.outputDir, exportsDir, s.name)
+
+	old, err := os.Readlink(filepath.Join(base, current))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := replaceSymlink(filepath.Base(s.Dir), filepath.Join(base, current)); err != nil {
+		return err
+	}
+	if old != "" {
+		if err := replaceSymlink(old, filepath.Join(base, previous)); err != nil {
+			return err
+		}
+	}
+
+	if err := linkEntries(s.outputDir, s.name); err != nil {
+		return err
+	}
+
+	return prune(base)
+}
+
+// Rollback makes the previous set of files published to outputDir the current one.
+func Rollback(outputDir, name string) error {
+	base := filepath.Join(outputDir, exportsDir, name)
+
+	cur, err := os.Readlink(filepath.Join(base, current))
+	if err != nil {
+		return fmt.Errorf("no %s export to roll back: %v", name, err)
+	}
+	prev, err := os.Readlink(filepath.Join(base, previous))
+	if err != nil {
+		return fmt.Errorf("no previous %s export to roll back to: %v", name, err)
+	}
+
+	if err := replaceSymlink(prev, filepath.Join(base, current)); err != nil {
+		return err
+	}
+	if err := replaceSymlink(cur, filepath.Join(base, previous)); err != nil {
+		return err
+	}
+
+	return linkEntries(outputDir, name)
+}
+
+// linkEntries makes every entry of the current set available in outputDir
+// and removes the links to the entries that are not in the set anymore.
+func linkEntries(outputDir, name string) error {
+	currentDir := filepath.Join(exportsDir, name, current)
+
+	entries, err := ioutil.ReadDir(filepath.Join(outputDir, currentDir))
+	if err != nil {
+		return err
+	}
+
+	inSet := map[string]bool{}
+	for _, e := range entries {
+		inSet[e.Name()] = true
+
+		target := filepath.Join(currentDir, e.Name())
+		link := filepath.Join(outputDir, e.Name())
+		if t, err := os.Readlink(link); err == nil && t == target {
+			continue
+		}
+		// Files can be replaced atomically, directories can't: the ones
+		// exported before staging was used are moved away, once.
+		if info, err := os.Lstat(link); err == nil && info.IsDir() {
+			if err := migrate(outputDir, name, e.Name()); err != nil {
+				return err
+			}
+		}
+
+		if err := replaceSymlink(target, link); err != nil {
+			return err
+		}
+	}
+
+	rootEntries, err := ioutil.ReadDir(outputDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range rootEntries {
+		if e.Mode()&os.ModeSymlink == 0 || inSet[e.Name()] {
+			continue
+		}
+		link := filepath.Join(outputDir, e.Name())
+		if t, err := os.Readlink(link); err == nil && strings.HasPrefix(t, currentDir+string(filepath.Separator)) {
+			if err := os.Remove(link); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrate moves the directory entry of outputDir to
+// outputDir/.exports/migrated/<name>-<entry>-<time>, where it's kept to be
+// removed by hand.
+func migrate(outputDir, name, entry string) error {
+	dir := filepath.Join(outputDir, exportsDir, migrated)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(
+		filepath.Join(outputDir, entry),
+		filepath.Join(dir, name+"-"+entry+"-"+time.Now().UTC().Format("20060102T150405Z")),
+	)
+}
+
+// prune removes the sets in base that are neither current nor previous,
+// including the ones left behind by failed exports.
+func prune(base string) error {
+	keep := map[string]bool{current: true, previous: true}
+	for _, l := range []string{current, previous} {
+		if t, err := os.Readlink(filepath.Join(base, l)); err == nil {
+			keep[t] = true
+		}
+	}
+
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if keep[e.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(base, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceSymlink atomically makes link a symlink to target.
+func replaceSymlink(target, link string) error {
+	tmp := link + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, link)
+}
@@ -0,0 +1,94 @@
+package staging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSet(t *testing.T, outputDir string, files map[string]string) *Set {
+	set, err := New(outputDir, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(set.Dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return set
+}
+
+func readFile(t *testing.T, filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPublishAndRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// An export made before staging was used is replaced, and its
+	// directories are moved away.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "softwares.yml"), []byte("- old\n"), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "feeds"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "feeds", "new.atom"), []byte("<feed/>"), 0644))
+
+	first := newSet(t, dir, map[string]string{"softwares.yml": "- first\n", "removed.yml": "- first\n"})
+	assert.Nil(t, os.Mkdir(filepath.Join(first.Dir, "feeds"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(first.Dir, "feeds", "new.atom"), []byte("<feed></feed>"), 0644))
+	assert.Nil(t, first.Check())
+	assert.Nil(t, first.Publish())
+	assert.Equal(t, "- first\n", readFile(t, filepath.Join(dir, "softwares.yml")))
+	assert.Equal(t, "<feed></feed>", readFile(t, filepath.Join(dir, "feeds", "new.atom")))
+	moved, err := filepath.Glob(filepath.Join(dir, ".exports", "migrated", "yaml-feeds-*", "new.atom"))
+	assert.Nil(t, err)
+	assert.Len(t, moved, 1)
+
+	second := newSet(t, dir, map[string]string{"softwares.yml": "- second\n"})
+	assert.Nil(t, second.Publish())
+	assert.Equal(t, "- second\n", readFile(t, filepath.Join(dir, "softwares.yml")))
+	_, err = os.Lstat(filepath.Join(dir, "removed.yml"))
+	assert.True(t, os.IsNotExist(err))
+
+	// A failed export doesn't touch the published files and is pruned.
+	failed := newSet(t, dir, map[string]string{"softwares.yml": ""})
+	assert.NotNil(t, failed.Check())
+
+	third := newSet(t, dir, map[string]string{"softwares.yml": "- third\n"})
+	assert.Nil(t, third.Publish())
+	_, err = os.Stat(failed.Dir)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(first.Dir)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, Rollback(dir, "yaml"))
+	assert.Equal(t, "- second\n", readFile(t, filepath.Join(dir, "softwares.yml")))
+	assert.Nil(t, Rollback(dir, "yaml"))
+	assert.Equal(t, "- third\n", readFile(t, filepath.Join(dir, "softwares.yml")))
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, newSet(t, dir, map[string]string{"a.yml": "- a\n", "b.json": "[]"}).Check())
+	assert.EqualError(t, newSet(t, dir, map[string]string{"a.yml": ""}).Check(), "a.yml is empty")
+	assert.Error(t, newSet(t, dir, map[string]string{"a.json": "{"}).Check())
+	assert.Error(t, newSet(t, dir, map[string]string{"a.yml": "a: [\n"}).Check())
+	assert.Error(t, newSet(t, dir, map[string]string{"a.rdf": "<a><b></a>"}).Check())
+
+	assert.Error(t, Rollback(dir, "yaml"))
+}
@@ -0,0 +1,314 @@
+// Package staticapi exports the catalog as a static JSON API, for the
+// frontends that can't use the YAML files generated for Jekyll.
+//
+// The generated tree looks like this:
+//
+//	manifest.json           crawl time and totals
+//	categories.json         categories with the number of software
+//	software/index.json     first page of the software list
+//	software/index-N.json   following pages
+//	software/<slug>.json    a single software
+//	publishers/<ipa>.json   a publisher and its software
+package staticapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic"
+	"github.com/italia/developers-italia-backend/crawler/maintenance"
+	"github.com/italia/developers-italia-backend/crawler/osv"
+	es "github.com/olivere/elastic"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// PageSize is the number of software in every page of the software index.
+const PageSize = 100
+
+// Exporter exports the catalog as a static JSON API.
+type Exporter struct{}
+
+// software contains the fields of the software objects stored in
+// Elasticsearch that are used in lists.
+type software struct {
+	ID         string `json:"id"`
+	Slug       string `json:"slug"`
+	CrawlTime  string `json:"crawltime"`
+	IPALabel   string `json:"it-riuso-codiceIPA-label"`
+	PublicCode struct {
+		Name       string   `json:"name"`
+		URL        string   `json:"url"`
+		Categories []string `json:"categories"`
+		It         struct {
+			Riuso struct {
+				CodiceIPA string `json:"codiceIPA"`
+			} `json:"riuso"`
+		} `json:"it"`
+	} `json:"publiccode"`
+
+	// Vulnerabilities is nil if the crawler has no OSV database.
+	Vulnerabilities *osv.Counts `json:"vulnerabilities"`
+
+	MaintenanceStatus *maintenance.Status `json:"maintenanceStatus"`
+}
+
+// Item is a software in the lists of the API.
+type Item struct {
+	ID         string   `json:"id"`
+	Slug       string   `json:"slug"`
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	CodiceIPA  string   `json:"codiceIPA,omitempty"`
+	Categories []string `json:"categories"`
+	CrawlTime  string   `json:"crawltime"`
+	// Vulnerabilities are the counts of the known vulnerabilities of the
+	// dependencies, by severity.
+	Vulnerabilities *osv.Counts `json:"vulnerabilities,omitempty"`
+	// MaintenanceStatus is nil for the software crawled before it was computed.
+	MaintenanceStatus *maintenance.Status `json:"maintenanceStatus,omitempty"`
+	// Href is the path of the software file, relative to the API root.
+	Href string `json:"href"`
+}
+
+// Page is a page of the software index.
+type Page struct {
+	Page  int    `json:"page"`
+	Pages int    `json:"pages"`
+	Total int    `json:"total"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Items []Item `json:"items"`
+}
+
+// Publisher is a publisher with its software.
+type Publisher struct {
+	CodiceIPA string `json:"codiceIPA"`
+	Name      string `json:"name"`
+	Software  []Item `json:"software"`
+}
+
+// Category is a category with the number of software in it.
+type Category struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Manifest describes an export.
+type Manifest struct {
+	GeneratedAt string `json:"generatedAt"`
+	// CrawlTime is the time of the most recently crawled software.
+	CrawlTime  string `json:"crawltime"`
+	Software   int    `json:"software"`
+	Publishers int    `json:"publishers"`
+	Categories int    `json:"categories"`
+	Pages      int    `json:"pages"`
+	PageSize   int    `json:"pageSize"`
+}
+
+// Export writes the static JSON API to outputDir. It returns the number of
+// software in Elasticsearch when the export started, less the ones skipped
+// because they are not valid.
+func (Exporter) Export(outputDir string, elasticClient *es.Client) (int64, error) {
+	for _, dir := range []string{"software", "publishers"} {
+		if err := os.MkdirAll(path.Join(outputDir, dir), 0755); err != nil {
+			return 0, err
+		}
+	}
+
+	var items []Item
+	publishers := map[string]*Publisher{}
+	categories := map[string]int{}
+	var crawlTime time.Time
+	var skipped int64
+
+	log.Infof("Generating %s", path.Join(outputDir, "software"))
+	query := elastic.NewBoolQuery("software")
+	total, err := elastic.ScrollTotal(viper.GetString("ELASTIC_PUBLICCODE_INDEX"), query, nil, elasticClient, func(hit *es.SearchHit) error {
+		var sw software
+		if err := json.Unmarshal(*hit.Source, &sw); err != nil {
+			log.Errorf("Skipping software %s: %v", hit.Id, err)
+			skipped++
+			return nil
+		}
+		if sw.Slug == "" || strings.ContainsAny(sw.Slug, "/\\") {
+			log.Errorf("Skipping software %s: invalid slug %q", sw.ID, sw.Slug)
+			skipped++
+			return nil
+		}
+
+		// The software file is the document as stored in Elasticsearch.
+		item := newItem(&sw)
+		if err := ioutil.WriteFile(path.Join(outputDir, item.Href), *hit.Source, 0644); err != nil {
+			return err
+		}
+		items = append(items, item)
+
+		if item.CodiceIPA != "" {
+			p, ok := publishers[item.CodiceIPA]
+			if !ok {
+				p = &Publisher{CodiceIPA: item.CodiceIPA, Name: sw.IPALabel}
+				publishers[item.CodiceIPA] = p
+			}
+			p.Software = append(p.Software, item)
+		}
+
+		for _, c := range sw.PublicCode.Categories {
+			categories[c]++
+		}
+
+		if t, err := time.Parse(time.RFC3339, sw.CrawlTime); err == nil && t.After(crawlTime) {
+			crawlTime = t
+		}
+
+		return nil
+	})
+	if err != nil {
+		return total, err
+	}
+	total -= skipped
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Slug < items[j].Slug })
+
+	pages, err := writePages(path.Join(outputDir, "software"), items)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Infof("Generating %s", path.Join(outputDir, "publishers"))
+	for codiceIPA, p := range publishers {
+		if strings.ContainsAny(codiceIPA, "/\\") {
+			log.Errorf("Skipping publisher with invalid codiceIPA %q", codiceIPA)
+			continue
+		}
+		sort.Slice(p.Software, func(i, j int) bool { return p.Software[i].Slug < p.Software[j].Slug })
+		if err := writeJSON(path.Join(outputDir, "publishers", codiceIPA+".json"), p); err != nil {
+			return 0, err
+		}
+	}
+
+	categoryList := []Category{}
+	for name, count := range categories {
+		categoryList = append(categoryList, Category{name, count})
+	}
+	sort.Slice(categoryList, func(i, j int) bool { return categoryList[i].Name < categoryList[j].Name })
+	if err := writeJSON(path.Join(outputDir, "categories.json"), categoryList); err != nil {
+		return 0, err
+	}
+
+	manifest := Manifest{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Software:    len(items),
+		Publishers:  len(publishers),
+		Categories:  len(categoryList),
+		Pages:       pages,
+		PageSize:    PageSize,
+	}
+	if !crawlTime.IsZero() {
+		manifest.CrawlTime = crawlTime.Format(time.RFC3339)
+	}
+
+	return total, writeJSON(path.Join(outputDir, "manifest.json"), manifest)
+}
+
+// Validate checks that the API in outputDir contains all the software.
+func (Exporter) Validate(outputDir string, count int64) error {
+	data, err := ioutil.ReadFile(path.Join(outputDir, "manifest.json"))
+	if err != nil {
+		return err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	if int64(manifest.Software) != count {
+		return fmt.Errorf("manifest.json lists %d software, %d expected", manifest.Software, count)
+	}
+
+	for n := 1; n <= manifest.Pages; n++ {
+		if _, err := os.Stat(path.Join(outputDir, "software", pageName(n))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newItem(sw *software) Item {
+	categories := sw.PublicCode.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+
+	return Item{
+		ID:         sw.ID,
+		Slug:       sw.Slug,
+		Name:       sw.PublicCode.Name,
+		URL:        sw.PublicCode.URL,
+		CodiceIPA:  strings.ToLower(sw.PublicCode.It.Riuso.CodiceIPA), // prevent mixed case duplicates
+		Categories: categories,
+		CrawlTime:  sw.CrawlTime,
+		Href:       path.Join("software", sw.Slug+".json"),
+
+		Vulnerabilities: sw.Vulnerabilities,
+
+		MaintenanceStatus: sw.MaintenanceStatus,
+	}
+}
+
+// writePages writes the paginated index of items to dir and returns the number of pages.
+func writePages(dir string, items []Item) (int, error) {
+	pages := (len(items) + PageSize - 1) / PageSize
+	// Always write the first page, even if there's no software.
+	if pages == 0 {
+		pages = 1
+	}
+
+	for n := 1; n <= pages; n++ {
+		page := Page{Page: n, Pages: pages, Total: len(items), Items: []Item{}}
+
+		from := (n - 1) * PageSize
+		to := from + PageSize
+		if to > len(items) {
+			to = len(items)
+		}
+		page.Items = append(page.Items, items[from:to]...)
+
+		if n > 1 {
+			page.Prev = pageName(n - 1)
+		}
+		if n < pages {
+			page.Next = pageName(n + 1)
+		}
+
+		if err := writeJSON(path.Join(dir, pageName(n)), page); err != nil {
+			return n - 1, err
+		}
+	}
+
+	return pages, nil
+}
+
+// pageName returns the file name of the nth page of the software index.
+func pageName(n int) string {
+	if n == 1 {
+		return "index.json"
+	}
+	return fmt.Sprintf("index-%d.json", n)
+}
+
+func writeJSON(filename string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,123 @@
+package staticapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/elastic/elastictest"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeSoftware(i int) interface{} {
+	return map[string]interface{}{
+		"id":                       fmt.Sprintf("id-%03d", i),
+		"slug":                     fmt.Sprintf("slug-%03d", i),
+		"crawltime":                fmt.Sprintf("2020-10-%02dT10:00:00Z", i%28+1),
+		"it-riuso-codiceIPA-label": fmt.Sprintf("Comune %d", i%3),
+		"publiccode": map[string]interface{}{
+			"name":       fmt.Sprintf("Software %d", i),
+			"url":        fmt.Sprintf("https://github.com/example/repo-%d", i),
+			"categories": []string{"it-development", fmt.Sprintf("category-%d", i%2)},
+			"it": map[string]interface{}{
+				"riuso": map[string]interface{}{"codiceIPA": fmt.Sprintf("C_%d", i%3)},
+			},
+		},
+	}
+}
+
+func readJSON(t *testing.T, filename string, v interface{}) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, json.Unmarshal(data, v))
+}
+
+func TestExport(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+
+	dir, err := ioutil.TempDir("", "staticapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	docs := make([]interface{}, 250)
+	for i := range docs {
+		docs[i] = fakeSoftware(i)
+	}
+	server, client := elastictest.NewClient(t, docs)
+	defer server.Close()
+
+	count, err := Exporter{}.Export(dir, client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(250), count)
+
+	var manifest Manifest
+	readJSON(t, path.Join(dir, "manifest.json"), &manifest)
+	assert.Equal(t, 250, manifest.Software)
+	assert.Equal(t, 3, manifest.Publishers)
+	assert.Equal(t, 3, manifest.Pages)
+	assert.Equal(t, "2020-10-28T10:00:00Z", manifest.CrawlTime)
+
+	var page Page
+	readJSON(t, path.Join(dir, "software", "index.json"), &page)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, 250, page.Total)
+	assert.Equal(t, "index-2.json", page.Next)
+	assert.Empty(t, page.Prev)
+	assert.Len(t, page.Items, PageSize)
+	assert.Equal(t, "slug-000", page.Items[0].Slug)
+	assert.Equal(t, "c_0", page.Items[0].CodiceIPA)
+	assert.Equal(t, "software/slug-000.json", page.Items[0].Href)
+
+	var last Page
+	readJSON(t, path.Join(dir, "software", "index-3.json"), &last)
+	assert.Equal(t, "index-2.json", last.Prev)
+	assert.Empty(t, last.Next)
+	assert.Len(t, last.Items, 50)
+
+	// The software file is the whole document.
+	var sw map[string]interface{}
+	readJSON(t, path.Join(dir, "software", "slug-042.json"), &sw)
+	assert.Equal(t, "id-042", sw["id"])
+	assert.Contains(t, sw, "publiccode")
+
+	var publisher Publisher
+	readJSON(t, path.Join(dir, "publishers", "c_1.json"), &publisher)
+	assert.Equal(t, "Comune 1", publisher.Name)
+	assert.Len(t, publisher.Software, 83)
+
+	var categories []Category
+	readJSON(t, path.Join(dir, "categories.json"), &categories)
+	assert.Equal(t, []Category{{"category-0", 125}, {"category-1", 125}, {"it-development", 250}}, categories)
+}
+
+func TestExportSkipped(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	viper.Set("ELASTIC_PUBLICCODE_INDEX", "publiccodes")
+
+	dir, err := ioutil.TempDir("", "staticapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	invalid := fakeSoftware(2).(map[string]interface{})
+	invalid["slug"] = ""
+	server, client := elastictest.NewClient(t, []interface{}{fakeSoftware(0), fakeSoftware(1), invalid})
+	defer server.Close()
+
+	// The invalid software is left out of the export, which is still valid.
+	count, err := Exporter{}.Export(dir, client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Nil(t, Exporter{}.Validate(dir, count))
+}
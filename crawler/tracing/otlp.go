@@ -0,0 +1,182 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	serviceName = "publiccode-crawler"
+	scopeName   = "github.com/italia/developers-italia-backend/crawler/tracing"
+
+	spanKindInternal = 1
+	statusCodeOk     = 1
+	statusCodeError  = 2
+)
+
+// OTLP JSON encoding of an ExportTraceServiceRequest.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func newAttribute(key, value string) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = value
+	return a
+}
+
+// newRequest returns the OTLP request exporting spans.
+func newRequest(spans []*Span) otlpRequest {
+	var scope otlpScopeSpans
+	scope.Scope.Name = scopeName
+	for _, s := range spans {
+		scope.Spans = append(scope.Spans, s.otlp())
+	}
+
+	var resource otlpResourceSpans
+	resource.Resource.Attributes = []otlpAttribute{newAttribute("service.name", serviceName)}
+	resource.ScopeSpans = []otlpScopeSpans{scope}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{resource}}
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(s.end),
+		Status:            otlpStatus{Code: statusCodeOk},
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.err != nil {
+		span.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+	}
+
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		span.Attributes = append(span.Attributes, newAttribute(key, s.attributes[key]))
+	}
+
+	return span
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpExporter sends the spans to an OTLP/HTTP collector.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newOTLPExporter(endpoint string) *otlpExporter {
+	return &otlpExporter{endpoint: endpoint, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (e *otlpExporter) export(spans []*Span) error {
+	body, err := json.Marshal(newRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("OTLP collector at %s returned %s: %s", e.endpoint, resp.Status, msg)
+	}
+
+	return nil
+}
+
+// fileExporter appends the spans to a file, one OTLP request per line,
+// as the file exporter of the OpenTelemetry collector does.
+type fileExporter struct {
+	filename string
+	mu       sync.Mutex
+}
+
+func (e *fileExporter) export(spans []*Span) error {
+	line, err := json.Marshal(newRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	f, err := os.OpenFile(e.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close() // nolint: errcheck
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,203 @@
+// Package tracing traces the crawls, to find out where the time goes.
+//
+// Spans are exported in the OpenTelemetry protocol (OTLP) JSON encoding to
+// the collector at TRACING_OTLP_ENDPOINT (eg. http://localhost:4318/v1/traces)
+// and/or appended to the TRACING_FILE file, one request per line.
+// If none of them is set, tracing is disabled and Start returns nil spans,
+// which can be used as any other span.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// BatchSize is the number of ended spans exported together.
+const BatchSize = 512
+
+// Span is an operation of a crawl.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+type exporter interface {
+	export(spans []*Span) error
+}
+
+// tracer buffers the ended spans and exports them.
+type tracer struct {
+	exporters []exporter
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+var (
+	mu      sync.Mutex
+	current *tracer
+)
+
+// Init configures tracing from TRACING_OTLP_ENDPOINT and TRACING_FILE.
+// The spans of the previous configuration are flushed.
+func Init() {
+	if err := Flush(); err != nil {
+		log.Errorf("Error exporting traces: %v", err)
+	}
+
+	var t tracer
+	if endpoint := viper.GetString("TRACING_OTLP_ENDPOINT"); endpoint != "" {
+		t.exporters = append(t.exporters, newOTLPExporter(endpoint))
+	}
+	if filename := viper.GetString("TRACING_FILE"); filename != "" {
+		t.exporters = append(t.exporters, &fileExporter{filename: filename})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = nil
+	if len(t.exporters) > 0 {
+		current = &t
+	}
+}
+
+// Flush exports all the ended spans.
+func Flush() error {
+	mu.Lock()
+	t := current
+	mu.Unlock()
+	if t == nil {
+		return nil
+	}
+
+	return t.flush()
+}
+
+// Start starts a span named name, child of parent or the root of a new trace if
+// parent is nil. It returns nil if tracing is disabled.
+func Start(parent *Span, name string) *Span {
+	mu.Lock()
+	enabled := current != nil
+	mu.Unlock()
+	if !enabled {
+		return nil
+	}
+
+	s := Span{name: name, start: time.Now(), attributes: map[string]string{}}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		randomID(s.traceID[:])
+	}
+	randomID(s.spanID[:])
+
+	return &s
+}
+
+// SetAttribute sets the attribute key of the span to value.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed with err, if it's not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End ends the span. Ending a span more than once has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	ended := !s.end.IsZero()
+	if !ended {
+		s.end = time.Now()
+	}
+	s.mu.Unlock()
+	if ended {
+		return
+	}
+
+	mu.Lock()
+	t := current
+	mu.Unlock()
+	if t != nil {
+		t.add(s)
+	}
+}
+
+// TraceID returns the hex encoded ID of the trace of the span,
+// or an empty string if tracing is disabled.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(s.traceID[:])
+}
+
+func (t *tracer) add(s *Span) {
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	full := len(t.spans) >= BatchSize
+	t.mu.Unlock()
+
+	if full {
+		if err := t.flush(); err != nil {
+			log.Errorf("Error exporting traces: %v", err)
+		}
+	}
+}
+
+func (t *tracer) flush() error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	var firstErr error
+	for _, e := range t.exporters {
+		if err := e.export(spans); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func randomID(id []byte) {
+	if _, err := rand.Read(id); err != nil {
+		log.Errorf("Error generating trace ID: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisabled(t *testing.T) {
+	viper.Set("TRACING_OTLP_ENDPOINT", "")
+	viper.Set("TRACING_FILE", "")
+	Init()
+
+	span := Start(nil, "root")
+	assert.Nil(t, span)
+
+	// Nil spans can be used as any other span.
+	child := Start(span, "child")
+	child.SetAttribute("key", "value")
+	child.SetError(errors.New("error"))
+	child.End()
+	assert.Equal(t, "", child.TraceID())
+	assert.Nil(t, Flush())
+}
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var received otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	filename := path.Join(dir, "traces.json")
+	viper.Set("TRACING_OTLP_ENDPOINT", server.URL+"/v1/traces")
+	viper.Set("TRACING_FILE", filename)
+	defer viper.Set("TRACING_OTLP_ENDPOINT", "")
+	defer viper.Set("TRACING_FILE", "")
+	Init()
+
+	root := Start(nil, "crawl")
+	child := Start(root, "ProcessRepo")
+	child.SetAttribute("repository.name", "italia/example")
+	child.SetError(errors.New("not found"))
+	child.End()
+	child.End()
+	root.End()
+
+	assert.Len(t, root.TraceID(), 32)
+	assert.Equal(t, root.TraceID(), child.TraceID())
+	assert.Nil(t, Flush())
+
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "ProcessRepo", spans[0].Name)
+	assert.Equal(t, root.TraceID(), spans[0].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, "repository.name", spans[0].Attributes[0].Key)
+	assert.Equal(t, "italia/example", spans[0].Attributes[0].Value.StringValue)
+	assert.Equal(t, otlpStatus{Code: statusCodeError, Message: "not found"}, spans[0].Status)
+	assert.Equal(t, "", spans[1].ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: statusCodeOk}, spans[1].Status)
+
+	// The file contains the same request.
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	assert.True(t, scanner.Scan())
+	var fromFile otlpRequest
+	assert.Nil(t, json.Unmarshal(scanner.Bytes(), &fromFile))
+	assert.Equal(t, received, fromFile)
+	assert.False(t, scanner.Scan())
+}
+
+func TestExportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	viper.Set("TRACING_OTLP_ENDPOINT", server.URL)
+	defer viper.Set("TRACING_OTLP_ENDPOINT", "")
+	Init()
+
+	Start(nil, "crawl").End()
+	assert.NotNil(t, Flush())
+}
@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Delivery is an event to send to a subscriber.
+type Delivery struct {
+	ID string `json:"id"`
+	// Subscriber is the URL of the subscriber.
+	Subscriber  string    `json:"subscriber"`
+	Event       Event     `json:"event"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Outbox keeps the deliveries not sent yet in a directory, one file per
+// delivery. The deliveries given up are moved to its "failed" subdirectory.
+type Outbox struct {
+	Dir string
+}
+
+// NewOutbox returns the Outbox in dir, creating it if needed.
+func NewOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "failed"), 0755); err != nil {
+		return nil, err
+	}
+
+	return &Outbox{Dir: dir}, nil
+}
+
+func (o *Outbox) file(d Delivery) string {
+	return filepath.Join(o.Dir, d.ID+".json")
+}
+
+// Put writes d to the outbox, replacing its previous state.
+func (o *Outbox) Put(d Delivery) error {
+	return writeJSON(o.file(d), d)
+}
+
+// Remove removes d from the outbox, once delivered.
+func (o *Outbox) Remove(d Delivery) error {
+	return os.Remove(o.file(d))
+}
+
+// Fail moves d to the failed deliveries, to be inspected.
+func (o *Outbox) Fail(d Delivery) error {
+	if err := writeJSON(filepath.Join(o.Dir, "failed", d.ID+".json"), d); err != nil {
+		return err
+	}
+
+	return o.Remove(d)
+}
+
+// List returns the deliveries in the outbox, in the order of their events.
+func (o *Outbox) List() ([]Delivery, error) {
+	files, err := ioutil.ReadDir(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []Delivery
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(o.Dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if !deliveries[i].Event.Time.Equal(deliveries[j].Event.Time) {
+			return deliveries[i].Event.Time.Before(deliveries[j].Event.Time)
+		}
+		return deliveries[i].Event.ID < deliveries[j].Event.ID
+	})
+
+	return deliveries, nil
+}
+
+// writeJSON writes v to path atomically, so that a crash never leaves a
+// partial delivery.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,266 @@
+// Package webhooks sends the events of the catalogue, such as software added,
+// updated or removed, to the subscribers configured, as JSON payloads signed
+// with the secret of the subscriber.
+//
+// The events are first written to an outbox on disk, one file per delivery,
+// and removed once delivered: the deliveries failed are retried with an
+// exponential backoff, also by the following runs, so no event is lost if a
+// subscriber is down.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The types of events.
+const (
+	SoftwareAdded   = "software.added"
+	SoftwareUpdated = "software.updated"
+	SoftwareRemoved = "software.removed"
+	SoftwareInvalid = "software.invalid"
+	PublisherAdded  = "publisher.added"
+)
+
+// The headers of the requests to the subscribers.
+const (
+	EventHeader     = "X-Developers-Italia-Event"
+	DeliveryHeader  = "X-Developers-Italia-Delivery"
+	SignatureHeader = "X-Developers-Italia-Signature"
+)
+
+// Event is an event of the catalogue, the payload sent to the subscribers.
+type Event struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Subscriber is a receiver of the events.
+type Subscriber struct {
+	URL string `mapstructure:"url"`
+	// Secret is the key of the signatures of the payloads.
+	Secret string `mapstructure:"secret"`
+	// Events are the types of events sent, all if empty.
+	Events []string `mapstructure:"events"`
+}
+
+// Wants returns whether the events of type eventType are sent to s.
+func (s Subscriber) Wants(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, t := range s.Events {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the signature of the payload with secret, as the hex HMAC-SHA256
+// prefixed by "sha256=", like the value of SignatureHeader.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher sends the events to the subscribers.
+type Dispatcher struct {
+	Subscribers []Subscriber
+	Outbox      *Outbox
+	Client      *http.Client
+	UserAgent   string
+	// MaxAttempts is the number of attempts of a delivery before giving up.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled at every attempt
+	// up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	now func() time.Time
+}
+
+// NewDispatcher returns a Dispatcher sending the events to subscribers
+// through the outbox.
+func NewDispatcher(subscribers []Subscriber, outbox *Outbox, timeout time.Duration, maxAttempts int, backoff, maxBackoff time.Duration) *Dispatcher {
+	return &Dispatcher{
+		Subscribers: subscribers,
+		Outbox:      outbox,
+		Client:      &http.Client{Timeout: timeout},
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		MaxBackoff:  maxBackoff,
+		now:         time.Now,
+	}
+}
+
+// Emit writes the event of type eventType with data to the outbox, for
+// every subscriber it's sent to.
+func (d *Dispatcher) Emit(eventType string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event := Event{ID: newID(), Type: eventType, Time: d.now().UTC(), Data: raw}
+	for _, s := range d.Subscribers {
+		if !s.Wants(eventType) {
+			continue
+		}
+		err := d.Outbox.Put(Delivery{
+			ID:          newID(),
+			Subscriber:  s.URL,
+			Event:       event,
+			NextAttempt: event.Time,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver sends the deliveries in the outbox that are due. A subscriber gets
+// the events in order: after a failure, its later deliveries wait for the
+// next run. It returns the number of deliveries sent and of the ones given
+// up after MaxAttempts.
+func (d *Dispatcher) Deliver() (sent, dropped int, err error) {
+	deliveries, err := d.Outbox.List()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	subscribers := map[string]Subscriber{}
+	for _, s := range d.Subscribers {
+		subscribers[s.URL] = s
+	}
+
+	blocked := map[string]bool{}
+	for _, delivery := range deliveries {
+		logger := log.WithFields(log.Fields{"subscriber": delivery.Subscriber, "event": delivery.Event.Type, "delivery": delivery.ID})
+
+		s, ok := subscribers[delivery.Subscriber]
+		if !ok {
+			logger.Warn("subscriber no longer configured, dropping the delivery")
+			if err := d.Outbox.Fail(delivery); err != nil {
+				return sent, dropped, err
+			}
+			dropped++
+			continue
+		}
+		if blocked[s.URL] {
+			continue
+		}
+		now := d.now()
+		if now.Before(delivery.NextAttempt) {
+			blocked[s.URL] = true
+			continue
+		}
+
+		err := d.send(s, delivery)
+		if err == nil {
+			if err := d.Outbox.Remove(delivery); err != nil {
+				return sent, dropped, err
+			}
+			sent++
+			logger.Debug("event delivered")
+			continue
+		}
+
+		blocked[s.URL] = true
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.MaxAttempts {
+			logger.Errorf("giving up the delivery after %d attempts: %v", delivery.Attempts, err)
+			if err := d.Outbox.Fail(delivery); err != nil {
+				return sent, dropped, err
+			}
+			dropped++
+			continue
+		}
+
+		delivery.NextAttempt = now.Add(d.backoff(delivery.Attempts))
+		logger.Warnf("delivery failed, retrying at %s: %v", delivery.NextAttempt.Format(time.RFC3339), err)
+		if err := d.Outbox.Put(delivery); err != nil {
+			return sent, dropped, err
+		}
+	}
+
+	return sent, dropped, nil
+}
+
+// backoff returns the delay before the retry after the attempts made.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.Backoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+
+	return delay
+}
+
+// send posts the event of delivery to s. Any 2xx status is a success.
+func (d *Dispatcher) send(s Subscriber, delivery Delivery) error {
+	payload, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event.Type)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	if s.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.Secret, payload))
+	}
+	if d.UserAgent != "" {
+		req.Header.Set("User-Agent", d.UserAgent)
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body to reuse the connection.
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// newID returns a random identifier.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
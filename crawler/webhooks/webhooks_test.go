@@ -0,0 +1,218 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// subscriber is a test subscriber recording the events received, and
+// failing while down.
+type subscriber struct {
+	*httptest.Server
+	mu     sync.Mutex
+	down   bool
+	events []Event
+}
+
+func newSubscriber(t *testing.T, secret string) *subscriber {
+	s := &subscriber{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		payload, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, Sign(secret, payload), r.Header.Get(SignatureHeader))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NotEmpty(t, r.Header.Get(DeliveryHeader))
+
+		var event Event
+		assert.NoError(t, json.Unmarshal(payload, &event))
+		assert.Equal(t, event.Type, r.Header.Get(EventHeader))
+		s.events = append(s.events, event)
+	}))
+
+	return s
+}
+
+func (s *subscriber) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *subscriber) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var types []string
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=6146142a2ce0159e84c0767881e4ec80bc397da62526e7d19f70795eb79460c0", Sign("secret", []byte(`{"id":"1"}`)))
+}
+
+func TestSubscriberWants(t *testing.T) {
+	assert.True(t, Subscriber{}.Wants(SoftwareAdded))
+	s := Subscriber{Events: []string{SoftwareAdded, SoftwareRemoved}}
+	assert.True(t, s.Wants(SoftwareRemoved))
+	assert.False(t, s.Wants(PublisherAdded))
+}
+
+func TestDispatcher(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	all := newSubscriber(t, "s3cret")
+	defer all.Close()
+	removed := newSubscriber(t, "other")
+	defer removed.Close()
+
+	tmp, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "outbox")
+	outbox, err := NewOutbox(dir)
+	assert.NoError(t, err)
+
+	now := time.Date(2020, 10, 20, 15, 0, 0, 0, time.UTC)
+	d := NewDispatcher([]Subscriber{
+		{URL: all.URL, Secret: "s3cret"},
+		{URL: removed.URL, Secret: "other", Events: []string{SoftwareRemoved}},
+	}, outbox, time.Second, 3, time.Minute, 3*time.Minute)
+	d.now = func() time.Time { return now }
+	emit := func(eventType string) {
+		now = now.Add(time.Second)
+		assert.NoError(t, d.Emit(eventType, map[string]string{"url": "https://example.org/a"}))
+	}
+
+	emit(SoftwareAdded)
+	emit(SoftwareRemoved)
+	deliveries, err := outbox.List()
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 3)
+
+	sent, dropped, err := d.Deliver()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, []string{SoftwareAdded, SoftwareRemoved}, all.types())
+	assert.Equal(t, []string{SoftwareRemoved}, removed.types())
+	assert.Equal(t, json.RawMessage(`{"url":"https://example.org/a"}`), all.events[0].Data)
+
+	// While a subscriber is down its events wait, in order, in the outbox.
+	all.setDown(true)
+	emit(SoftwareUpdated)
+	emit(SoftwareInvalid)
+	emit(SoftwareRemoved)
+	sent, _, err = d.Deliver()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{SoftwareRemoved, SoftwareRemoved}, removed.types())
+
+	deliveries, err = outbox.List()
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 3) {
+		assert.Equal(t, SoftwareUpdated, deliveries[0].Event.Type)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, "status 503", deliveries[0].LastError)
+		assert.Equal(t, now.Add(time.Minute), deliveries[0].NextAttempt)
+	}
+
+	// Not retried before the backoff.
+	sent, _, err = d.Deliver()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// The backoff doubles.
+	now = now.Add(time.Minute)
+	_, _, err = d.Deliver()
+	assert.NoError(t, err)
+	deliveries, err = outbox.List()
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Minute), deliveries[0].NextAttempt)
+
+	// A new run with the same outbox delivers the events once the
+	// subscriber is up again.
+	all.setDown(false)
+	now = now.Add(2 * time.Minute)
+	outbox, err = NewOutbox(dir)
+	assert.NoError(t, err)
+	d.Outbox = outbox
+	sent, dropped, err = d.Deliver()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, []string{SoftwareAdded, SoftwareRemoved, SoftwareUpdated, SoftwareInvalid, SoftwareRemoved}, all.types())
+
+	deliveries, err = outbox.List()
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestDispatcherGiveUp(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	down := newSubscriber(t, "")
+	defer down.Close()
+	down.setDown(true)
+
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outbox, err := NewOutbox(dir)
+	assert.NoError(t, err)
+
+	now := time.Date(2020, 10, 20, 15, 0, 0, 0, time.UTC)
+	d := NewDispatcher([]Subscriber{{URL: down.URL}}, outbox, time.Second, 2, time.Minute, time.Hour)
+	d.now = func() time.Time { return now }
+	assert.NoError(t, d.Emit(PublisherAdded, map[string]string{"codiceIPA": "c_a"}))
+
+	_, dropped, err := d.Deliver()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+
+	now = now.Add(time.Hour)
+	_, dropped, err = d.Deliver()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+
+	deliveries, err := outbox.List()
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+	failed, err := filepath.Glob(filepath.Join(dir, "failed", "*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, failed, 1)
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{Backoff: time.Minute, MaxBackoff: 10 * time.Minute}
+	assert.Equal(t, time.Minute, d.backoff(1))
+	assert.Equal(t, 2*time.Minute, d.backoff(2))
+	assert.Equal(t, 8*time.Minute, d.backoff(4))
+	assert.Equal(t, 10*time.Minute, d.backoff(5))
+	assert.Equal(t, 10*time.Minute, d.backoff(100))
+}
@@ -0,0 +1,76 @@
+// Package worktree walks the working tree of a cloned repository once for
+// all the analyses of its files.
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// SkipDirs are the directories not walked: the git metadata and the code of
+// the dependencies, which isn't the code of the repository.
+var SkipDirs = []string{".git", "node_modules", "vendor", "bower_components"}
+
+// Done is returned by a Visitor that doesn't need to visit more files.
+var Done = errors.New("done")
+
+// Visitor visits the files and the directories of a working tree.
+type Visitor interface {
+	// Visit is called with the path and the info of every file and
+	// directory, but the root. It returns Done to stop visiting, or an
+	// error to stop the walk.
+	Visit(path string, info os.FileInfo) error
+}
+
+// Walk walks the working tree in dir, but SkipDirs, calling the visitors
+// with every file and directory. The walk ends early once all the visitors
+// are done.
+func Walk(dir string, visitors ...Visitor) error {
+	active := append([]Visitor(nil), visitors...)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if info.IsDir() && Skip(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		for i := 0; i < len(active); {
+			err := active[i].Visit(path, info)
+			if err == Done {
+				active = append(active[:i], active[i+1:]...)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			i++
+		}
+		if len(active) == 0 {
+			return Done
+		}
+
+		return nil
+	})
+	if err == Done {
+		err = nil
+	}
+
+	return err
+}
+
+// Skip returns true if the directory name is not walked.
+func Skip(name string) bool {
+	for _, dir := range SkipDirs {
+		if dir == name {
+			return true
+		}
+	}
+
+	return false
+}
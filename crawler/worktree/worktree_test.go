@@ -0,0 +1,68 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/italia/developers-italia-backend/crawler/worktree/worktreetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the paths it visits, up to max if set.
+type recorder struct {
+	dir   string
+	max   int
+	paths []string
+}
+
+func (r *recorder) Visit(path string, info os.FileInfo) error {
+	if r.max > 0 && len(r.paths) == r.max {
+		return Done
+	}
+	rel, err := filepath.Rel(r.dir, path)
+	if err != nil {
+		return err
+	}
+	r.paths = append(r.paths, filepath.ToSlash(rel))
+
+	return nil
+}
+
+func TestWalk(t *testing.T) {
+	dir := worktreetest.Write(t, map[string]string{
+		"README.md":                "",
+		"src/main.go":              "",
+		".git/HEAD":                "",
+		"vendor/lib/lib.go":        "",
+		"web/node_modules/a.js":    "",
+		"bower_components/b.js":    "",
+		"docs/vendor-notes.md":     "",
+		"docs/bower_components.md": "",
+	})
+	defer os.RemoveAll(dir)
+
+	all := &recorder{dir: dir}
+	first := &recorder{dir: dir, max: 1}
+	assert.NoError(t, Walk(dir, all, first))
+
+	sort.Strings(all.paths)
+	assert.Equal(t, []string{
+		"README.md",
+		"docs",
+		"docs/bower_components.md",
+		"docs/vendor-notes.md",
+		"src",
+		"src/main.go",
+		"web",
+	}, all.paths)
+	assert.Len(t, first.paths, 1)
+
+	// The walk ends when all the visitors are done.
+	done := &recorder{dir: dir, max: 2}
+	assert.NoError(t, Walk(dir, done))
+	assert.Len(t, done.paths, 2)
+
+	assert.Error(t, Walk(filepath.Join(dir, "missing"), all))
+}
@@ -0,0 +1,30 @@
+// Package worktreetest writes working trees for testing the analyses of the
+// repositories.
+package worktreetest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write writes files, a map from the slash-separated paths to the contents,
+// to a new temporary directory and returns it. The caller removes it.
+func Write(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "worktree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}